@@ -16,8 +16,12 @@ type SentPacketHandler interface {
 	// Specific to multipath operation
 	ReceivedClosePath(f *wire.ClosePathFrame, withPacketNumber protocol.PacketNumber, recvTime time.Time) error
 	SetInflightAsLost()
+	// OnConnectionMigration resets the RTT estimate and the congestion state when the peer changed its address
+	OnConnectionMigration()
 
 	SendingAllowed() bool
+	// TimeUntilSend returns when the next packet may be sent according to the pacer
+	TimeUntilSend() time.Time
 	CongestionFree() bool
 	OvershootFree(pathNum int) bool
 	GetStopWaitingFrame(force bool) *wire.StopWaitingFrame
@@ -29,6 +33,10 @@ type SentPacketHandler interface {
 	OnAlarm()
 
 	DuplicatePacket(packet *Packet)
+	// GetOutstandingPackets returns the packets in flight that carry retransmittable frames
+	GetOutstandingPackets() []*Packet
+	// GetQueuedRetransmissions returns the packets queued for retransmission that were not dequeued yet
+	GetQueuedRetransmissions() []*Packet
 
 	GetStatistics() (uint64, uint64, uint64, uint64)
 	GetCongestionWindow() uint64
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
@@ -26,17 +27,23 @@ type receivedPacketHandler struct {
 	lastAck                                    *wire.AckFrame
 
 	version protocol.VersionNumber
+	clock   congestion.Clock
 
 	packets              uint64
 	recvStreamFrameBytes uint64
 }
 
-// NewReceivedPacketHandler creates a new receivedPacketHandler
-func NewReceivedPacketHandler(version protocol.VersionNumber) ReceivedPacketHandler {
+// NewReceivedPacketHandler creates a new receivedPacketHandler.
+// If clock is nil, the system clock is used.
+func NewReceivedPacketHandler(version protocol.VersionNumber, clock congestion.Clock) ReceivedPacketHandler {
+	if clock == nil {
+		clock = congestion.DefaultClock{}
+	}
 	return &receivedPacketHandler{
 		packetHistory: newReceivedPacketHistory(),
 		ackSendDelay:  protocol.AckSendDelay,
 		version:       version,
+		clock:         clock,
 	}
 }
 
@@ -55,7 +62,7 @@ func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumbe
 
 	if packetNumber > h.largestObserved {
 		h.largestObserved = packetNumber
-		h.largestObservedReceivedTime = time.Now()
+		h.largestObservedReceivedTime = h.clock.Now()
 	}
 
 	if packetNumber <= h.lowerLimit {
@@ -113,7 +120,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 			h.ackQueued = true
 		} else {
 			if h.ackAlarm.IsZero() {
-				h.ackAlarm = time.Now().Add(h.ackSendDelay)
+				h.ackAlarm = h.clock.Now().Add(h.ackSendDelay)
 			}
 		}
 	}
@@ -125,7 +132,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 }
 
 func (h *receivedPacketHandler) GetAckFrame() *wire.AckFrame {
-	if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(time.Now())) {
+	if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(h.clock.Now())) {
 		return nil
 	}
 
@@ -149,8 +156,12 @@ func (h *receivedPacketHandler) GetAckFrame() *wire.AckFrame {
 	return ack
 }
 
+// GetClosePathFrame returns nil if no packet was received yet
 func (h *receivedPacketHandler) GetClosePathFrame() *wire.ClosePathFrame {
 	ackRanges := h.packetHistory.GetAckRanges()
+	if len(ackRanges) == 0 {
+		return nil
+	}
 	frame := &wire.ClosePathFrame{
 		LargestAcked: h.largestObserved,
 		LowestAcked:  ackRanges[len(ackRanges)-1].First,
@@ -18,7 +18,7 @@ var _ = Describe("receivedPacketHandler", func() {
 	)
 
 	BeforeEach(func() {
-		handler = NewReceivedPacketHandler(protocol.VersionWhatever).(*receivedPacketHandler)
+		handler = NewReceivedPacketHandler(protocol.VersionWhatever, nil).(*receivedPacketHandler)
 	})
 
 	Context("accepting packets", func() {
@@ -295,6 +295,10 @@ var _ = Describe("receivedPacketHandler", func() {
 		})
 
 		Context("ClosePath generation", func() {
+			It("doesn't generate a ClosePath frame if no packet was received", func() {
+				Expect(handler.GetClosePathFrame()).To(BeNil())
+			})
+
 			It("generates a simple ClosePath frame", func() {
 				err := handler.ReceivedPacket(1, true, payloadLength)
 				Expect(err).ToNot(HaveOccurred())
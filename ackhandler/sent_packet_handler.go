@@ -34,6 +34,8 @@ const (
 	minTailLossProbeTimeout = 10 * time.Millisecond
 	// Bufferbloat mitigation base parameter lambda
 	bmLambdaBase = 3.0
+	// defaultPacingRTT is the RTT used to compute the pacing rate before an RTT sample is available
+	defaultPacingRTT = 100 * time.Millisecond
 )
 
 var (
@@ -48,9 +50,6 @@ var (
 
 var errPacketNumberNotIncreasing = errors.New("Already sent a packet with a higher packet number")
 
-// DupAck Duplicate ACK for vegas
-var DupAck bool
-
 type sentPacketHandler struct {
 	lastSentPacketNumber protocol.PacketNumber
 	skippedPackets       []protocol.PacketNumber
@@ -69,6 +68,7 @@ type sentPacketHandler struct {
 	bytesInFlight protocol.ByteCount
 
 	congestion congestion.SendAlgorithm
+	pacer      *congestion.Pacer
 
 	rttStats *congestion.RTTStats
 
@@ -89,8 +89,11 @@ type sentPacketHandler struct {
 	// The alarm timeout
 	alarm time.Time
 
-	pathID        protocol.PathID
-	onAckCallback func(protocol.PathID, protocol.PacketNumber)
+	pathID         protocol.PathID
+	onAckCallback  func(protocol.PathID, protocol.PacketNumber)
+	onLossCallback func(protocol.PathID, protocol.PacketNumber)
+
+	clock congestion.Clock
 
 	packets              uint64
 	retransmissions      uint64
@@ -107,8 +110,14 @@ func (h *sentPacketHandler) GetBytesInFlight() uint64 {
 }
 
 // NewSentPacketHandler creates a new sentPacketHandler
+// onLossCallback is called for every packet declared lost, it may be nil. If clock is nil, the system clock is used.
 func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlgorithm, onRTOCallback func(time.Time) bool,
-	pathID protocol.PathID, onAckCallback func(protocol.PathID, protocol.PacketNumber)) SentPacketHandler {
+	pathID protocol.PathID, onAckCallback func(protocol.PathID, protocol.PacketNumber),
+	onLossCallback func(protocol.PathID, protocol.PacketNumber), clock congestion.Clock) SentPacketHandler {
+
+	if clock == nil {
+		clock = congestion.DefaultClock{}
+	}
 
 	var congestionControl congestion.SendAlgorithm
 
@@ -116,7 +125,7 @@ func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlg
 		congestionControl = cong
 	} else {
 		congestionControl = congestion.NewCubicSender(
-			congestion.DefaultClock{},
+			clock,
 			rttStats,
 			false, /* don't use reno since chromium doesn't (why?) */
 			protocol.InitialCongestionWindow,
@@ -124,7 +133,7 @@ func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlg
 		)
 	}
 
-	return &sentPacketHandler{
+	h := &sentPacketHandler{
 		packetHistory:      NewPacketList(),
 		stopWaitingManager: stopWaitingManager{},
 		rttStats:           rttStats,
@@ -132,37 +141,26 @@ func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlg
 		onRTOCallback:      onRTOCallback,
 		pathID:             pathID,
 		onAckCallback:      onAckCallback,
+		onLossCallback:     onLossCallback,
+		clock:              clock,
 	}
+	h.pacer = congestion.NewPacer(h.pacingRate)
+	return h
 }
 
-// NewVegasSentPacketHandler is an experimental PacketHandler for Vegas
-func NewVegasSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlgorithmVegas, onRTOCallback func(time.Time) bool,
-	pathID protocol.PathID, onAckCallback func(protocol.PathID, protocol.PacketNumber), checkDupAck bool) SentPacketHandler {
-	checkDupAck = DupAck
-	var congestionControl congestion.SendAlgorithm
-
-	congestionControl = congestion.NewVegasSender(congestion.DefaultClock{}, rttStats, false, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow, checkDupAck)
-	//if checkDupAck() == true {
-	//
-	//	}
-	return &sentPacketHandler{
-		packetHistory:      NewPacketList(),
-		stopWaitingManager: stopWaitingManager{},
-		rttStats:           rttStats,
-		congestion:         congestionControl,
-		onRTOCallback:      onRTOCallback,
-		pathID:             pathID,
-		onAckCallback:      onAckCallback,
+// pacingRate is the rate at which packets are paced on this path.
+// Senders that don't set a pacing rate are paced at 1.25 times the congestion window per RTT.
+func (h *sentPacketHandler) pacingRate() congestion.Bandwidth {
+	if pacing, ok := h.congestion.(congestion.PacingSendAlgorithm); ok {
+		return pacing.PacingRate()
+	}
+	srtt := h.rttStats.SmoothedRTT()
+	if srtt == 0 {
+		srtt = defaultPacingRTT
 	}
+	return congestion.BandwidthFromDelta(h.congestion.GetCongestionWindow(), srtt) * 5 / 4
 }
 
-// func (h *sentPacketHandler) CheckDupVegas() bool {
-// 	// retransmit the last packet if has Duplicate ack or time out
-// 	if DupAck {
-// 		h.retransmitOldestPacket()
-// 	}
-// }
-
 func (h *sentPacketHandler) GetStatistics() (uint64, uint64, uint64, uint64) {
 	return h.packets, h.retransmissions, h.losses, h.sentStreamFrameBytes
 }
@@ -196,7 +194,7 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 	}
 
 	h.lastSentPacketNumber = packet.PacketNumber
-	now := time.Now()
+	now := h.clock.Now()
 
 	// Update some statistics
 	h.packets++
@@ -213,6 +211,7 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 		h.bytesInFlight += packet.Length
 		h.packetHistory.PushBack(*packet)
 		h.numNonRetransmittablePackets = 0
+		h.pacer.SentPacket(now, packet.Length)
 	} else {
 		h.numNonRetransmittablePackets++
 	}
@@ -236,7 +235,9 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 
 	// duplicate or out-of-order ACK
 	if withPacketNumber <= h.largestReceivedPacketWithAck {
-		DupAck = true
+		if delayBased, ok := h.congestion.(congestion.DelayBasedSendAlgorithm); ok {
+			delayBased.OnDuplicateAck()
+		}
 		return ErrDuplicateOrOutOfOrderAck
 	}
 	h.largestReceivedPacketWithAck = withPacketNumber
@@ -399,7 +400,7 @@ func (h *sentPacketHandler) maybeUpdateRTT(largestAcked protocol.PacketNumber, a
 	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
 		packet := el.Value
 		if packet.PacketNumber == largestAcked {
-			h.rttStats.UpdateRTT(rcvTime.Sub(packet.SendTime), ackDelay, time.Now())
+			h.rttStats.UpdateRTT(rcvTime.Sub(packet.SendTime), ackDelay, h.clock.Now())
 			return true
 		}
 		// Packets are sorted by number, so we can stop searching
@@ -441,7 +442,7 @@ func (h *sentPacketHandler) updateLossDetectionAlarm() {
 
 func (h *sentPacketHandler) detectLostPackets() {
 	h.lossTime = time.Time{}
-	now := time.Now()
+	now := h.clock.Now()
 
 	maxRTT := float64(utils.MaxDuration(h.rttStats.LatestRTT(), h.rttStats.SmoothedRTT()))
 	delayUntilLost := time.Duration((1.0 + timeReorderingFraction) * maxRTT)
@@ -469,10 +470,17 @@ func (h *sentPacketHandler) detectLostPackets() {
 		for _, p := range lostPackets {
 			h.queuePacketForRetransmission(p)
 			h.congestion.OnPacketLost(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
+			h.reportLoss(p.Value.PacketNumber)
 		}
 	}
 }
 
+func (h *sentPacketHandler) OnConnectionMigration() {
+	h.rttStats.OnConnectionMigration()
+	h.congestion.OnConnectionMigration()
+	h.pacer = congestion.NewPacer(h.pacingRate)
+}
+
 func (h *sentPacketHandler) SetInflightAsLost() {
 	var lostPackets []*PacketElement
 	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
@@ -491,6 +499,7 @@ func (h *sentPacketHandler) SetInflightAsLost() {
 			h.queuePacketForRetransmission(p)
 			// XXX (QDC): should we?
 			h.congestion.OnPacketLost(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
+			h.reportLoss(p.Value.PacketNumber)
 		}
 	}
 }
@@ -566,6 +575,7 @@ func (h *sentPacketHandler) GetStopWaitingFrame(force bool) *wire.StopWaitingFra
 func (h *sentPacketHandler) SendingAllowed() bool {
 	congestionLimited := h.bytesInFlight > h.congestion.GetCongestionWindow()
 	maxTrackedLimited := protocol.PacketNumber(len(h.retransmissionQueue)+h.packetHistory.Len()) >= protocol.MaxTrackedSentPackets
+	pacingLimited := h.pacer.TimeUntilSend(h.clock.Now()) > 0
 	if congestionLimited {
 		utils.Debugf("Congestion limited: bytes in flight %d, window %d",
 			h.bytesInFlight,
@@ -575,7 +585,18 @@ func (h *sentPacketHandler) SendingAllowed() bool {
 	// Always allow sending of retransmissions. This should probably be limited
 	// to RTOs, but we currently don't have a nice way of distinguishing them.
 	haveRetransmissions := len(h.retransmissionQueue) > 0
-	return !maxTrackedLimited && (!congestionLimited || haveRetransmissions)
+	return !maxTrackedLimited && (!congestionLimited && !pacingLimited || haveRetransmissions)
+}
+
+// TimeUntilSend returns when the pacer allows sending the next packet.
+// It returns the zero time if sending is not delayed by the pacer.
+func (h *sentPacketHandler) TimeUntilSend() time.Time {
+	now := h.clock.Now()
+	delay := h.pacer.TimeUntilSend(now)
+	if delay == 0 {
+		return time.Time{}
+	}
+	return now.Add(delay)
 }
 
 func (h *sentPacketHandler) CongestionFree() bool {
@@ -631,6 +652,13 @@ func (h *sentPacketHandler) queueRTO(el *PacketElement) {
 	h.queuePacketForRetransmission(el)
 	h.losses++
 	h.congestion.OnPacketLost(packet.PacketNumber, packet.Length, h.bytesInFlight)
+	h.reportLoss(packet.PacketNumber)
+}
+
+func (h *sentPacketHandler) reportLoss(packetNumber protocol.PacketNumber) {
+	if h.onLossCallback != nil {
+		h.onLossCallback(h.pathID, packetNumber)
+	}
 }
 
 func (h *sentPacketHandler) queuePacketForRetransmission(packetElement *PacketElement) {
@@ -645,6 +673,22 @@ func (h *sentPacketHandler) DuplicatePacket(packet *Packet) {
 	h.retransmissionQueue = append(h.retransmissionQueue, packet)
 }
 
+func (h *sentPacketHandler) GetOutstandingPackets() []*Packet {
+	var packets []*Packet
+	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
+		if HasRetransmittableFrames(el.Value.Frames) {
+			packets = append(packets, &el.Value)
+		}
+	}
+	return packets
+}
+
+func (h *sentPacketHandler) GetQueuedRetransmissions() []*Packet {
+	packets := make([]*Packet, len(h.retransmissionQueue))
+	copy(packets, h.retransmissionQueue)
+	return packets
+}
+
 func (h *sentPacketHandler) computeRTOTimeout() time.Duration {
 	rto := h.congestion.RetransmissionDelay()
 	if rto == 0 {
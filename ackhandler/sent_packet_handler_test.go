@@ -15,6 +15,7 @@ type mockCongestion struct {
 	maybeExitSlowStart      bool
 	onRetransmissionTimeout bool
 	getCongestionWindow     bool
+	onConnectionMigration   bool
 	packetsAcked            [][]interface{}
 	packetsLost             [][]interface{}
 }
@@ -46,7 +47,7 @@ func (m *mockCongestion) RetransmissionDelay() time.Duration {
 }
 
 func (m *mockCongestion) SetNumEmulatedConnections(n int)         { panic("not implemented") }
-func (m *mockCongestion) OnConnectionMigration()                  { panic("not implemented") }
+func (m *mockCongestion) OnConnectionMigration()                  { m.onConnectionMigration = true }
 func (m *mockCongestion) SetSlowStartLargeReduction(enabled bool) { panic("not implemented") }
 func (m *mockCongestion) SmoothedRTT() time.Duration              { return defaultRTOTimeout / 10 }
 
@@ -58,6 +59,22 @@ func (m *mockCongestion) OnPacketLost(n protocol.PacketNumber, l protocol.ByteCo
 	m.packetsLost = append(m.packetsLost, []interface{}{n, l, bif})
 }
 
+type mockDelayBasedCongestion struct {
+	mockCongestion
+	duplicateAcks int
+}
+
+func (m *mockDelayBasedCongestion) InRecovery() bool  { panic("not implemented") }
+func (m *mockDelayBasedCongestion) InSlowStart() bool { panic("not implemented") }
+func (m *mockDelayBasedCongestion) OnDuplicateAck()   { m.duplicateAcks++ }
+
+type mockPacingCongestion struct {
+	mockCongestion
+	pacingRate congestion.Bandwidth
+}
+
+func (m *mockPacingCongestion) PacingRate() congestion.Bandwidth { return m.pacingRate }
+
 func retransmittablePacket(num protocol.PacketNumber) *Packet {
 	return &Packet{PacketNumber: num, Length: 1, Frames: []wire.Frame{&wire.PingFrame{}}}
 }
@@ -77,7 +94,7 @@ var _ = Describe("SentPacketHandler", func() {
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
 
-		handler = NewSentPacketHandler(rttStats, nil, nil, pt, nil).(*sentPacketHandler)
+		handler = NewSentPacketHandler(rttStats, nil, nil, pt, nil, nil, nil).(*sentPacketHandler)
 
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
@@ -100,6 +117,17 @@ var _ = Describe("SentPacketHandler", func() {
 	})
 
 	Context("registering sent packets", func() {
+		It("returns the outstanding retransmittable packets", func() {
+			Expect(handler.SentPacket(retransmittablePacket(1))).To(Succeed())
+			Expect(handler.SentPacket(nonRetransmittablePacket(2))).To(Succeed())
+			Expect(handler.SentPacket(retransmittablePacket(3))).To(Succeed())
+			var packetNumbers []protocol.PacketNumber
+			for _, p := range handler.GetOutstandingPackets() {
+				packetNumbers = append(packetNumbers, p.PacketNumber)
+			}
+			Expect(packetNumbers).To(Equal([]protocol.PacketNumber{1, 3}))
+		})
+
 		It("accepts two consecutive packets", func() {
 			packet1 := Packet{PacketNumber: 1, Frames: []wire.Frame{&streamFrame}, Length: 1}
 			packet2 := Packet{PacketNumber: 2, Frames: []wire.Frame{&streamFrame}, Length: 2}
@@ -682,6 +710,20 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(cong.argsOnPacketSent[4]).To(BeTrue())
 		})
 
+		It("notifies delay-based algorithms of duplicate ACKs", func() {
+			delayBased := &mockDelayBasedCongestion{}
+			handler.congestion = delayBased
+			handler.onAckCallback = func(protocol.PathID, protocol.PacketNumber) {}
+			handler.SentPacket(retransmittablePacket(1))
+			handler.SentPacket(retransmittablePacket(2))
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(delayBased.duplicateAcks).To(BeZero())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, time.Now())
+			Expect(err).To(MatchError(ErrDuplicateOrOutOfOrderAck))
+			Expect(delayBased.duplicateAcks).To(Equal(1))
+		})
+
 		It("should call MaybeExitSlowStart and OnPacketAcked", func() {
 			handler.SentPacket(retransmittablePacket(1))
 			handler.SentPacket(retransmittablePacket(2))
@@ -737,6 +779,59 @@ var _ = Describe("SentPacketHandler", func() {
 			handler.retransmissionQueue = []*Packet{nil}
 			Expect(handler.SendingAllowed()).To(BeTrue())
 		})
+
+		Context("pacing", func() {
+			sendFullPackets := func(n int) {
+				for i := 0; i < n; i++ {
+					err := handler.SentPacket(&Packet{
+						PacketNumber: handler.lastSentPacketNumber + 1,
+						Frames:       []wire.Frame{&wire.PingFrame{}},
+						Length:       protocol.DefaultTCPMSS,
+					})
+					Expect(err).NotTo(HaveOccurred())
+				}
+				// only test the pacer, not the congestion window
+				handler.bytesInFlight = 0
+			}
+
+			It("paces packets at 1.25 times the congestion window per RTT", func() {
+				Expect(handler.pacingRate()).To(Equal(congestion.BandwidthFromDelta(protocol.DefaultTCPMSS, 100*time.Millisecond) * 5 / 4))
+				handler.rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+				Expect(handler.pacingRate()).To(Equal(congestion.BandwidthFromDelta(protocol.DefaultTCPMSS, 10*time.Millisecond) * 5 / 4))
+			})
+
+			It("uses the pacing rate of the congestion control", func() {
+				handler.congestion = &mockPacingCongestion{pacingRate: 42 * congestion.BytesPerSecond}
+				Expect(handler.pacingRate()).To(Equal(42 * congestion.BytesPerSecond))
+			})
+
+			It("allows an initial burst, then delays sending", func() {
+				Expect(handler.TimeUntilSend()).To(BeZero())
+				sendFullPackets(3)
+				Expect(handler.SendingAllowed()).To(BeFalse())
+				// one full sized packet takes 80ms at the pacing rate
+				Expect(handler.TimeUntilSend()).To(BeTemporally("~", time.Now().Add(80*time.Millisecond), 5*time.Millisecond))
+			})
+
+			It("doesn't pace non-retransmittable packets", func() {
+				for i := 1; i <= 5; i++ {
+					Expect(handler.SentPacket(nonRetransmittablePacket(protocol.PacketNumber(i)))).To(Succeed())
+				}
+				Expect(handler.TimeUntilSend()).To(BeZero())
+				Expect(handler.SendingAllowed()).To(BeTrue())
+			})
+
+			It("resets the RTT estimate and the pacer on connection migration", func() {
+				handler.rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+				sendFullPackets(3)
+				Expect(handler.TimeUntilSend()).ToNot(BeZero())
+				handler.OnConnectionMigration()
+				Expect(handler.rttStats.SmoothedRTT()).To(BeZero())
+				Expect(handler.rttStats.MinRTT()).To(BeZero())
+				Expect(handler.congestion.(*mockCongestion).onConnectionMigration).To(BeTrue())
+				Expect(handler.TimeUntilSend()).To(BeZero())
+			})
+		})
 	})
 
 	Context("calculating RTO", func() {
@@ -840,5 +935,34 @@ var _ = Describe("SentPacketHandler", func() {
 
 			Expect(handler.rtoCount).To(BeEquivalentTo(1))
 		})
+
+		It("returns the packets queued for retransmission", func() {
+			handler.SentPacket(retransmittablePacket(1))
+			handler.SentPacket(retransmittablePacket(2))
+			handler.SentPacket(retransmittablePacket(3))
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+			var packetNumbers []protocol.PacketNumber
+			for _, p := range handler.GetQueuedRetransmissions() {
+				packetNumbers = append(packetNumbers, p.PacketNumber)
+			}
+			Expect(packetNumbers).To(Equal([]protocol.PacketNumber{1, 2}))
+			handler.DequeuePacketForRetransmission()
+			Expect(handler.GetQueuedRetransmissions()).To(HaveLen(1))
+		})
+
+		It("reports the packets lost by the RTO", func() {
+			var lost []protocol.PacketNumber
+			handler.onLossCallback = func(pathID protocol.PathID, pn protocol.PacketNumber) {
+				Expect(pathID).To(Equal(handler.pathID))
+				lost = append(lost, pn)
+			}
+			handler.SentPacket(retransmittablePacket(1))
+			handler.SentPacket(retransmittablePacket(2))
+			handler.SentPacket(retransmittablePacket(3))
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+			Expect(lost).To(Equal([]protocol.PacketNumber{1, 2}))
+		})
 	})
 })
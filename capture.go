@@ -0,0 +1,233 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// The block types and options of pcapng, see https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-02.html
+const (
+	pcapngSectionHeaderBlock      = 0x0a0d0d0a
+	pcapngInterfaceDescription    = 0x00000001
+	pcapngEnhancedPacketBlock     = 0x00000006
+	pcapngByteOrderMagic          = 0x1a2b3c4d
+	pcapngOptionEndOfOptions      = 0
+	pcapngOptionComment           = 1
+	pcapngOptionUserApplication   = 4
+	pcapngOptionInterfaceName     = 2
+	pcapngOptionTimestampRes      = 9
+	pcapngOptionFlags             = 2
+	pcapngFlagInbound             = 1
+	pcapngFlagOutbound            = 2
+	pcapngLinkTypeRaw             = 101
+	pcapngSnapLen                 = 0xffff
+	pcapngTimestampResNanoseconds = 9
+)
+
+type captureFlowKey struct {
+	local, remote string
+}
+
+// A packetCapture writes the packets sent and received by a pconnManager to a pcapng file.
+// Every local address is an interface of the capture. The packets are written with an IP and a UDP header,
+// carrying the local and the remote address, and a comment naming their path.
+// The path of a received packet is the path that last sent a packet with the same addresses,
+// the packets received before aren't annotated.
+type packetCapture struct {
+	mutex sync.Mutex
+
+	w          io.Writer
+	interfaces map[string]uint32
+	paths      map[captureFlowKey]protocol.PathID
+}
+
+// start writes the section header to w, and starts capturing the packets
+func (c *packetCapture) start(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.w = w
+	c.interfaces = make(map[string]uint32)
+	c.paths = make(map[captureFlowKey]protocol.PathID)
+
+	body := &bytes.Buffer{}
+	utils.LittleEndian.WriteUint32(body, pcapngByteOrderMagic)
+	utils.LittleEndian.WriteUint16(body, 1)
+	utils.LittleEndian.WriteUint16(body, 0)
+	// the length of the section isn't known in advance
+	utils.LittleEndian.WriteUint64(body, 0xffffffffffffffff)
+	writePcapngOption(body, pcapngOptionUserApplication, []byte("quic-go"))
+	writePcapngOption(body, pcapngOptionEndOfOptions, nil)
+	c.writeBlock(pcapngSectionHeaderBlock, body.Bytes())
+}
+
+func (c *packetCapture) sentPacket(pathID protocol.PathID, localAddr, remoteAddr net.Addr, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.w == nil {
+		return
+	}
+	c.paths[captureFlowKey{local: localAddr.String(), remote: remoteAddr.String()}] = pathID
+	c.writePacket(time.Now(), localAddr, remoteAddr, data, true, fmt.Sprintf("path %d", pathID))
+}
+
+func (c *packetCapture) receivedPacket(rcvTime time.Time, localAddr, remoteAddr net.Addr, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.w == nil {
+		return
+	}
+	var comment string
+	if pathID, ok := c.paths[captureFlowKey{local: localAddr.String(), remote: remoteAddr.String()}]; ok {
+		comment = fmt.Sprintf("path %d", pathID)
+	}
+	c.writePacket(rcvTime, localAddr, remoteAddr, data, false, comment)
+}
+
+func (c *packetCapture) writePacket(t time.Time, localAddr, remoteAddr net.Addr, data []byte, outbound bool, comment string) {
+	local, ok1 := localAddr.(*net.UDPAddr)
+	remote, ok2 := remoteAddr.(*net.UDPAddr)
+	if !ok1 || !ok2 {
+		return
+	}
+	flags := uint32(pcapngFlagInbound)
+	if outbound {
+		flags = pcapngFlagOutbound
+	}
+	packet := capturedIPPacket(local, remote, outbound, data)
+
+	body := &bytes.Buffer{}
+	utils.LittleEndian.WriteUint32(body, c.interfaceID(local))
+	nanoseconds := uint64(t.UnixNano())
+	utils.LittleEndian.WriteUint32(body, uint32(nanoseconds>>32))
+	utils.LittleEndian.WriteUint32(body, uint32(nanoseconds))
+	utils.LittleEndian.WriteUint32(body, uint32(len(packet)))
+	utils.LittleEndian.WriteUint32(body, uint32(len(packet)))
+	body.Write(packet)
+	writePcapngPadding(body, len(packet))
+	flagsValue := make([]byte, 4)
+	binary.LittleEndian.PutUint32(flagsValue, flags)
+	writePcapngOption(body, pcapngOptionFlags, flagsValue)
+	if comment != "" {
+		writePcapngOption(body, pcapngOptionComment, []byte(comment))
+	}
+	writePcapngOption(body, pcapngOptionEndOfOptions, nil)
+	c.writeBlock(pcapngEnhancedPacketBlock, body.Bytes())
+}
+
+// interfaceID returns the ID of the interface of a local address, and describes the interface the first time
+func (c *packetCapture) interfaceID(localAddr *net.UDPAddr) uint32 {
+	if id, ok := c.interfaces[localAddr.String()]; ok {
+		return id
+	}
+	id := uint32(len(c.interfaces))
+	c.interfaces[localAddr.String()] = id
+
+	body := &bytes.Buffer{}
+	utils.LittleEndian.WriteUint16(body, pcapngLinkTypeRaw)
+	utils.LittleEndian.WriteUint16(body, 0)
+	utils.LittleEndian.WriteUint32(body, pcapngSnapLen)
+	writePcapngOption(body, pcapngOptionInterfaceName, []byte(localAddr.String()))
+	writePcapngOption(body, pcapngOptionTimestampRes, []byte{pcapngTimestampResNanoseconds})
+	writePcapngOption(body, pcapngOptionEndOfOptions, nil)
+	c.writeBlock(pcapngInterfaceDescription, body.Bytes())
+	return id
+}
+
+// writeBlock writes a block with a single call to Write. The capture is stopped if it fails.
+func (c *packetCapture) writeBlock(blockType uint32, body []byte) {
+	b := &bytes.Buffer{}
+	length := uint32(12 + len(body))
+	utils.LittleEndian.WriteUint32(b, blockType)
+	utils.LittleEndian.WriteUint32(b, length)
+	b.Write(body)
+	utils.LittleEndian.WriteUint32(b, length)
+	if _, err := c.w.Write(b.Bytes()); err != nil {
+		utils.Errorf("Stopping the packet capture: %s", err.Error())
+		c.w = nil
+	}
+}
+
+func writePcapngOption(b *bytes.Buffer, code uint16, value []byte) {
+	utils.LittleEndian.WriteUint16(b, code)
+	utils.LittleEndian.WriteUint16(b, uint16(len(value)))
+	b.Write(value)
+	writePcapngPadding(b, len(value))
+}
+
+// writePcapngPadding pads a value of length n to 32 bits
+func writePcapngPadding(b *bytes.Buffer, n int) {
+	b.Write(make([]byte, (4-n%4)%4))
+}
+
+// capturedIPPacket puts data into a UDP datagram between the local and the remote address.
+// The IP version is the version of the remote address, the local address is converted to it,
+// e.g. the unspecified address of a socket listening on all interfaces.
+func capturedIPPacket(local, remote *net.UDPAddr, outbound bool, data []byte) []byte {
+	b := &bytes.Buffer{}
+	udpLength := 8 + len(data)
+	src, dst := remote, local
+	if outbound {
+		src, dst = local, remote
+	}
+	if remoteIP := remote.IP.To4(); remoteIP != nil {
+		localIP := local.IP.To4()
+		if localIP == nil {
+			localIP = net.IPv4zero.To4()
+		}
+		srcIP, dstIP := remoteIP, localIP
+		if outbound {
+			srcIP, dstIP = localIP, remoteIP
+		}
+		header := make([]byte, 20)
+		header[0] = 0x45
+		binary.BigEndian.PutUint16(header[2:4], uint16(20+udpLength))
+		header[8] = 64
+		header[9] = 17
+		copy(header[12:16], srcIP)
+		copy(header[16:20], dstIP)
+		binary.BigEndian.PutUint16(header[10:12], ipv4Checksum(header))
+		b.Write(header)
+	} else {
+		header := make([]byte, 40)
+		header[0] = 0x60
+		binary.BigEndian.PutUint16(header[4:6], uint16(udpLength))
+		header[6] = 17
+		header[7] = 64
+		copy(header[8:24], src.IP.To16())
+		copy(header[24:40], dst.IP.To16())
+		b.Write(header)
+	}
+	// the UDP checksum is left out, it is optional for IPv4 and not verified by Wireshark by default
+	utils.BigEndian.WriteUint16(b, uint16(src.Port))
+	utils.BigEndian.WriteUint16(b, uint16(dst.Port))
+	utils.BigEndian.WriteUint16(b, uint16(udpLength))
+	utils.BigEndian.WriteUint16(b, 0)
+	b.Write(data)
+	return b.Bytes()
+}
+
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+func (s *session) captureSentPacket(packet *packedPacket, pth *path) {
+	if s.capture == nil {
+		return
+	}
+	s.capture.sentPacket(pth.pathID, pth.conn.LocalAddr(), pth.conn.RemoteAddr(), packet.raw)
+}
@@ -0,0 +1,200 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type capturedBlock struct {
+	blockType uint32
+	body      []byte
+}
+
+// parseCapturedBlocks splits a little-endian pcapng file into its blocks
+func parseCapturedBlocks(data []byte) []capturedBlock {
+	var blocks []capturedBlock
+	for len(data) > 0 {
+		ExpectWithOffset(1, len(data)).To(BeNumerically(">=", 12))
+		length := binary.LittleEndian.Uint32(data[4:8])
+		ExpectWithOffset(1, length%4).To(BeZero())
+		ExpectWithOffset(1, binary.LittleEndian.Uint32(data[length-4:length])).To(Equal(length))
+		blocks = append(blocks, capturedBlock{
+			blockType: binary.LittleEndian.Uint32(data[0:4]),
+			body:      data[8 : length-4],
+		})
+		data = data[length:]
+	}
+	return blocks
+}
+
+// capturedOptions parses the options of a block
+func capturedOptions(options []byte) map[uint16][]byte {
+	values := make(map[uint16][]byte)
+	for len(options) >= 4 {
+		code, length := binary.LittleEndian.Uint16(options[0:2]), int(binary.LittleEndian.Uint16(options[2:4]))
+		if code == pcapngOptionEndOfOptions {
+			break
+		}
+		values[code] = options[4 : 4+length]
+		options = options[4+(length+3)/4*4:]
+	}
+	return values
+}
+
+// capturedPacket returns the packet data and the options of an Enhanced Packet Block
+func capturedPacket(block capturedBlock) (uint32, []byte, map[uint16][]byte) {
+	ExpectWithOffset(1, block.blockType).To(Equal(uint32(pcapngEnhancedPacketBlock)))
+	length := int(binary.LittleEndian.Uint32(block.body[12:16]))
+	return binary.LittleEndian.Uint32(block.body[0:4]), block.body[20 : 20+length], capturedOptions(block.body[20+(length+3)/4*4:])
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+var _ = Describe("Packet Capture", func() {
+	var (
+		capture *packetCapture
+		buf     *bytes.Buffer
+		local   *net.UDPAddr
+		remote  *net.UDPAddr
+	)
+
+	BeforeEach(func() {
+		capture = &packetCapture{}
+		buf = &bytes.Buffer{}
+		local = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+		remote = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 6121}
+	})
+
+	It("doesn't capture anything before it is started", func() {
+		capture.sentPacket(1, local, remote, []byte("foobar"))
+		capture.receivedPacket(time.Now(), local, remote, []byte("foobar"))
+		Expect(capture.w).To(BeNil())
+	})
+
+	It("writes a section header", func() {
+		capture.start(buf)
+		blocks := parseCapturedBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(1))
+		Expect(blocks[0].blockType).To(Equal(uint32(pcapngSectionHeaderBlock)))
+		Expect(binary.LittleEndian.Uint32(blocks[0].body[0:4])).To(Equal(uint32(pcapngByteOrderMagic)))
+		Expect(capturedOptions(blocks[0].body[16:])).To(HaveKeyWithValue(uint16(pcapngOptionUserApplication), []byte("quic-go")))
+	})
+
+	It("writes sent packets with their path", func() {
+		capture.start(buf)
+		now := time.Now()
+		capture.sentPacket(3, local, remote, []byte("foobar"))
+		blocks := parseCapturedBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(3))
+		Expect(blocks[1].blockType).To(Equal(uint32(pcapngInterfaceDescription)))
+		Expect(binary.LittleEndian.Uint16(blocks[1].body[0:2])).To(Equal(uint16(pcapngLinkTypeRaw)))
+		Expect(capturedOptions(blocks[1].body[8:])).To(HaveKeyWithValue(uint16(pcapngOptionInterfaceName), []byte("10.0.0.1:4242")))
+
+		interfaceID, packet, options := capturedPacket(blocks[2])
+		Expect(interfaceID).To(BeZero())
+		nanoseconds := int64(binary.LittleEndian.Uint32(blocks[2].body[4:8]))<<32 | int64(binary.LittleEndian.Uint32(blocks[2].body[8:12]))
+		Expect(time.Unix(0, nanoseconds)).To(BeTemporally("~", now, time.Second))
+		Expect(options).To(HaveKeyWithValue(uint16(pcapngOptionComment), []byte("path 3")))
+		Expect(options).To(HaveKeyWithValue(uint16(pcapngOptionFlags), []byte{pcapngFlagOutbound, 0, 0, 0}))
+		// IPv4 header
+		Expect(packet).To(HaveLen(20 + 8 + 6))
+		Expect(packet[9]).To(Equal(byte(17)))
+		Expect(ipv4Checksum(packet[:20])).To(BeZero())
+		Expect(net.IP(packet[12:16]).Equal(local.IP)).To(BeTrue())
+		Expect(net.IP(packet[16:20]).Equal(remote.IP)).To(BeTrue())
+		// UDP header
+		Expect(binary.BigEndian.Uint16(packet[20:22])).To(Equal(uint16(4242)))
+		Expect(binary.BigEndian.Uint16(packet[22:24])).To(Equal(uint16(6121)))
+		Expect(binary.BigEndian.Uint16(packet[24:26])).To(Equal(uint16(14)))
+		Expect(packet[28:]).To(Equal([]byte("foobar")))
+	})
+
+	It("annotates received packets with the path that sent packets between the same addresses", func() {
+		capture.start(buf)
+		capture.receivedPacket(time.Now(), local, remote, []byte("foo"))
+		capture.sentPacket(2, local, remote, []byte("bar"))
+		capture.receivedPacket(time.Now(), local, remote, []byte("baz"))
+		blocks := parseCapturedBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(5))
+		_, packet, options := capturedPacket(blocks[2])
+		Expect(options).ToNot(HaveKey(uint16(pcapngOptionComment)))
+		Expect(options).To(HaveKeyWithValue(uint16(pcapngOptionFlags), []byte{pcapngFlagInbound, 0, 0, 0}))
+		Expect(net.IP(packet[12:16]).Equal(remote.IP)).To(BeTrue())
+		Expect(binary.BigEndian.Uint16(packet[20:22])).To(Equal(uint16(6121)))
+		_, _, options = capturedPacket(blocks[4])
+		Expect(options).To(HaveKeyWithValue(uint16(pcapngOptionComment), []byte("path 2")))
+	})
+
+	It("describes an interface per local address", func() {
+		capture.start(buf)
+		otherLocal := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 4242}
+		capture.sentPacket(0, local, remote, []byte("foo"))
+		capture.sentPacket(1, otherLocal, remote, []byte("bar"))
+		capture.sentPacket(0, local, remote, []byte("baz"))
+		blocks := parseCapturedBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(6))
+		Expect(blocks[3].blockType).To(Equal(uint32(pcapngInterfaceDescription)))
+		interfaceID, _, _ := capturedPacket(blocks[4])
+		Expect(interfaceID).To(Equal(uint32(1)))
+		interfaceID, _, _ = capturedPacket(blocks[5])
+		Expect(interfaceID).To(BeZero())
+	})
+
+	It("uses the IP version of the remote address", func() {
+		capture.start(buf)
+		any := &net.UDPAddr{IP: net.IPv6unspecified, Port: 4242}
+		capture.sentPacket(0, any, remote, []byte("foo"))
+		capture.sentPacket(0, any, &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}, []byte("bar"))
+		blocks := parseCapturedBlocks(buf.Bytes())
+		_, packet, _ := capturedPacket(blocks[2])
+		Expect(packet[0] >> 4).To(Equal(byte(4)))
+		Expect(net.IP(packet[12:16]).Equal(net.IPv4zero)).To(BeTrue())
+		_, packet, _ = capturedPacket(blocks[3])
+		Expect(packet[0] >> 4).To(Equal(byte(6)))
+		Expect(binary.BigEndian.Uint16(packet[4:6])).To(Equal(uint16(8 + 3)))
+		Expect(net.IP(packet[24:40]).Equal(net.ParseIP("2001:db8::1"))).To(BeTrue())
+	})
+
+	It("stops capturing if writing fails", func() {
+		capture.start(errorWriter{})
+		Expect(capture.w).To(BeNil())
+		capture.sentPacket(0, local, remote, []byte("foo"))
+	})
+
+	It("captures the packets received by a pconnManager", func() {
+		pconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		pcm := &pconnManager{perspective: protocol.PerspectiveClient}
+		pcm.capture.start(buf)
+		Expect(pcm.setup(pconn, nil)).To(Succeed())
+		defer func() {
+			pcm.closeConns <- struct{}{}
+			Eventually(pcm.closed).Should(BeClosed())
+		}()
+
+		sender, err := net.DialUDP("udp", nil, pconn.LocalAddr().(*net.UDPAddr))
+		Expect(err).ToNot(HaveOccurred())
+		defer sender.Close()
+		_, err = sender.Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(pcm.rcvRawPackets).Should(Receive())
+
+		pcm.capture.mutex.Lock()
+		blocks := parseCapturedBlocks(buf.Bytes())
+		pcm.capture.mutex.Unlock()
+		Expect(blocks).To(HaveLen(3))
+		_, packet, _ := capturedPacket(blocks[2])
+		Expect(packet[28:]).To(Equal([]byte("foobar")))
+		Expect(binary.BigEndian.Uint16(packet[20:22])).To(Equal(uint16(sender.LocalAddr().(*net.UDPAddr).Port)))
+	})
+})
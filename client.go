@@ -107,6 +107,11 @@ func DialNonFWSecure(
 		}
 	}
 
+	clientConfig := populateClientConfig(config)
+	if err = validateConfig(clientConfig); err != nil {
+		return nil, err
+	}
+
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
@@ -118,8 +123,10 @@ func DialNonFWSecure(
 	} else {
 		pconnMgr = pconnMgrArg
 	}
+	if clientConfig.PacketCapture != nil {
+		pconnMgr.capture.start(clientConfig.PacketCapture)
+	}
 
-	clientConfig := populateClientConfig(config)
 	c := &client{
 		pconnMgr:               pconnMgr,
 		connectionID:           connID,
@@ -188,6 +195,18 @@ func populateClientConfig(config *Config) *Config {
 	if maxReceiveConnectionFlowControlWindow == 0 {
 		maxReceiveConnectionFlowControlWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowClient
 	}
+	scheduler := config.Scheduler
+	if scheduler == "" {
+		scheduler = defaultScheduler()
+	}
+	congestionControl := config.CongestionControl
+	if congestionControl == "" {
+		congestionControl = defaultCongestionControl()
+	}
+	metricsSink := config.MetricsSink
+	if metricsSink == nil {
+		metricsSink = defaultMetricsSink()
+	}
 
 	return &Config{
 		Versions:                              versions,
@@ -199,6 +218,15 @@ func populateClientConfig(config *Config) *Config {
 		KeepAlive:                             config.KeepAlive,
 		CacheHandshake:                        config.CacheHandshake,
 		CreatePaths:                           config.CreatePaths,
+		Scheduler:                             scheduler,
+		CongestionControl:                     congestionControl,
+		PathCongestionControl:                 config.PathCongestionControl,
+		MaxRedundancyRatio:                    config.MaxRedundancyRatio,
+		MaxRedundantBytesPerSecond:            config.MaxRedundantBytesPerSecond,
+		Tracer:                                config.Tracer,
+		MetricsSink:                           metricsSink,
+		KeyLogWriter:                          config.KeyLogWriter,
+		PacketCapture:                         config.PacketCapture,
 	}
 }
 
@@ -0,0 +1,73 @@
+package congestion
+
+import (
+	"math"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// BaliaSender implements the Balanced Linked Adaptation (BALIA) algorithm by Peng et al., coupling the paths of a connection
+type BaliaSender struct {
+	coupledSender
+	baliaSenders map[protocol.PathID]*BaliaSender
+}
+
+// NewBaliaSender makes a new BALIA sender for the path and couples it with the baliaSenders of the other paths
+func NewBaliaSender(baliaSenders map[protocol.PathID]*BaliaSender, pathID protocol.PathID, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
+	b := &BaliaSender{baliaSenders: baliaSenders}
+	b.coupledSender = newCoupledSender(b, pathID, rttStats, initialCongestionWindow, initialMaxCongestionWindow)
+	baliaSenders[pathID] = b
+	return b
+}
+
+var _ CoupledSendAlgorithm = &BaliaSender{}
+
+// Decouple removes the sender from the baliaSenders
+func (b *BaliaSender) Decouple() {
+	delete(b.baliaSenders, b.pathID)
+}
+
+// rates returns the rate cwnd / rtt of the path, the maximum and the sum of the rates of all paths.
+// Paths without RTT estimate are ignored.
+func (b *BaliaSender) rates() (rate, maxRate, sumRates float64) {
+	for _, bs := range b.baliaSenders {
+		rtt := bs.rttStats.SmoothedRTT().Seconds()
+		if rtt == 0 {
+			continue
+		}
+		pathRate := float64(bs.congestionWindow) / rtt
+		if bs == b {
+			rate = pathRate
+		}
+		maxRate = math.Max(maxRate, pathRate)
+		sumRates += pathRate
+	}
+	return
+}
+
+// congestionWindowIncreasePerAck returns the increase of the congestion window in congestion avoidance, in packets per acked packet.
+// BALIA increases by (rate / rtt) / sum(rates)^2 * (1 + alpha) / 2 * (4 + alpha) / 5, with alpha = maxRate / rate
+func (b *BaliaSender) congestionWindowIncreasePerAck() float64 {
+	rate, maxRate, sumRates := b.rates()
+	if rate == 0 {
+		// Without RTT estimate, behave like Reno
+		return 1 / float64(b.congestionWindow)
+	}
+	alpha := maxRate / rate
+	return rate / b.rttStats.SmoothedRTT().Seconds() / (sumRates * sumRates) * (1 + alpha) / 2 * (4 + alpha) / 5
+}
+
+// decreaseFactor returns the factor the congestion window is multiplied with on a loss.
+// BALIA decreases by cwnd / 2 * min(alpha, 1.5)
+func (b *BaliaSender) decreaseFactor() float64 {
+	rate, maxRate, _ := b.rates()
+	if rate == 0 {
+		return float64(b.RenoBeta())
+	}
+	return 1 - math.Min(maxRate/rate, 1.5)/2
+}
+
+// congestionWindowAfterLoss returns the congestion window after a loss event
+func (b *BaliaSender) congestionWindowAfterLoss() protocol.PacketNumber {
+	return protocol.PacketNumber(float64(b.congestionWindow) * b.decreaseFactor())
+}
@@ -0,0 +1,55 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BALIA Sender", func() {
+	var (
+		baliaSenders map[protocol.PathID]*BaliaSender
+		rttStats1    *RTTStats
+		rttStats2    *RTTStats
+		sender1      *BaliaSender
+	)
+
+	BeforeEach(func() {
+		baliaSenders = make(map[protocol.PathID]*BaliaSender)
+		rttStats1 = NewRTTStats()
+		rttStats2 = NewRTTStats()
+		sender1 = NewBaliaSender(baliaSenders, 1, rttStats1, initialCongestionWindowPackets, MaxCongestionWindow).(*BaliaSender)
+		rttStats1.UpdateRTT(100*time.Millisecond, 0, time.Now())
+	})
+
+	It("couples the senders of the paths", func() {
+		sender2 := NewBaliaSender(baliaSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow).(*BaliaSender)
+		Expect(baliaSenders).To(HaveLen(2))
+		sender2.Decouple()
+		Expect(baliaSenders).To(HaveLen(1))
+		Expect(baliaSenders).To(HaveKey(protocol.PathID(1)))
+	})
+
+	It("behaves like Reno on a single path", func() {
+		Expect(sender1.congestionWindowIncreasePerAck()).To(BeNumerically("~", 1/float64(initialCongestionWindowPackets), 1e-9))
+		Expect(sender1.decreaseFactor()).To(BeNumerically("~", 0.5, 1e-9))
+	})
+
+	It("shares the increase between paths with the same rate", func() {
+		NewBaliaSender(baliaSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow)
+		rttStats2.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		Expect(sender1.congestionWindowIncreasePerAck()).To(BeNumerically("~", 1/float64(4*initialCongestionWindowPackets), 1e-9))
+		Expect(sender1.decreaseFactor()).To(BeNumerically("~", 0.5, 1e-9))
+	})
+
+	It("decreases the slower path more on losses", func() {
+		sender2 := NewBaliaSender(baliaSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow).(*BaliaSender)
+		rttStats2.UpdateRTT(300*time.Millisecond, 0, time.Now())
+		Expect(sender1.decreaseFactor()).To(BeNumerically("~", 0.5, 1e-9))
+		Expect(sender2.decreaseFactor()).To(BeNumerically("~", 0.25, 1e-9))
+		sender2.OnPacketLost(1, protocol.DefaultTCPMSS, defaultWindowTCP)
+		Expect(sender2.congestionWindow).To(Equal(initialCongestionWindowPackets / 4))
+	})
+})
@@ -0,0 +1,73 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// sentPacketState is the state of the connection at the time a packet was sent
+type sentPacketState struct {
+	bytes protocol.ByteCount
+	// Total number of bytes delivered when the packet was sent
+	totalBytesDeliveredAtSent protocol.ByteCount
+	// Time of the last delivery when the packet was sent
+	lastDeliveredTimeAtSent time.Time
+}
+
+// bandwidthSample is the delivery rate measured when a packet is acked
+type bandwidthSample struct {
+	bandwidth Bandwidth
+	// Total number of bytes delivered when the acked packet was sent
+	totalBytesDeliveredAtSent protocol.ByteCount
+}
+
+// bandwidthSampler measures the delivery rate of the acked packets
+type bandwidthSampler struct {
+	totalBytesDelivered protocol.ByteCount
+	lastDeliveredTime   time.Time
+
+	sentPackets map[protocol.PacketNumber]sentPacketState
+}
+
+func newBandwidthSampler() bandwidthSampler {
+	return bandwidthSampler{sentPackets: make(map[protocol.PacketNumber]sentPacketState)}
+}
+
+// OnPacketSent records the state of the connection when a retransmittable packet is sent.
+// bytesInFlight includes the packet.
+func (s *bandwidthSampler) OnPacketSent(sentTime time.Time, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	// When nothing was in flight, the delivery rate must not include the idle time
+	if bytesInFlight <= bytes || s.lastDeliveredTime.IsZero() {
+		s.lastDeliveredTime = sentTime
+	}
+	s.sentPackets[packetNumber] = sentPacketState{
+		bytes:                     bytes,
+		totalBytesDeliveredAtSent: s.totalBytesDelivered,
+		lastDeliveredTimeAtSent:   s.lastDeliveredTime,
+	}
+}
+
+// OnPacketAcked returns the delivery rate measured since the packet was sent.
+// It returns false if the packet isn't tracked.
+func (s *bandwidthSampler) OnPacketAcked(ackTime time.Time, packetNumber protocol.PacketNumber) (bandwidthSample, bool) {
+	state, ok := s.sentPackets[packetNumber]
+	if !ok {
+		return bandwidthSample{}, false
+	}
+	delete(s.sentPackets, packetNumber)
+
+	s.totalBytesDelivered += state.bytes
+	s.lastDeliveredTime = ackTime
+
+	sample := bandwidthSample{totalBytesDeliveredAtSent: state.totalBytesDeliveredAtSent}
+	if interval := ackTime.Sub(state.lastDeliveredTimeAtSent); interval > 0 {
+		sample.bandwidth = BandwidthFromDelta(s.totalBytesDelivered-state.totalBytesDeliveredAtSent, interval)
+	}
+	return sample, true
+}
+
+// OnPacketLost stops tracking a lost packet
+func (s *bandwidthSampler) OnPacketLost(packetNumber protocol.PacketNumber) {
+	delete(s.sentPackets, packetNumber)
+}
@@ -0,0 +1,370 @@
+package congestion
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// BBR (v1) congestion control, see https://tools.ietf.org/html/draft-cardwell-iccrg-bbr-congestion-control-00
+// It builds a model of the path from the measured bottleneck bandwidth and minimum RTT,
+// and doesn't reduce its sending rate on losses, which makes it suitable for links with random losses.
+
+type bbrMode int
+
+const (
+	// Grow the sending rate exponentially until the bandwidth stops growing
+	bbrStartup bbrMode = iota
+	// Drain the queue built up during startup
+	bbrDrain
+	// Cycle the pacing gain to probe for more bandwidth
+	bbrProbeBandwidth
+	// Reduce the congestion window to measure the minimum RTT
+	bbrProbeRTT
+)
+
+const (
+	// 2/ln(2), the smallest gain allowing to double the sending rate every round trip
+	bbrHighGain  = 2.885
+	bbrDrainGain = 1 / bbrHighGain
+	// Gain of the congestion window in ProbeBandwidth mode
+	bbrCongestionWindowGain = 2.0
+	// Length of the window of the bandwidth filter, in round trips
+	bbrBandwidthWindowSize = 10
+	// Growth of the bandwidth required to stay in startup, and the number of round trips without it
+	bbrStartupGrowthTarget                         = 1.25
+	bbrRoundTripsWithoutGrowthBeforeExitingStartup = 3
+	// Time after which the minimum RTT is measured again
+	bbrMinRTTExpiry = 10 * time.Second
+	// Time spent in ProbeRTT mode
+	bbrProbeRTTTime = 200 * time.Millisecond
+	// Minimum congestion window in packets
+	bbrMinCongestionWindow protocol.PacketNumber = 4
+	// RTT used to calculate the initial pacing rate before an RTT was measured
+	bbrInitialRTT = 100 * time.Millisecond
+)
+
+// Pacing gains of the phases in ProbeBandwidth mode
+var bbrPacingGainCycle = []float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+// BBRSender implements BBR congestion control
+type BBRSender struct {
+	clock    Clock
+	rttStats *RTTStats
+	sampler  bandwidthSampler
+
+	mode bbrMode
+
+	maxBandwidth    maxBandwidthFilter
+	minRTT          time.Duration
+	minRTTTimestamp time.Time
+
+	// Round trips are counted by the bytes delivered
+	roundTripCount     uint64
+	nextRoundDelivered protocol.ByteCount
+	roundStart         bool
+
+	pacingGain           float64
+	congestionWindowGain float64
+
+	// Phase of the ProbeBandwidth gain cycle
+	cycleIndex int
+	cycleStart time.Time
+
+	// Used to detect that the bandwidth stopped growing in startup
+	fullBandwidth           Bandwidth
+	roundTripsWithoutGrowth int
+	fullBandwidthReached    bool
+
+	// Whether a packet was lost since the last ACK, used to stop probing for bandwidth
+	lostSinceLastAck bool
+
+	probeRTTDoneTime    time.Time
+	probeRTTRoundPassed bool
+
+	// Congestion window in bytes
+	congestionWindow        protocol.ByteCount
+	initialCongestionWindow protocol.ByteCount
+	maxCongestionWindow     protocol.ByteCount
+	minCongestionWindow     protocol.ByteCount
+}
+
+var _ PacingSendAlgorithm = &BBRSender{}
+
+// NewBBRSender makes a new BBR sender
+func NewBBRSender(clock Clock, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) *BBRSender {
+	b := &BBRSender{
+		clock:                   clock,
+		rttStats:                rttStats,
+		initialCongestionWindow: protocol.ByteCount(initialCongestionWindow) * protocol.DefaultTCPMSS,
+		maxCongestionWindow:     protocol.ByteCount(initialMaxCongestionWindow) * protocol.DefaultTCPMSS,
+		minCongestionWindow:     protocol.ByteCount(bbrMinCongestionWindow) * protocol.DefaultTCPMSS,
+	}
+	b.reset()
+	return b
+}
+
+func (b *BBRSender) reset() {
+	b.sampler = newBandwidthSampler()
+	b.maxBandwidth = newMaxBandwidthFilter(bbrBandwidthWindowSize)
+	b.minRTT = 0
+	b.minRTTTimestamp = time.Time{}
+	b.roundTripCount = 0
+	b.nextRoundDelivered = 0
+	b.roundStart = false
+	b.fullBandwidth = 0
+	b.roundTripsWithoutGrowth = 0
+	b.fullBandwidthReached = false
+	b.lostSinceLastAck = false
+	b.congestionWindow = b.initialCongestionWindow
+	b.enterStartupMode()
+}
+
+// TimeUntilSend returns when the next packet may be sent according to the congestion window.
+// Packets are paced by the pacer of the path, at the PacingRate.
+func (b *BBRSender) TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration {
+	if bytesInFlight >= b.GetCongestionWindow() {
+		return utils.InfDuration
+	}
+	return 0
+}
+
+// OnPacketSent is called when a packet is sent
+func (b *BBRSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
+	if !isRetransmittable {
+		return false
+	}
+	b.sampler.OnPacketSent(sentTime, packetNumber, bytes, bytesInFlight)
+	return true
+}
+
+// GetCongestionWindow returns the congestion window in bytes
+func (b *BBRSender) GetCongestionWindow() protocol.ByteCount {
+	if b.mode == bbrProbeRTT {
+		return utils.MinByteCount(b.congestionWindow, b.minCongestionWindow)
+	}
+	return b.congestionWindow
+}
+
+// MaybeExitSlowStart does nothing, BBR leaves startup when the bandwidth stops growing
+func (b *BBRSender) MaybeExitSlowStart() {}
+
+// OnPacketAcked updates the model of the path with the delivery rate of the packet
+func (b *BBRSender) OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	now := b.clock.Now()
+	sample, ok := b.sampler.OnPacketAcked(now, number)
+	if !ok {
+		return
+	}
+
+	b.roundStart = false
+	if sample.totalBytesDeliveredAtSent >= b.nextRoundDelivered {
+		b.roundTripCount++
+		b.nextRoundDelivered = b.sampler.totalBytesDelivered
+		b.roundStart = true
+	}
+	if sample.bandwidth > 0 {
+		b.maxBandwidth.Update(sample.bandwidth, b.roundTripCount)
+	}
+	minRTTExpired := b.updateMinRTT(now)
+
+	if b.mode == bbrProbeBandwidth {
+		b.updateGainCycle(now, bytesInFlight+ackedBytes)
+	}
+	if b.roundStart && !b.fullBandwidthReached {
+		b.checkFullBandwidthReached()
+	}
+	b.maybeExitStartupOrDrain(now, bytesInFlight)
+	b.maybeEnterOrExitProbeRTT(now, minRTTExpired, bytesInFlight)
+	b.updateCongestionWindow(ackedBytes)
+	b.lostSinceLastAck = false
+}
+
+// OnPacketLost is called when a packet is lost.
+// BBR doesn't reduce its congestion window on losses.
+func (b *BBRSender) OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	b.sampler.OnPacketLost(number)
+	b.lostSinceLastAck = true
+}
+
+// SetNumEmulatedConnections does nothing, BBR doesn't emulate multiple connections
+func (b *BBRSender) SetNumEmulatedConnections(n int) {}
+
+// OnRetransmissionTimeout is called on a retransmission timeout.
+// The model of the path is kept, the lost packets are reported with OnPacketLost.
+func (b *BBRSender) OnRetransmissionTimeout(packetsRetransmitted bool) {}
+
+// OnConnectionMigration resets the model of the path
+func (b *BBRSender) OnConnectionMigration() {
+	b.reset()
+}
+
+// RetransmissionDelay gives the time to retransmission
+func (b *BBRSender) RetransmissionDelay() time.Duration {
+	if b.rttStats.SmoothedRTT() == 0 {
+		return 0
+	}
+	return b.rttStats.SmoothedRTT() + b.rttStats.MeanDeviation()*4
+}
+
+// SmoothedRTT returns the smoothed RTT
+func (b *BBRSender) SmoothedRTT() time.Duration {
+	return b.rttStats.SmoothedRTT()
+}
+
+// SetSlowStartLargeReduction does nothing, BBR has no slow start
+func (b *BBRSender) SetSlowStartLargeReduction(enabled bool) {}
+
+// BandwidthEstimate returns the estimated bottleneck bandwidth
+func (b *BBRSender) BandwidthEstimate() Bandwidth {
+	return b.maxBandwidth.Get()
+}
+
+// PacingRate returns the rate at which packets are sent
+func (b *BBRSender) PacingRate() Bandwidth {
+	bandwidth := b.maxBandwidth.Get()
+	if bandwidth == 0 {
+		// Pace the initial congestion window at the high gain
+		rtt := b.rttStats.SmoothedRTT()
+		if rtt == 0 {
+			rtt = bbrInitialRTT
+		}
+		return Bandwidth(bbrHighGain * float64(BandwidthFromDelta(b.initialCongestionWindow, rtt)))
+	}
+	return Bandwidth(b.pacingGain * float64(bandwidth))
+}
+
+// InSlowStart returns true if BBR is in startup mode
+func (b *BBRSender) InSlowStart() bool {
+	return b.mode == bbrStartup
+}
+
+// updateMinRTT updates the minimum RTT with the latest RTT sample.
+// It returns true if the minimum RTT expired.
+func (b *BBRSender) updateMinRTT(now time.Time) bool {
+	expired := !b.minRTTTimestamp.IsZero() && now.Sub(b.minRTTTimestamp) > bbrMinRTTExpiry
+	rtt := b.rttStats.LatestRTT()
+	if rtt > 0 && (b.minRTT == 0 || rtt <= b.minRTT || expired) {
+		b.minRTT = rtt
+		b.minRTTTimestamp = now
+	}
+	return expired
+}
+
+func (b *BBRSender) updateGainCycle(now time.Time, priorInFlight protocol.ByteCount) {
+	// Each phase lasts about one minimum RTT
+	shouldAdvance := now.Sub(b.cycleStart) > b.minRTT
+	// Stay in the probing phase until the increased rate filled the pipe, or a loss occurs
+	if b.pacingGain > 1 && !b.lostSinceLastAck && priorInFlight < b.targetCongestionWindow(b.pacingGain) {
+		shouldAdvance = false
+	}
+	// Leave the draining phase as soon as the queue is drained
+	if b.pacingGain < 1 && priorInFlight <= b.targetCongestionWindow(1) {
+		shouldAdvance = true
+	}
+	if shouldAdvance {
+		b.cycleIndex = (b.cycleIndex + 1) % len(bbrPacingGainCycle)
+		b.cycleStart = now
+		b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+	}
+}
+
+func (b *BBRSender) checkFullBandwidthReached() {
+	bandwidth := b.maxBandwidth.Get()
+	if float64(bandwidth) >= float64(b.fullBandwidth)*bbrStartupGrowthTarget {
+		b.fullBandwidth = bandwidth
+		b.roundTripsWithoutGrowth = 0
+		return
+	}
+	b.roundTripsWithoutGrowth++
+	if b.roundTripsWithoutGrowth >= bbrRoundTripsWithoutGrowthBeforeExitingStartup {
+		b.fullBandwidthReached = true
+	}
+}
+
+func (b *BBRSender) maybeExitStartupOrDrain(now time.Time, bytesInFlight protocol.ByteCount) {
+	if b.mode == bbrStartup && b.fullBandwidthReached {
+		b.mode = bbrDrain
+		b.pacingGain = bbrDrainGain
+		b.congestionWindowGain = bbrHighGain
+	}
+	if b.mode == bbrDrain && bytesInFlight <= b.targetCongestionWindow(1) {
+		b.enterProbeBandwidthMode(now)
+	}
+}
+
+func (b *BBRSender) maybeEnterOrExitProbeRTT(now time.Time, minRTTExpired bool, bytesInFlight protocol.ByteCount) {
+	if minRTTExpired && b.mode != bbrProbeRTT {
+		b.mode = bbrProbeRTT
+		b.pacingGain = 1
+		b.probeRTTDoneTime = time.Time{}
+	}
+	if b.mode != bbrProbeRTT {
+		return
+	}
+
+	if b.probeRTTDoneTime.IsZero() {
+		// Wait until the queue is drained to the minimum congestion window
+		if bytesInFlight <= b.minCongestionWindow {
+			b.probeRTTDoneTime = now.Add(bbrProbeRTTTime)
+			b.probeRTTRoundPassed = false
+			b.nextRoundDelivered = b.sampler.totalBytesDelivered
+		}
+		return
+	}
+	if b.roundStart {
+		b.probeRTTRoundPassed = true
+	}
+	if b.probeRTTRoundPassed && !now.Before(b.probeRTTDoneTime) {
+		b.minRTTTimestamp = now
+		if b.fullBandwidthReached {
+			b.enterProbeBandwidthMode(now)
+		} else {
+			b.enterStartupMode()
+		}
+	}
+}
+
+func (b *BBRSender) enterStartupMode() {
+	b.mode = bbrStartup
+	b.pacingGain = bbrHighGain
+	b.congestionWindowGain = bbrHighGain
+}
+
+func (b *BBRSender) enterProbeBandwidthMode(now time.Time) {
+	b.mode = bbrProbeBandwidth
+	b.congestionWindowGain = bbrCongestionWindowGain
+	// Start in a random phase, but not in the draining one
+	b.cycleIndex = rand.Intn(len(bbrPacingGainCycle) - 1)
+	if b.cycleIndex >= 1 {
+		b.cycleIndex++
+	}
+	b.cycleStart = now
+	b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+}
+
+func (b *BBRSender) updateCongestionWindow(ackedBytes protocol.ByteCount) {
+	if b.mode == bbrProbeRTT {
+		return
+	}
+	target := b.targetCongestionWindow(b.congestionWindowGain)
+	if b.fullBandwidthReached {
+		b.congestionWindow = utils.MinByteCount(target, b.congestionWindow+ackedBytes)
+	} else if b.congestionWindow < target || b.sampler.totalBytesDelivered < b.initialCongestionWindow {
+		b.congestionWindow += ackedBytes
+	}
+	b.congestionWindow = utils.MaxByteCount(b.congestionWindow, b.minCongestionWindow)
+	b.congestionWindow = utils.MinByteCount(b.congestionWindow, b.maxCongestionWindow)
+}
+
+// targetCongestionWindow returns the bandwidth-delay product multiplied by the gain
+func (b *BBRSender) targetCongestionWindow(gain float64) protocol.ByteCount {
+	bandwidth := b.maxBandwidth.Get()
+	if bandwidth == 0 || b.minRTT == 0 {
+		return protocol.ByteCount(gain * float64(b.initialCongestionWindow))
+	}
+	target := protocol.ByteCount(gain * float64(bytesFromBandwidth(bandwidth, b.minRTT)))
+	return utils.MaxByteCount(target, b.minCongestionWindow)
+}
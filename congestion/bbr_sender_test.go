@@ -0,0 +1,131 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BBR Sender", func() {
+	const rtt = 100 * time.Millisecond
+
+	var (
+		sender        *BBRSender
+		clock         mockClock
+		rttStats      *RTTStats
+		bytesInFlight protocol.ByteCount
+		packetNumber  protocol.PacketNumber
+	)
+
+	BeforeEach(func() {
+		clock = mockClock{}
+		clock.Advance(time.Hour)
+		rttStats = NewRTTStats()
+		bytesInFlight = 0
+		packetNumber = 1
+		sender = NewBBRSender(&clock, rttStats, initialCongestionWindowPackets, MaxCongestionWindow)
+	})
+
+	sendPackets := func(n int) []protocol.PacketNumber {
+		var sent []protocol.PacketNumber
+		for i := 0; i < n; i++ {
+			bytesInFlight += protocol.DefaultTCPMSS
+			sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+			sent = append(sent, packetNumber)
+			packetNumber++
+		}
+		return sent
+	}
+
+	ackPackets := func(packetNumbers []protocol.PacketNumber) {
+		rttStats.UpdateRTT(rtt, 0, clock.Now())
+		for _, pn := range packetNumbers {
+			bytesInFlight -= protocol.DefaultTCPMSS
+			sender.OnPacketAcked(pn, protocol.DefaultTCPMSS, bytesInFlight)
+		}
+	}
+
+	// sendRoundTrips sends n packets and acks them one RTT later, for a number of round trips
+	sendRoundTrips := func(rounds, n int) {
+		for i := 0; i < rounds; i++ {
+			sent := sendPackets(n)
+			clock.Advance(rtt)
+			ackPackets(sent)
+		}
+	}
+
+	It("starts in startup with the initial congestion window", func() {
+		Expect(sender.InSlowStart()).To(BeTrue())
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+		Expect(sender.PacingRate()).ToNot(BeZero())
+	})
+
+	It("doesn't send more than the congestion window", func() {
+		Expect(sender.TimeUntilSend(clock.Now(), 0)).To(BeZero())
+		Expect(sender.TimeUntilSend(clock.Now(), defaultWindowTCP)).To(Equal(utils.InfDuration))
+	})
+
+	It("paces at the high gain in startup", func() {
+		sendRoundTrips(1, 10)
+		Expect(sender.PacingRate()).To(Equal(Bandwidth(bbrHighGain * float64(sender.BandwidthEstimate()))))
+	})
+
+	It("ignores non-retransmittable packets", func() {
+		Expect(sender.OnPacketSent(clock.Now(), 0, 1, protocol.DefaultTCPMSS, false)).To(BeFalse())
+		Expect(sender.sampler.sentPackets).To(BeEmpty())
+	})
+
+	It("estimates the bandwidth", func() {
+		sendRoundTrips(1, 10)
+		Expect(sender.BandwidthEstimate()).To(Equal(BandwidthFromDelta(10*protocol.DefaultTCPMSS, rtt)))
+	})
+
+	It("grows the congestion window in startup", func() {
+		sendRoundTrips(1, 10)
+		Expect(sender.GetCongestionWindow()).To(Equal(2 * defaultWindowTCP))
+	})
+
+	It("leaves startup when the bandwidth stops growing", func() {
+		sendRoundTrips(2, 10)
+		Expect(sender.InSlowStart()).To(BeTrue())
+		sendRoundTrips(3, 10)
+		Expect(sender.InSlowStart()).To(BeFalse())
+		Expect(sender.mode).To(Equal(bbrProbeBandwidth))
+		// The congestion window is twice the bandwidth-delay product
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("<=", 2*10*protocol.DefaultTCPMSS))
+	})
+
+	It("doesn't reduce the congestion window on losses", func() {
+		sendRoundTrips(1, 10)
+		congestionWindow := sender.GetCongestionWindow()
+		sent := sendPackets(10)
+		bytesInFlight -= protocol.DefaultTCPMSS
+		sender.OnPacketLost(sent[0], protocol.DefaultTCPMSS, bytesInFlight)
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.GetCongestionWindow()).To(Equal(congestionWindow))
+		Expect(sender.sampler.sentPackets).ToNot(HaveKey(sent[0]))
+	})
+
+	It("probes the RTT when the minimum RTT expired", func() {
+		sendRoundTrips(5, 10)
+		clock.Advance(bbrMinRTTExpiry)
+		sendRoundTrips(1, 10)
+		Expect(sender.mode).To(Equal(bbrProbeRTT))
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.ByteCount(bbrMinCongestionWindow) * protocol.DefaultTCPMSS))
+		// Stay in ProbeRTT for at least one round trip and bbrProbeRTTTime
+		sendRoundTrips(3, 2)
+		Expect(sender.mode).To(Equal(bbrProbeBandwidth))
+	})
+
+	It("resets on connection migration", func() {
+		sendRoundTrips(5, 10)
+		sender.OnConnectionMigration()
+		Expect(sender.InSlowStart()).To(BeTrue())
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+	})
+})
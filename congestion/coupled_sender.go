@@ -0,0 +1,285 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// coupledAlgorithm holds the increase and decrease of a coupled congestion control algorithm, e.g. LIA or BALIA
+type coupledAlgorithm interface {
+	// congestionWindowIncreasePerAck returns the increase of the congestion window in congestion avoidance, in packets per acked packet
+	congestionWindowIncreasePerAck() float64
+	// congestionWindowAfterLoss returns the congestion window after a loss event, in packets
+	congestionWindowAfterLoss() protocol.PacketNumber
+}
+
+// coupledSender implements slow start, recovery and the window accounting shared by the coupled senders.
+// The increase in congestion avoidance and the decrease on losses are delegated to the algorithm.
+type coupledSender struct {
+	hybridSlowStart HybridSlowStart
+	prr             PrrSender
+	rttStats        *RTTStats
+	stats           connectionStats
+	algorithm       coupledAlgorithm
+	pathID          protocol.PathID
+
+	// Track the largest packet that has been sent.
+	largestSentPacketNumber protocol.PacketNumber
+
+	// Track the largest packet that has been acked.
+	largestAckedPacketNumber protocol.PacketNumber
+
+	// Track the largest packet number outstanding when a CWND cutbacks occurs.
+	largestSentAtLastCutback protocol.PacketNumber
+
+	// Congestion window in packets.
+	congestionWindow protocol.PacketNumber
+
+	// Slow start congestion window in packets, aka ssthresh.
+	slowstartThreshold protocol.PacketNumber
+
+	// Whether the last loss event caused us to exit slowstart.
+	// Used for stats collection of slowstartPacketsLost
+	lastCutbackExitedSlowstart bool
+
+	// When true, texist slow start with large cutback of congestion window.
+	slowStartLargeReduction bool
+
+	// Minimum congestion window in packets.
+	minCongestionWindow protocol.PacketNumber
+
+	// Maximum number of outstanding packets for tcp.
+	maxTCPCongestionWindow protocol.PacketNumber
+
+	// Number of connections to simulate
+	numConnections int
+
+	// Fraction of a packet by which the congestion window was increased in congestion avoidance
+	congestionWindowIncrease float64
+
+	initialCongestionWindow    protocol.PacketNumber
+	initialMaxCongestionWindow protocol.PacketNumber
+}
+
+func newCoupledSender(algorithm coupledAlgorithm, pathID protocol.PathID, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) coupledSender {
+	return coupledSender{
+		algorithm:                  algorithm,
+		rttStats:                   rttStats,
+		initialCongestionWindow:    initialCongestionWindow,
+		initialMaxCongestionWindow: initialMaxCongestionWindow,
+		congestionWindow:           initialCongestionWindow,
+		minCongestionWindow:        defaultMinimumCongestionWindow,
+		slowstartThreshold:         initialMaxCongestionWindow,
+		maxTCPCongestionWindow:     initialMaxCongestionWindow,
+		numConnections:             defaultNumConnections,
+		pathID:                     pathID,
+	}
+}
+
+func (c *coupledSender) TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration {
+	if c.InRecovery() {
+		// PRR is used when in recovery.
+		return c.prr.TimeUntilSend(c.GetCongestionWindow(), bytesInFlight, c.GetSlowStartThreshold())
+	}
+	if c.GetCongestionWindow() > bytesInFlight {
+		return 0
+	}
+	return utils.InfDuration
+}
+
+func (c *coupledSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
+	// Only update bytesInFlight for data packets.
+	if !isRetransmittable {
+		return false
+	}
+	if c.InRecovery() {
+		// PRR is used when in recovery.
+		c.prr.OnPacketSent(bytes)
+	}
+	c.largestSentPacketNumber = packetNumber
+	c.hybridSlowStart.OnPacketSent(packetNumber)
+	return true
+}
+
+func (c *coupledSender) GetCongestionWindow() protocol.ByteCount {
+	return protocol.ByteCount(c.congestionWindow) * protocol.DefaultTCPMSS
+}
+
+func (c *coupledSender) GetSlowStartThreshold() protocol.ByteCount {
+	return protocol.ByteCount(c.slowstartThreshold) * protocol.DefaultTCPMSS
+}
+
+func (c *coupledSender) ExitSlowstart() {
+	c.slowstartThreshold = c.congestionWindow
+}
+
+func (c *coupledSender) MaybeExitSlowStart() {
+	if c.InSlowStart() && c.hybridSlowStart.ShouldExitSlowStart(c.rttStats.LatestRTT(), c.rttStats.MinRTT(), c.GetCongestionWindow()/protocol.DefaultTCPMSS) {
+		c.ExitSlowstart()
+	}
+}
+
+func (c *coupledSender) isCwndLimited(bytesInFlight protocol.ByteCount) bool {
+	congestionWindow := c.GetCongestionWindow()
+	if bytesInFlight >= congestionWindow {
+		return true
+	}
+	availableBytes := congestionWindow - bytesInFlight
+	slowStartLimited := c.InSlowStart() && bytesInFlight > congestionWindow/2
+	return slowStartLimited || availableBytes <= maxBurstBytes
+}
+
+func (c *coupledSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	// Do not increase the congestion window unless the sender is close to using
+	// the current window.
+	if !c.isCwndLimited(bytesInFlight) {
+		return
+	}
+	if c.congestionWindow >= c.maxTCPCongestionWindow {
+		return
+	}
+	if c.InSlowStart() {
+		// TCP slow start, exponential growth, increase by one for each ACK.
+		c.congestionWindow++
+		return
+	}
+	c.congestionWindowIncrease += c.algorithm.congestionWindowIncreasePerAck()
+	if c.congestionWindowIncrease >= 1 {
+		c.congestionWindow++
+		c.congestionWindowIncrease--
+	}
+}
+
+func (c *coupledSender) OnPacketAcked(ackedPacketNumber protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	c.largestAckedPacketNumber = utils.MaxPacketNumber(ackedPacketNumber, c.largestAckedPacketNumber)
+	if c.InRecovery() {
+		// PRR is used when in recovery
+		c.prr.OnPacketAcked(ackedBytes)
+		return
+	}
+	c.maybeIncreaseCwnd(ackedPacketNumber, ackedBytes, bytesInFlight)
+	if c.InSlowStart() {
+		c.hybridSlowStart.OnPacketAcked(ackedPacketNumber)
+	}
+}
+
+func (c *coupledSender) OnPacketLost(packetNumber protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	// TCP NewReno (RFC6582) says that once a loss occurs, any losses in packets
+	// already sent should be treated as a single loss event, since it's expected.
+	if packetNumber <= c.largestSentAtLastCutback {
+		if c.lastCutbackExitedSlowstart {
+			c.stats.slowstartPacketsLost++
+			c.stats.slowstartBytesLost += lostBytes
+			if c.slowStartLargeReduction {
+				if c.stats.slowstartPacketsLost == 1 || (c.stats.slowstartBytesLost/protocol.DefaultTCPMSS) > (c.stats.slowstartBytesLost-lostBytes)/protocol.DefaultTCPMSS {
+					// Reduce congestion window by 1 for every mss of bytes lost.
+					c.congestionWindow = utils.MaxPacketNumber(c.congestionWindow-1, c.minCongestionWindow)
+				}
+				c.slowstartThreshold = c.congestionWindow
+			}
+		}
+		return
+	}
+	c.lastCutbackExitedSlowstart = c.InSlowStart()
+	if c.InSlowStart() {
+		c.stats.slowstartPacketsLost++
+	}
+
+	c.prr.OnPacketLost(bytesInFlight)
+
+	// TODO(chromium): Separate out all of slow start into a separate class.
+	if c.slowStartLargeReduction && c.InSlowStart() {
+		c.congestionWindow = c.congestionWindow - 1
+	} else {
+		c.congestionWindow = c.algorithm.congestionWindowAfterLoss()
+	}
+	// Enforce a minimum congestion window.
+	if c.congestionWindow < c.minCongestionWindow {
+		c.congestionWindow = c.minCongestionWindow
+	}
+	c.slowstartThreshold = c.congestionWindow
+	c.largestSentAtLastCutback = c.largestSentPacketNumber
+	// reset packet count from congestion avoidance mode. We start
+	// counting again when we're out of recovery.
+	c.congestionWindowIncrease = 0
+}
+
+func (c *coupledSender) SetNumEmulatedConnections(n int) {
+	c.numConnections = utils.Max(n, 1)
+}
+
+// OnRetransmissionTimeout is called on an retransmission timeout
+func (c *coupledSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	c.largestSentAtLastCutback = 0
+	if !packetsRetransmitted {
+		return
+	}
+	c.hybridSlowStart.Restart()
+	c.slowstartThreshold = c.congestionWindow / 2
+	c.congestionWindow = c.minCongestionWindow
+}
+
+func (c *coupledSender) OnConnectionMigration() {
+	c.hybridSlowStart.Restart()
+	c.prr = PrrSender{}
+	c.largestSentPacketNumber = 0
+	c.largestAckedPacketNumber = 0
+	c.largestSentAtLastCutback = 0
+	c.lastCutbackExitedSlowstart = false
+	c.congestionWindowIncrease = 0
+	c.congestionWindow = c.initialCongestionWindow
+	c.slowstartThreshold = c.initialMaxCongestionWindow
+	c.maxTCPCongestionWindow = c.initialMaxCongestionWindow
+}
+
+// RetransmissionDelay gives the RTO retransmission time
+func (c *coupledSender) RetransmissionDelay() time.Duration {
+	if c.rttStats.SmoothedRTT() == 0 {
+		return 0
+	}
+	return c.rttStats.SmoothedRTT() + c.rttStats.MeanDeviation()*4
+}
+
+func (c *coupledSender) SmoothedRTT() time.Duration {
+	return c.rttStats.SmoothedRTT()
+}
+
+func (c *coupledSender) SetSlowStartLargeReduction(enabled bool) {
+	c.slowStartLargeReduction = enabled
+}
+
+func (c *coupledSender) BandwidthEstimate() Bandwidth {
+	srtt := c.rttStats.SmoothedRTT()
+	if srtt == 0 {
+		// If we haven't measured an rtt, the bandwidth estimate is unknown.
+		return 0
+	}
+	return BandwidthFromDelta(c.GetCongestionWindow(), srtt)
+}
+
+// HybridSlowStart returns the hybrid slow start instance for testing
+func (c *coupledSender) HybridSlowStart() *HybridSlowStart {
+	return &c.hybridSlowStart
+}
+
+func (c *coupledSender) SlowstartThreshold() protocol.PacketNumber {
+	return c.slowstartThreshold
+}
+
+func (c *coupledSender) RenoBeta() float32 {
+	// kNConnectionBeta is the backoff factor after loss for our N-connection
+	// emulation, which emulates the effective backoff of an ensemble of N
+	// TCP-Reno connections on a single loss event. The effective multiplier is
+	// computed as:
+	return (float32(c.numConnections) - 1. + renoBeta) / float32(c.numConnections)
+}
+
+func (c *coupledSender) InRecovery() bool {
+	return c.largestAckedPacketNumber <= c.largestSentAtLastCutback && c.largestAckedPacketNumber != 0
+}
+
+func (c *coupledSender) InSlowStart() bool {
+	return c.GetCongestionWindow() < c.GetSlowStartThreshold()
+}
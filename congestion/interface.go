@@ -38,22 +38,25 @@ type SendAlgorithmWithDebugInfo interface {
 	InSlowStart() bool
 }
 
-// SendAlgorithmVegas for vegas
-type SendAlgorithmVegas interface {
-	TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration
-	OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool
-	GetCongestionWindow() protocol.ByteCount
-	MaybeExitSlowStart()
-	OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount)
-	OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount)
-	SetNumEmulatedConnections(n int)
-	OnRetransmissionTimeout(packetsRetransmitted bool)
-	OnConnectionMigration()
-	RetransmissionDelay() time.Duration
-	SmoothedRTT() time.Duration
+// A CoupledSendAlgorithm is a SendAlgorithm whose congestion window is coupled
+// to the senders of the other paths of the same connection, e.g. OLIA
+type CoupledSendAlgorithm interface {
+	SendAlgorithm
+	// Decouple removes the sender from the coupled senders, e.g. when its path is closed
+	Decouple()
+}
+
+// A DelayBasedSendAlgorithm is a SendAlgorithm reacting to RTT changes rather than to losses, e.g. Vegas
+type DelayBasedSendAlgorithm interface {
+	SendAlgorithm
 	InRecovery() bool
 	InSlowStart() bool
+	// OnDuplicateAck is called when a duplicate or out-of-order ACK is received
+	OnDuplicateAck()
+}
 
-	// Experiments
-	SetSlowStartLargeReduction(enabled bool)
+// A PacingSendAlgorithm is a SendAlgorithm that sets the rate at which packets are paced, e.g. BBR
+type PacingSendAlgorithm interface {
+	SendAlgorithm
+	PacingRate() Bandwidth
 }
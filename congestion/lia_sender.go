@@ -0,0 +1,55 @@
+package congestion
+
+import (
+	"math"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// LiaSender implements the Linked Increases Algorithm (LIA) from RFC 6356, coupling the paths of a connection
+type LiaSender struct {
+	coupledSender
+	liaSenders map[protocol.PathID]*LiaSender
+}
+
+// NewLiaSender makes a new LIA sender for the path and couples it with the liaSenders of the other paths
+func NewLiaSender(liaSenders map[protocol.PathID]*LiaSender, pathID protocol.PathID, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
+	l := &LiaSender{liaSenders: liaSenders}
+	l.coupledSender = newCoupledSender(l, pathID, rttStats, initialCongestionWindow, initialMaxCongestionWindow)
+	liaSenders[pathID] = l
+	return l
+}
+
+var _ CoupledSendAlgorithm = &LiaSender{}
+
+// Decouple removes the sender from the liaSenders
+func (l *LiaSender) Decouple() {
+	delete(l.liaSenders, l.pathID)
+}
+
+// congestionWindowIncreasePerAck returns the increase of the congestion window in congestion avoidance, in packets per acked packet.
+// RFC 6356 increases by min(alpha / totalCwnd, 1 / cwnd), with alpha / totalCwnd = max(cwnd_i / rtt_i^2) / sum(cwnd_i / rtt_i)^2
+func (l *LiaSender) congestionWindowIncreasePerAck() float64 {
+	var maxCwndOverRTT2, sumCwndOverRTT float64
+	for _, ls := range l.liaSenders {
+		rtt := ls.rttStats.SmoothedRTT().Seconds()
+		if rtt == 0 {
+			continue
+		}
+		pathCwnd := float64(ls.congestionWindow)
+		maxCwndOverRTT2 = math.Max(maxCwndOverRTT2, pathCwnd/(rtt*rtt))
+		sumCwndOverRTT += pathCwnd / rtt
+	}
+	renoIncrease := 1 / float64(l.congestionWindow)
+	if sumCwndOverRTT == 0 {
+		// Without any RTT estimate, behave like Reno
+		return renoIncrease
+	}
+	return math.Min(maxCwndOverRTT2/(sumCwndOverRTT*sumCwndOverRTT), renoIncrease)
+}
+
+// congestionWindowAfterLoss returns the congestion window after a loss event.
+// LIA decreases like Reno.
+func (l *LiaSender) congestionWindowAfterLoss() protocol.PacketNumber {
+	return protocol.PacketNumber(float32(l.congestionWindow) * l.RenoBeta())
+}
@@ -0,0 +1,60 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LIA Sender", func() {
+	var (
+		liaSenders map[protocol.PathID]*LiaSender
+		rttStats1  *RTTStats
+		rttStats2  *RTTStats
+		sender1    *LiaSender
+	)
+
+	BeforeEach(func() {
+		liaSenders = make(map[protocol.PathID]*LiaSender)
+		rttStats1 = NewRTTStats()
+		rttStats2 = NewRTTStats()
+		sender1 = NewLiaSender(liaSenders, 1, rttStats1, initialCongestionWindowPackets, MaxCongestionWindow).(*LiaSender)
+	})
+
+	It("couples the senders of the paths", func() {
+		sender2 := NewLiaSender(liaSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow).(*LiaSender)
+		Expect(liaSenders).To(HaveLen(2))
+		sender2.Decouple()
+		Expect(liaSenders).To(HaveLen(1))
+		Expect(liaSenders).To(HaveKey(protocol.PathID(1)))
+	})
+
+	It("increases like Reno without RTT estimate", func() {
+		Expect(sender1.congestionWindowIncreasePerAck()).To(Equal(1 / float64(initialCongestionWindowPackets)))
+	})
+
+	It("increases like Reno on a single path", func() {
+		rttStats1.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		Expect(sender1.congestionWindowIncreasePerAck()).To(BeNumerically("~", 1/float64(initialCongestionWindowPackets), 1e-9))
+	})
+
+	It("shares the increase between paths with the same RTT", func() {
+		NewLiaSender(liaSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow)
+		rttStats1.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		rttStats2.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		Expect(sender1.congestionWindowIncreasePerAck()).To(BeNumerically("~", 1/float64(4*initialCongestionWindowPackets), 1e-9))
+	})
+
+	It("increases the congestion window once the increases sum up to a packet", func() {
+		sender1.congestionWindow = 8
+		sender1.slowstartThreshold = 8
+		for i := 0; i < 7; i++ {
+			sender1.maybeIncreaseCwnd(protocol.PacketNumber(i), protocol.DefaultTCPMSS, 8*protocol.DefaultTCPMSS)
+		}
+		Expect(sender1.congestionWindow).To(Equal(protocol.PacketNumber(8)))
+		sender1.maybeIncreaseCwnd(8, protocol.DefaultTCPMSS, 8*protocol.DefaultTCPMSS)
+		Expect(sender1.congestionWindow).To(Equal(protocol.PacketNumber(9)))
+	})
+})
@@ -14,6 +14,7 @@ type OliaSender struct {
 	stats           connectionStats
 	olia            *Olia
 	oliaSenders     map[protocol.PathID]*OliaSender
+	pathID          protocol.PathID
 
 	// Track the largest packet that has been sent.
 	largestSentPacketNumber protocol.PacketNumber
@@ -53,8 +54,9 @@ type OliaSender struct {
 	initialMaxCongestionWindow protocol.PacketNumber
 }
 
-func NewOliaSender(oliaSenders map[protocol.PathID]*OliaSender, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
-	return &OliaSender{
+// NewOliaSender makes a new OLIA sender for the path and couples it with the oliaSenders of the other paths
+func NewOliaSender(oliaSenders map[protocol.PathID]*OliaSender, pathID protocol.PathID, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
+	o := &OliaSender{
 		rttStats:                   rttStats,
 		initialCongestionWindow:    initialCongestionWindow,
 		initialMaxCongestionWindow: initialMaxCongestionWindow,
@@ -65,7 +67,17 @@ func NewOliaSender(oliaSenders map[protocol.PathID]*OliaSender, rttStats *RTTSta
 		numConnections:             defaultNumConnections,
 		olia:                       NewOlia(0),
 		oliaSenders:                oliaSenders,
+		pathID:                     pathID,
 	}
+	oliaSenders[pathID] = o
+	return o
+}
+
+var _ CoupledSendAlgorithm = &OliaSender{}
+
+// Decouple removes the sender from the oliaSenders
+func (o *OliaSender) Decouple() {
+	delete(o.oliaSenders, o.pathID)
 }
 
 func (o *OliaSender) TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration {
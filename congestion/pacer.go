@@ -0,0 +1,79 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A Pacer spreads the packets sent over time, according to a pacing rate.
+// It uses a token bucket, allowing bursts of at most maxBurstBytes.
+type Pacer struct {
+	getPacingRate func() Bandwidth
+
+	budgetAtLastSent protocol.ByteCount
+	lastSentTime     time.Time
+}
+
+// NewPacer makes a new pacer, getPacingRate is called whenever the pacing rate is needed.
+// If the pacing rate is zero, packets are not paced.
+func NewPacer(getPacingRate func() Bandwidth) *Pacer {
+	return &Pacer{
+		getPacingRate:    getPacingRate,
+		budgetAtLastSent: maxBurstBytes,
+	}
+}
+
+// SentPacket consumes the budget used by a packet
+func (p *Pacer) SentPacket(sentTime time.Time, bytes protocol.ByteCount) {
+	budget := p.Budget(sentTime)
+	if bytes > budget {
+		p.budgetAtLastSent = 0
+	} else {
+		p.budgetAtLastSent = budget - bytes
+	}
+	p.lastSentTime = sentTime
+}
+
+// Budget returns the number of bytes that can be sent at the given time
+func (p *Pacer) Budget(now time.Time) protocol.ByteCount {
+	if p.lastSentTime.IsZero() {
+		return maxBurstBytes
+	}
+	rate := p.getPacingRate()
+	delta := now.Sub(p.lastSentTime)
+	if rate > 0 && delta >= timeToSendBytes(rate, maxBurstBytes) {
+		return maxBurstBytes
+	}
+	budget := p.budgetAtLastSent + bytesFromBandwidth(rate, delta)
+	return utils.MinByteCount(maxBurstBytes, budget)
+}
+
+// TimeUntilSend returns the time until a full sized packet can be sent
+func (p *Pacer) TimeUntilSend(now time.Time) time.Duration {
+	budget := p.Budget(now)
+	if budget >= protocol.DefaultTCPMSS {
+		return 0
+	}
+	rate := p.getPacingRate()
+	if rate == 0 {
+		// Without a pacing rate, don't pace
+		return 0
+	}
+	// Round up, such that the budget is sufficient once the time passed
+	return timeToSendBytes(rate, protocol.DefaultTCPMSS-budget) + time.Nanosecond
+}
+
+// bytesFromBandwidth calculates the number of bytes sent at a bandwidth during the time delta
+func bytesFromBandwidth(bandwidth Bandwidth, delta time.Duration) protocol.ByteCount {
+	if delta <= 0 {
+		return 0
+	}
+	return protocol.ByteCount((float64(bandwidth) / float64(BytesPerSecond)) * delta.Seconds())
+}
+
+// timeToSendBytes calculates the time it takes to send a number of bytes at a bandwidth
+func timeToSendBytes(bandwidth Bandwidth, bytes protocol.ByteCount) time.Duration {
+	return time.Duration(float64(bytes) / (float64(bandwidth) / float64(BytesPerSecond)) * float64(time.Second))
+}
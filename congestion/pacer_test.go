@@ -0,0 +1,56 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pacer", func() {
+	var (
+		p    *Pacer
+		rate Bandwidth
+		now  time.Time
+	)
+
+	BeforeEach(func() {
+		// one full sized packet per millisecond
+		rate = BandwidthFromDelta(protocol.DefaultTCPMSS, time.Millisecond)
+		p = NewPacer(func() Bandwidth { return rate })
+		now = time.Now()
+	})
+
+	It("allows a burst at the beginning", func() {
+		for i := 0; i < 3; i++ {
+			Expect(p.TimeUntilSend(now)).To(BeZero())
+			p.SentPacket(now, protocol.DefaultTCPMSS)
+		}
+		Expect(p.TimeUntilSend(now)).To(BeNumerically("~", time.Millisecond, time.Microsecond))
+	})
+
+	It("refills the budget at the pacing rate", func() {
+		for i := 0; i < 3; i++ {
+			p.SentPacket(now, protocol.DefaultTCPMSS)
+		}
+		now = now.Add(time.Millisecond / 2)
+		Expect(p.Budget(now)).To(Equal(protocol.DefaultTCPMSS / 2))
+		Expect(p.TimeUntilSend(now)).To(BeNumerically("~", time.Millisecond/2, time.Microsecond))
+		now = now.Add(time.Millisecond / 2)
+		Expect(p.TimeUntilSend(now)).To(BeZero())
+	})
+
+	It("doesn't accumulate more than a burst", func() {
+		p.SentPacket(now, protocol.DefaultTCPMSS)
+		Expect(p.Budget(now.Add(time.Hour))).To(Equal(maxBurstBytes))
+	})
+
+	It("doesn't pace without a rate", func() {
+		rate = 0
+		for i := 0; i < 5; i++ {
+			p.SentPacket(now, protocol.DefaultTCPMSS)
+		}
+		Expect(p.TimeUntilSend(now)).To(BeZero())
+	})
+})
@@ -186,7 +186,7 @@ func (r *RTTStats) SampleNewRecentMinRTT(numSamples uint32) {
 // OnConnectionMigration is called when connection migrates and rtt measurement needs to be reset.
 func (r *RTTStats) OnConnectionMigration() {
 	r.latestRTT = 0
-	r.minRTT = 60
+	r.minRTT = 0
 	r.smoothedRTT = 0
 	r.meanDeviation = 0
 	r.initialRTTus = initialRTTus
@@ -24,7 +24,6 @@ type VegasSender struct {
 	rttStats        *RTTStats
 	stats           connectionStats
 	vegas           *Vegas
-	vegasSenders    map[protocol.PathID]*VegasSender
 	noPRR           bool
 	reno            bool
 
@@ -70,7 +69,7 @@ type VegasSender struct {
 }
 
 // NewVegasSender help other packeges access this struct
-func NewVegasSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber, checkDup bool) SendAlgorithmVegas {
+func NewVegasSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) DelayBasedSendAlgorithm {
 	return &VegasSender{
 		rttStats:                   rttStats,
 		initialCongestionWindow:    initialCongestionWindow,
@@ -81,11 +80,17 @@ func NewVegasSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestio
 		maxTCPCongestionWindow:     initialMaxCongestionWindow,
 		numConnections:             defaultNumConnections,
 		vegas:                      NewVegas(0),
-		DupAck:                     checkDup,
 		reno:                       reno,
 	}
 }
 
+var _ DelayBasedSendAlgorithm = &VegasSender{}
+
+// OnDuplicateAck for vegas
+func (v *VegasSender) OnDuplicateAck() {
+	v.DupAck = true
+}
+
 // OnPacketSent for vegas
 func (v *VegasSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
 	if !isRetransmittable {
@@ -0,0 +1,72 @@
+package congestion
+
+// maxBandwidthFilter tracks the maximum bandwidth sample within a window of round trips.
+// It implements the windowed min/max estimator by Kathleen Nichols,
+// which keeps the best, second best and third best sample of the window.
+type maxBandwidthFilter struct {
+	// Length of the window in round trips
+	windowLength uint64
+	estimates    [3]bandwidthEstimate
+}
+
+type bandwidthEstimate struct {
+	bandwidth Bandwidth
+	round     uint64
+}
+
+func newMaxBandwidthFilter(windowLength uint64) maxBandwidthFilter {
+	return maxBandwidthFilter{windowLength: windowLength}
+}
+
+// Update adds a new sample taken in the given round trip
+func (f *maxBandwidthFilter) Update(bandwidth Bandwidth, round uint64) {
+	sample := bandwidthEstimate{bandwidth: bandwidth, round: round}
+
+	// Reset all estimates if there are none yet, if the sample is a new best,
+	// or if even the third best estimate is outside the window
+	if f.estimates[0].bandwidth == 0 || bandwidth >= f.estimates[0].bandwidth || round-f.estimates[2].round > f.windowLength {
+		f.Reset(bandwidth, round)
+		return
+	}
+
+	if bandwidth >= f.estimates[1].bandwidth {
+		f.estimates[1] = sample
+		f.estimates[2] = sample
+	} else if bandwidth >= f.estimates[2].bandwidth {
+		f.estimates[2] = sample
+	}
+
+	// Expire the best estimate if it's outside the window
+	if round-f.estimates[0].round > f.windowLength {
+		f.estimates[0] = f.estimates[1]
+		f.estimates[1] = f.estimates[2]
+		f.estimates[2] = sample
+		// The second best estimate might be outside the window as well
+		if round-f.estimates[0].round > f.windowLength {
+			f.estimates[0] = f.estimates[1]
+			f.estimates[1] = f.estimates[2]
+		}
+		return
+	}
+
+	// Make sure the second and third best estimates come from different quarters of the window
+	if f.estimates[1].bandwidth == f.estimates[0].bandwidth && round-f.estimates[1].round > f.windowLength/4 {
+		f.estimates[1] = sample
+		f.estimates[2] = sample
+		return
+	}
+	if f.estimates[2].bandwidth == f.estimates[1].bandwidth && round-f.estimates[2].round > f.windowLength/2 {
+		f.estimates[2] = sample
+	}
+}
+
+// Reset replaces all estimates by the sample
+func (f *maxBandwidthFilter) Reset(bandwidth Bandwidth, round uint64) {
+	sample := bandwidthEstimate{bandwidth: bandwidth, round: round}
+	f.estimates = [3]bandwidthEstimate{sample, sample, sample}
+}
+
+// Get returns the best estimate
+func (f *maxBandwidthFilter) Get() Bandwidth {
+	return f.estimates[0].bandwidth
+}
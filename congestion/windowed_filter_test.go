@@ -0,0 +1,42 @@
+package congestion
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Max bandwidth filter", func() {
+	var f maxBandwidthFilter
+
+	BeforeEach(func() {
+		f = newMaxBandwidthFilter(10)
+	})
+
+	It("is zero without samples", func() {
+		Expect(f.Get()).To(BeZero())
+	})
+
+	It("returns the maximum sample", func() {
+		f.Update(100, 1)
+		f.Update(300, 2)
+		f.Update(200, 3)
+		Expect(f.Get()).To(Equal(Bandwidth(300)))
+	})
+
+	It("expires samples outside the window", func() {
+		f.Update(300, 1)
+		f.Update(200, 5)
+		f.Update(100, 8)
+		Expect(f.Get()).To(Equal(Bandwidth(300)))
+		f.Update(100, 12)
+		Expect(f.Get()).To(Equal(Bandwidth(200)))
+		f.Update(100, 16)
+		Expect(f.Get()).To(Equal(Bandwidth(100)))
+	})
+
+	It("resets if all samples expired", func() {
+		f.Update(300, 1)
+		f.Update(50, 30)
+		Expect(f.Get()).To(Equal(Bandwidth(50)))
+	})
+})
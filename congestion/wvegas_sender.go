@@ -0,0 +1,381 @@
+package congestion
+
+import (
+	"math"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const (
+	// Sum of the alpha of all paths, in packets
+	wvegasTotalAlpha = 10
+	// Minimum alpha of a path, in packets
+	wvegasMinAlpha = 2
+	// Number of packets queued at which slow start is left
+	wvegasGamma = 1
+	// Minimum number of RTT samples in a round trip for the Vegas calculation
+	wvegasMinRTTSamples = 2
+)
+
+// WVegasSender implements weighted Vegas (wVegas) by Cao et al., coupling the paths of a connection.
+// Each path keeps alpha packets queued at its bottleneck, with alpha weighted by the share of the path in the total rate.
+// Traffic is thereby shifted away from paths with increasing queueing delays.
+type WVegasSender struct {
+	hybridSlowStart HybridSlowStart
+	prr             PrrSender
+	rttStats        *RTTStats
+	stats           connectionStats
+	wvegasSenders   map[protocol.PathID]*WVegasSender
+	pathID          protocol.PathID
+
+	// Track the largest packet that has been sent.
+	largestSentPacketNumber protocol.PacketNumber
+
+	// Track the largest packet that has been acked.
+	largestAckedPacketNumber protocol.PacketNumber
+
+	// Track the largest packet number outstanding when a CWND cutbacks occurs.
+	largestSentAtLastCutback protocol.PacketNumber
+
+	// Congestion window in packets.
+	congestionWindow protocol.PacketNumber
+
+	// Slow start congestion window in packets, aka ssthresh.
+	slowstartThreshold protocol.PacketNumber
+
+	// Whether the last loss event caused us to exit slowstart.
+	// Used for stats collection of slowstartPacketsLost
+	lastCutbackExitedSlowstart bool
+
+	// When true, texist slow start with large cutback of congestion window.
+	slowStartLargeReduction bool
+
+	// Minimum congestion window in packets.
+	minCongestionWindow protocol.PacketNumber
+
+	// Maximum number of outstanding packets for tcp.
+	maxTCPCongestionWindow protocol.PacketNumber
+
+	// Number of connections to simulate
+	numConnections int
+
+	// The congestion window is adapted once per round trip, when a packet sent after the end of the round trip is acked
+	roundEndPacketNumber protocol.PacketNumber
+	// Minimum RTT and number of RTT samples in the current round trip
+	roundMinRTT time.Duration
+	rttSamples  int
+	// Minimum queueing delay observed since the queue was last drained
+	queueDelay time.Duration
+
+	initialCongestionWindow    protocol.PacketNumber
+	initialMaxCongestionWindow protocol.PacketNumber
+}
+
+// NewWVegasSender makes a new wVegas sender for the path and couples it with the wvegasSenders of the other paths
+func NewWVegasSender(wvegasSenders map[protocol.PathID]*WVegasSender, pathID protocol.PathID, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
+	w := &WVegasSender{
+		rttStats:                   rttStats,
+		initialCongestionWindow:    initialCongestionWindow,
+		initialMaxCongestionWindow: initialMaxCongestionWindow,
+		congestionWindow:           initialCongestionWindow,
+		minCongestionWindow:        defaultMinimumCongestionWindow,
+		slowstartThreshold:         initialMaxCongestionWindow,
+		maxTCPCongestionWindow:     initialMaxCongestionWindow,
+		numConnections:             defaultNumConnections,
+		wvegasSenders:              wvegasSenders,
+		pathID:                     pathID,
+	}
+	wvegasSenders[pathID] = w
+	return w
+}
+
+var _ CoupledSendAlgorithm = &WVegasSender{}
+var _ DelayBasedSendAlgorithm = &WVegasSender{}
+
+// OnDuplicateAck does nothing, the congestion window only depends on the RTT samples of new ACKs
+func (w *WVegasSender) OnDuplicateAck() {}
+
+// Decouple removes the sender from the wvegasSenders
+func (w *WVegasSender) Decouple() {
+	delete(w.wvegasSenders, w.pathID)
+}
+
+func (w *WVegasSender) TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration {
+	if w.InRecovery() {
+		// PRR is used when in recovery.
+		return w.prr.TimeUntilSend(w.GetCongestionWindow(), bytesInFlight, w.GetSlowStartThreshold())
+	}
+	if w.GetCongestionWindow() > bytesInFlight {
+		return 0
+	}
+	return utils.InfDuration
+}
+
+func (w *WVegasSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
+	// Only update bytesInFlight for data packets.
+	if !isRetransmittable {
+		return false
+	}
+	if w.InRecovery() {
+		// PRR is used when in recovery.
+		w.prr.OnPacketSent(bytes)
+	}
+	w.largestSentPacketNumber = packetNumber
+	w.hybridSlowStart.OnPacketSent(packetNumber)
+	return true
+}
+
+func (w *WVegasSender) GetCongestionWindow() protocol.ByteCount {
+	return protocol.ByteCount(w.congestionWindow) * protocol.DefaultTCPMSS
+}
+
+func (w *WVegasSender) GetSlowStartThreshold() protocol.ByteCount {
+	return protocol.ByteCount(w.slowstartThreshold) * protocol.DefaultTCPMSS
+}
+
+func (w *WVegasSender) ExitSlowstart() {
+	w.slowstartThreshold = w.congestionWindow
+}
+
+func (w *WVegasSender) MaybeExitSlowStart() {
+	if w.InSlowStart() && w.hybridSlowStart.ShouldExitSlowStart(w.rttStats.LatestRTT(), w.rttStats.MinRTT(), w.GetCongestionWindow()/protocol.DefaultTCPMSS) {
+		w.ExitSlowstart()
+	}
+}
+
+func (w *WVegasSender) isCwndLimited(bytesInFlight protocol.ByteCount) bool {
+	congestionWindow := w.GetCongestionWindow()
+	if bytesInFlight >= congestionWindow {
+		return true
+	}
+	availableBytes := congestionWindow - bytesInFlight
+	slowStartLimited := w.InSlowStart() && bytesInFlight > congestionWindow/2
+	return slowStartLimited || availableBytes <= maxBurstBytes
+}
+
+// weight returns the share of the path in the total rate cwnd / rtt of the connection
+func (w *WVegasSender) weight() float64 {
+	var rate, sumRates float64
+	for _, ws := range w.wvegasSenders {
+		rtt := ws.rttStats.SmoothedRTT().Seconds()
+		if rtt == 0 {
+			continue
+		}
+		pathRate := float64(ws.congestionWindow) / rtt
+		if ws == w {
+			rate = pathRate
+		}
+		sumRates += pathRate
+	}
+	if sumRates == 0 {
+		return 1
+	}
+	return rate / sumRates
+}
+
+// alpha returns the number of packets the path keeps queued at its bottleneck
+func (w *WVegasSender) alpha() float64 {
+	return math.Max(wvegasMinAlpha, w.weight()*wvegasTotalAlpha)
+}
+
+func (w *WVegasSender) OnPacketAcked(ackedPacketNumber protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	w.largestAckedPacketNumber = utils.MaxPacketNumber(ackedPacketNumber, w.largestAckedPacketNumber)
+	if w.InRecovery() {
+		// PRR is used when in recovery
+		w.prr.OnPacketAcked(ackedBytes)
+		return
+	}
+
+	if rtt := w.rttStats.LatestRTT(); rtt > 0 {
+		if w.roundMinRTT == 0 || rtt < w.roundMinRTT {
+			w.roundMinRTT = rtt
+		}
+		w.rttSamples++
+	}
+
+	cwndLimited := w.isCwndLimited(bytesInFlight) && w.congestionWindow < w.maxTCPCongestionWindow
+	if cwndLimited && w.InSlowStart() {
+		// TCP slow start, exponential growth, increase by one for each ACK.
+		w.congestionWindow++
+		w.hybridSlowStart.OnPacketAcked(ackedPacketNumber)
+	}
+
+	if ackedPacketNumber > w.roundEndPacketNumber {
+		if cwndLimited {
+			w.adaptCongestionWindow()
+		}
+		w.roundEndPacketNumber = w.largestSentPacketNumber
+		w.roundMinRTT = 0
+		w.rttSamples = 0
+	}
+}
+
+// adaptCongestionWindow is called once per round trip.
+// It compares the number of packets queued at the bottleneck, estimated from the queueing delay, with alpha.
+func (w *WVegasSender) adaptCongestionWindow() {
+	rtt := w.roundMinRTT
+	baseRTT := w.rttStats.MinRTT()
+	if w.rttSamples <= wvegasMinRTTSamples || rtt == 0 || baseRTT == 0 {
+		// We don't have enough RTT samples to do the Vegas calculation, so we'll behave like Reno.
+		if !w.InSlowStart() {
+			w.congestionWindow++
+		}
+		return
+	}
+
+	// Number of packets queued at the bottleneck
+	diff := float64(w.congestionWindow) * float64(rtt-baseRTT) / float64(rtt)
+
+	if w.InSlowStart() {
+		if diff > wvegasGamma {
+			// Leave slow start, reducing the window to the rate actually achieved
+			targetCongestionWindow := protocol.PacketNumber(float64(w.congestionWindow) * float64(baseRTT) / float64(rtt))
+			w.congestionWindow = utils.MinPacketNumber(w.congestionWindow, targetCongestionWindow+1)
+			w.congestionWindow = utils.MaxPacketNumber(w.congestionWindow, w.minCongestionWindow)
+			w.slowstartThreshold = utils.MaxPacketNumber(w.congestionWindow-1, w.minCongestionWindow)
+		}
+		return
+	}
+
+	if diff >= w.alpha() {
+		w.congestionWindow--
+	} else {
+		w.congestionWindow++
+	}
+
+	// Drain the queue if the queueing delay doubled
+	queueDelay := rtt - baseRTT
+	if w.queueDelay == 0 || queueDelay < w.queueDelay {
+		w.queueDelay = queueDelay
+	}
+	if w.queueDelay > 0 && queueDelay >= 2*w.queueDelay {
+		w.congestionWindow = protocol.PacketNumber(float64(w.congestionWindow) * float64(baseRTT) / float64(2*rtt))
+		w.queueDelay = 0
+	}
+
+	w.congestionWindow = utils.MaxPacketNumber(w.congestionWindow, w.minCongestionWindow)
+	w.congestionWindow = utils.MinPacketNumber(w.congestionWindow, w.maxTCPCongestionWindow)
+}
+
+func (w *WVegasSender) OnPacketLost(packetNumber protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	// TCP NewReno (RFC6582) says that once a loss occurs, any losses in packets
+	// already sent should be treated as a single loss event, since it's expected.
+	if packetNumber <= w.largestSentAtLastCutback {
+		if w.lastCutbackExitedSlowstart {
+			w.stats.slowstartPacketsLost++
+			w.stats.slowstartBytesLost += lostBytes
+			if w.slowStartLargeReduction {
+				if w.stats.slowstartPacketsLost == 1 || (w.stats.slowstartBytesLost/protocol.DefaultTCPMSS) > (w.stats.slowstartBytesLost-lostBytes)/protocol.DefaultTCPMSS {
+					// Reduce congestion window by 1 for every mss of bytes lost.
+					w.congestionWindow = utils.MaxPacketNumber(w.congestionWindow-1, w.minCongestionWindow)
+				}
+				w.slowstartThreshold = w.congestionWindow
+			}
+		}
+		return
+	}
+	w.lastCutbackExitedSlowstart = w.InSlowStart()
+	if w.InSlowStart() {
+		w.stats.slowstartPacketsLost++
+	}
+
+	w.prr.OnPacketLost(bytesInFlight)
+
+	// TODO(chromium): Separate out all of slow start into a separate class.
+	if w.slowStartLargeReduction && w.InSlowStart() {
+		w.congestionWindow = w.congestionWindow - 1
+	} else {
+		w.congestionWindow = protocol.PacketNumber(float32(w.congestionWindow) * w.RenoBeta())
+	}
+	// Enforce a minimum congestion window.
+	if w.congestionWindow < w.minCongestionWindow {
+		w.congestionWindow = w.minCongestionWindow
+	}
+	w.slowstartThreshold = w.congestionWindow
+	w.largestSentAtLastCutback = w.largestSentPacketNumber
+	// The queue was drained by the loss
+	w.queueDelay = 0
+}
+
+func (w *WVegasSender) SetNumEmulatedConnections(n int) {
+	w.numConnections = utils.Max(n, 1)
+}
+
+// OnRetransmissionTimeout is called on an retransmission timeout
+func (w *WVegasSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	w.largestSentAtLastCutback = 0
+	if !packetsRetransmitted {
+		return
+	}
+	w.hybridSlowStart.Restart()
+	w.slowstartThreshold = w.congestionWindow / 2
+	w.congestionWindow = w.minCongestionWindow
+}
+
+func (w *WVegasSender) OnConnectionMigration() {
+	w.hybridSlowStart.Restart()
+	w.prr = PrrSender{}
+	w.largestSentPacketNumber = 0
+	w.largestAckedPacketNumber = 0
+	w.largestSentAtLastCutback = 0
+	w.lastCutbackExitedSlowstart = false
+	w.roundEndPacketNumber = 0
+	w.roundMinRTT = 0
+	w.rttSamples = 0
+	w.queueDelay = 0
+	w.congestionWindow = w.initialCongestionWindow
+	w.slowstartThreshold = w.initialMaxCongestionWindow
+	w.maxTCPCongestionWindow = w.initialMaxCongestionWindow
+}
+
+// RetransmissionDelay gives the RTO retransmission time
+func (w *WVegasSender) RetransmissionDelay() time.Duration {
+	if w.rttStats.SmoothedRTT() == 0 {
+		return 0
+	}
+	return w.rttStats.SmoothedRTT() + w.rttStats.MeanDeviation()*4
+}
+
+func (w *WVegasSender) SmoothedRTT() time.Duration {
+	return w.rttStats.SmoothedRTT()
+}
+
+func (w *WVegasSender) SetSlowStartLargeReduction(enabled bool) {
+	w.slowStartLargeReduction = enabled
+}
+
+func (w *WVegasSender) BandwidthEstimate() Bandwidth {
+	srtt := w.rttStats.SmoothedRTT()
+	if srtt == 0 {
+		// If we haven't measured an rtt, the bandwidth estimate is unknown.
+		return 0
+	}
+	return BandwidthFromDelta(w.GetCongestionWindow(), srtt)
+}
+
+// HybridSlowStart returns the hybrid slow start instance for testing
+func (w *WVegasSender) HybridSlowStart() *HybridSlowStart {
+	return &w.hybridSlowStart
+}
+
+func (w *WVegasSender) SlowstartThreshold() protocol.PacketNumber {
+	return w.slowstartThreshold
+}
+
+func (w *WVegasSender) RenoBeta() float32 {
+	// kNConnectionBeta is the backoff factor after loss for our N-connection
+	// emulation, which emulates the effective backoff of an ensemble of N
+	// TCP-Reno connections on a single loss event. The effective multiplier is
+	// computed as:
+	return (float32(w.numConnections) - 1. + renoBeta) / float32(w.numConnections)
+}
+
+func (w *WVegasSender) InRecovery() bool {
+	return w.largestAckedPacketNumber <= w.largestSentAtLastCutback && w.largestAckedPacketNumber != 0
+}
+
+func (w *WVegasSender) InSlowStart() bool {
+	return w.GetCongestionWindow() < w.GetSlowStartThreshold()
+}
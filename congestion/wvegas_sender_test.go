@@ -0,0 +1,101 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("wVegas Sender", func() {
+	var (
+		wvegasSenders map[protocol.PathID]*WVegasSender
+		rttStats1     *RTTStats
+		rttStats2     *RTTStats
+		sender1       *WVegasSender
+	)
+
+	BeforeEach(func() {
+		wvegasSenders = make(map[protocol.PathID]*WVegasSender)
+		rttStats1 = NewRTTStats()
+		rttStats2 = NewRTTStats()
+		sender1 = NewWVegasSender(wvegasSenders, 1, rttStats1, initialCongestionWindowPackets, MaxCongestionWindow).(*WVegasSender)
+		rttStats1.UpdateRTT(100*time.Millisecond, 0, time.Now())
+	})
+
+	// setRoundTrip sets the RTT samples of the current round trip
+	setRoundTrip := func(rtt time.Duration) {
+		sender1.roundMinRTT = rtt
+		sender1.rttSamples = wvegasMinRTTSamples + 1
+	}
+
+	It("couples the senders of the paths", func() {
+		sender2 := NewWVegasSender(wvegasSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow).(*WVegasSender)
+		Expect(wvegasSenders).To(HaveLen(2))
+		sender2.Decouple()
+		Expect(wvegasSenders).To(HaveLen(1))
+		Expect(wvegasSenders).To(HaveKey(protocol.PathID(1)))
+	})
+
+	It("weights alpha by the share of the path in the total rate", func() {
+		Expect(sender1.weight()).To(Equal(1.0))
+		Expect(sender1.alpha()).To(Equal(float64(wvegasTotalAlpha)))
+		sender2 := NewWVegasSender(wvegasSenders, 2, rttStats2, 3*initialCongestionWindowPackets, MaxCongestionWindow).(*WVegasSender)
+		rttStats2.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		Expect(sender1.weight()).To(BeNumerically("~", 0.25, 1e-9))
+		Expect(sender1.alpha()).To(BeNumerically("~", 2.5, 1e-9))
+		Expect(sender2.alpha()).To(BeNumerically("~", 7.5, 1e-9))
+	})
+
+	It("leaves slow start when packets get queued", func() {
+		Expect(sender1.InSlowStart()).To(BeTrue())
+		setRoundTrip(200 * time.Millisecond)
+		sender1.adaptCongestionWindow()
+		Expect(sender1.InSlowStart()).To(BeFalse())
+		Expect(sender1.congestionWindow).To(Equal(initialCongestionWindowPackets/2 + 1))
+	})
+
+	It("behaves like Reno without enough RTT samples", func() {
+		sender1.slowstartThreshold = initialCongestionWindowPackets
+		sender1.adaptCongestionWindow()
+		Expect(sender1.congestionWindow).To(Equal(initialCongestionWindowPackets + 1))
+	})
+
+	Context("in congestion avoidance", func() {
+		BeforeEach(func() {
+			sender1.slowstartThreshold = initialCongestionWindowPackets
+		})
+
+		It("increases the congestion window if less than alpha packets are queued", func() {
+			setRoundTrip(110 * time.Millisecond)
+			sender1.adaptCongestionWindow()
+			Expect(sender1.congestionWindow).To(Equal(initialCongestionWindowPackets + 1))
+		})
+
+		It("decreases the congestion window if alpha packets are queued", func() {
+			sender2 := NewWVegasSender(wvegasSenders, 2, rttStats2, initialCongestionWindowPackets, MaxCongestionWindow).(*WVegasSender)
+			rttStats2.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			sender2.slowstartThreshold = initialCongestionWindowPackets
+			// 10 * 50 / 150 = 3.3 packets queued, alpha is 5 for both paths
+			sender1.queueDelay = 50 * time.Millisecond
+			setRoundTrip(150 * time.Millisecond)
+			sender1.adaptCongestionWindow()
+			Expect(sender1.congestionWindow).To(Equal(initialCongestionWindowPackets + 1))
+			// With a smaller share of the rate, the path tolerates fewer queued packets
+			sender2.congestionWindow = 4 * initialCongestionWindowPackets
+			sender1.congestionWindow = initialCongestionWindowPackets
+			sender1.adaptCongestionWindow()
+			Expect(sender1.congestionWindow).To(Equal(initialCongestionWindowPackets - 1))
+		})
+
+		It("drains the queue if the queueing delay doubled", func() {
+			sender1.queueDelay = 10 * time.Millisecond
+			setRoundTrip(125 * time.Millisecond)
+			sender1.adaptCongestionWindow()
+			// 10 * 25 / 125 = 2 packets queued, the window is increased before draining
+			Expect(sender1.congestionWindow).To(Equal(protocol.PacketNumber(11 * 100 / 250)))
+			Expect(sender1.queueDelay).To(BeZero())
+		})
+	})
+})
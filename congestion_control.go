@@ -0,0 +1,106 @@
+package quic
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// congestionCoupling holds the senders of the paths of a session, such that coupled algorithms can share them
+type congestionCoupling struct {
+	oliaSenders   map[protocol.PathID]*congestion.OliaSender
+	liaSenders    map[protocol.PathID]*congestion.LiaSender
+	baliaSenders  map[protocol.PathID]*congestion.BaliaSender
+	wvegasSenders map[protocol.PathID]*congestion.WVegasSender
+}
+
+func newCongestionCoupling() *congestionCoupling {
+	return &congestionCoupling{
+		oliaSenders:   make(map[protocol.PathID]*congestion.OliaSender),
+		liaSenders:    make(map[protocol.PathID]*congestion.LiaSender),
+		baliaSenders:  make(map[protocol.PathID]*congestion.BaliaSender),
+		wvegasSenders: make(map[protocol.PathID]*congestion.WVegasSender),
+	}
+}
+
+// congestionControls create the congestion controller of a path for each algorithm that can be set in the Config.
+// The coupling may be nil if the session has no path manager.
+// If nil is returned, the path uses Cubic.
+var congestionControls = map[string]func(p *path, coupling *congestionCoupling) congestion.SendAlgorithm{
+	"cubic":  newCubicSender,
+	"olia":   newOliaSender,
+	"lia":    newLiaSender,
+	"balia":  newBaliaSender,
+	"vegas":  newVegasSender,
+	"wvegas": newWVegasSender,
+	"bbr":    newBBRSender,
+}
+
+func newCubicSender(p *path, _ *congestionCoupling) congestion.SendAlgorithm {
+	return congestion.NewCubicSender(
+		p.sess.getClock(),
+		p.rttStats,
+		false, /* don't use reno since chromium doesn't (why?) */
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
+
+// isCoupled returns true if the path uses coupled congestion control.
+// Coupled algorithms are not used on the initial path, since it is not used for data once other paths exist.
+func isCoupled(p *path, coupling *congestionCoupling) bool {
+	return p.sess.version >= protocol.VersionMP && coupling != nil && p.pathID != protocol.InitialPathID
+}
+
+func newOliaSender(p *path, coupling *congestionCoupling) congestion.SendAlgorithm {
+	if !isCoupled(p, coupling) {
+		return newCubicSender(p, coupling)
+	}
+	return congestion.NewOliaSender(coupling.oliaSenders, p.pathID, p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+func newLiaSender(p *path, coupling *congestionCoupling) congestion.SendAlgorithm {
+	if !isCoupled(p, coupling) {
+		return newCubicSender(p, coupling)
+	}
+	return congestion.NewLiaSender(coupling.liaSenders, p.pathID, p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+func newBaliaSender(p *path, coupling *congestionCoupling) congestion.SendAlgorithm {
+	if !isCoupled(p, coupling) {
+		return newCubicSender(p, coupling)
+	}
+	return congestion.NewBaliaSender(coupling.baliaSenders, p.pathID, p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+func newWVegasSender(p *path, coupling *congestionCoupling) congestion.SendAlgorithm {
+	if !isCoupled(p, coupling) {
+		return newCubicSender(p, coupling)
+	}
+	return congestion.NewWVegasSender(coupling.wvegasSenders, p.pathID, p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+func newVegasSender(p *path, _ *congestionCoupling) congestion.SendAlgorithm {
+	return congestion.NewVegasSender(p.sess.getClock(), p.rttStats, false, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+func newBBRSender(p *path, _ *congestionCoupling) congestion.SendAlgorithm {
+	return congestion.NewBBRSender(p.sess.getClock(), p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+}
+
+// defaultCongestionControl returns the name of the congestion control algorithm used if the Config doesn't set one
+func defaultCongestionControl() string {
+	if CongestionControl != "" {
+		return CongestionControl
+	}
+	return "cubic"
+}
+
+// checkCongestionControl returns an error if the congestion control algorithm is unknown
+func checkCongestionControl(name string) error {
+	if _, ok := congestionControls[name]; !ok {
+		return fmt.Errorf("unknown congestion control: %s", name)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/hpack"
 
+	quic "github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -48,6 +49,7 @@ func (s *mockStream) SetReadDeadline(time.Time) error              { panic("not
 func (s *mockStream) SetWriteDeadline(time.Time) error             { panic("not implemented") }
 func (s *mockStream) GetBytesSent() (protocol.ByteCount, error)    { panic("not implemented") }
 func (s *mockStream) GetBytesRetrans() (protocol.ByteCount, error) { panic("not implemented") }
+func (s *mockStream) SetPathPreference([]quic.PathID, bool)        { panic("not implemented") }
 
 func (s *mockStream) Read(p []byte) (int, error) {
 	n, _ := s.dataToRead.Read(p)
@@ -71,6 +71,21 @@ func (s *mockSession) RemoteAddr() net.Addr {
 func (s *mockSession) Context() context.Context {
 	return s.ctx
 }
+func (s *mockSession) Paths() []quic.PathStats {
+	panic("not implemented")
+}
+func (s *mockSession) OpenPath(local, remote net.Addr) (quic.PathID, error) {
+	panic("not implemented")
+}
+func (s *mockSession) ClosePath(quic.PathID) error {
+	panic("not implemented")
+}
+func (s *mockSession) SetPathPriority(quic.PathID, quic.PathPriority) error {
+	panic("not implemented")
+}
+func (s *mockSession) PathEvents() <-chan quic.PathEvent {
+	panic("not implemented")
+}
 
 var _ = Describe("H2 server", func() {
 	var (
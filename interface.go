@@ -19,6 +19,18 @@ type VersionNumber = protocol.VersionNumber
 // A Cookie can be used to verify the ownership of the client address.
 type Cookie = handshake.Cookie
 
+// A PathID identifies a path of a multipath QUIC connection.
+type PathID = protocol.PathID
+
+// A ConnectionID identifies a QUIC connection.
+type ConnectionID = protocol.ConnectionID
+
+// A PacketNumber is the number of a packet, counted separately on every path.
+type PacketNumber = protocol.PacketNumber
+
+// A ByteCount is a number of bytes.
+type ByteCount = protocol.ByteCount
+
 // Stream is the interface implemented by QUIC streams
 type Stream interface {
 	// Read reads data from the stream.
@@ -55,6 +67,10 @@ type Stream interface {
 	GetBytesSent() (protocol.ByteCount, error)
 	// GetBytesRetrans returns the number of bytes of the stream that were retransmitted to the peer
 	GetBytesRetrans() (protocol.ByteCount, error)
+	// SetPathPreference sets the paths on which the data of the stream is sent.
+	// While none of these paths can be used, the data is sent on the other paths, unless pinned is set.
+	// Retransmissions may be sent on any path. Calling it without paths removes the preference.
+	SetPathPreference(paths []PathID, pinned bool)
 }
 
 // A Session is a QUIC connection between two peers.
@@ -78,6 +94,111 @@ type Session interface {
 	// The context is cancelled when the session is closed.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
+	// Paths returns a snapshot of the statistics of every path of the session, ordered by path ID.
+	// It returns nil once the session is closed.
+	Paths() []PathStats
+	// OpenPath opens a new path from a local address of the session to a remote address, and returns its ID.
+	// If a path between both addresses already exists, its ID is returned.
+	// Paths can only be opened if a multipath version was negotiated.
+	OpenPath(local, remote net.Addr) (PathID, error)
+	// ClosePath closes a path and informs the peer with a CLOSE_PATH frame.
+	// Packets that are still in flight on the path are retransmitted on the other paths.
+	// The initial path cannot be closed.
+	ClosePath(PathID) error
+	// SetPathPriority sets the priority of a path and announces it to the peer with a PATH_STATUS frame.
+	// Backup paths are only used when every active path is potentially failed or closed.
+	SetPathPriority(PathID, PathPriority) error
+	// PathEvents returns the channel on which changes of the state of the paths are reported.
+	// Events are dropped if the application doesn't read them fast enough.
+	// The channel is never closed, use the Context to find out when the session is closed.
+	PathEvents() <-chan PathEvent
+}
+
+// PathPriority is the priority of a path
+type PathPriority uint8
+
+const (
+	// PathPriorityActive paths are used by the scheduler. This is the default.
+	PathPriorityActive PathPriority = iota
+	// PathPriorityBackup paths are only used when every active path is potentially failed or closed.
+	PathPriorityBackup
+)
+
+// PathEventType is the type of a PathEvent
+type PathEventType uint8
+
+const (
+	// PathOpened is reported when a path is created, either by this host or by the peer.
+	PathOpened PathEventType = iota + 1
+	// PathValidated is reported when the peer proved that it is reachable on a path,
+	// and when a potentially failed path works again.
+	PathValidated
+	// PathPotentiallyFailed is reported when a path shows no activity since the last RTO,
+	// or when the peer reports it as failed.
+	PathPotentiallyFailed
+	// PathClosed is reported when a path is closed, either by this host or by the peer.
+	PathClosed
+	// PathMigrated is reported when the peer moved a path to a new address, e.g. after a NAT rebinding.
+	// The path is validated again before it carries data.
+	PathMigrated
+)
+
+func (t PathEventType) String() string {
+	switch t {
+	case PathOpened:
+		return "opened"
+	case PathValidated:
+		return "validated"
+	case PathPotentiallyFailed:
+		return "potentially failed"
+	case PathClosed:
+		return "closed"
+	case PathMigrated:
+		return "migrated"
+	default:
+		return "unknown path event"
+	}
+}
+
+// A PathEvent reports a change of the state of a path.
+type PathEvent struct {
+	Type       PathEventType
+	PathID     PathID
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Reason describes what caused the event.
+	Reason string
+}
+
+// PathStats is a snapshot of the state of a path, as returned by Session.Paths.
+type PathStats struct {
+	PathID     PathID
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Open is false once the path was closed.
+	Open bool
+	// PotentiallyFailed is set if the path did not show any activity since the last RTO.
+	PotentiallyFailed bool
+	// Validated is set once the peer proved that it is reachable on the path.
+	// Paths are not used to send data before they are validated.
+	Validated bool
+	// Backup is set if this host or the peer gave the path the backup priority.
+	Backup bool
+
+	SmoothedRTT      time.Duration
+	MinRTT           time.Duration
+	CongestionWindow uint64
+	BytesInFlight    uint64
+
+	SentPackets          uint64
+	SentStreamBytes      uint64
+	RetransmittedPackets uint64
+	LostPackets          uint64
+	ReceivedPackets      uint64
+	ReceivedStreamBytes  uint64
+	// DuplicatedStreamBytes counts the stream bytes sent on the path as copies of packets sent on other paths.
+	// They are included in SentStreamBytes.
+	DuplicatedStreamBytes uint64
 }
 
 // A NonFWSession is a QUIC connection between two peers half-way through the handshake.
@@ -95,6 +216,7 @@ type Config struct {
 	Versions []VersionNumber
 	// Ask the server to truncate the connection ID sent in the Public Header.
 	// This saves 8 bytes in the Public Header in every packet. However, if the IP address of the server changes, the connection cannot be migrated.
+	// Address changes of the client, e.g. after a NAT rebinding, are still handled.
 	// Currently only valid for the client.
 	RequestConnectionIDTruncation bool
 	// HandshakeTimeout is the maximum duration that the cryptographic handshake may take.
@@ -123,6 +245,205 @@ type Config struct {
 	CacheHandshake bool
 	// Should the host try to create new paths, if possible?
 	CreatePaths bool
+	// Scheduler is the name of the algorithm distributing packets over the paths.
+	// It must be one of the built-in schedulers ("lowRTT", "RR", "oppRedundant", "utilRepair", "blest", "ecf")
+	// or registered with RegisterScheduler.
+	// If empty, the scheduler set with SetSchedulerAlgorithm is used, or "lowRTT" if none was set.
+	Scheduler string
+	// CongestionControl is the congestion control algorithm used on the paths.
+	// It must be one of "cubic", "olia", "lia", "balia", "vegas", "wvegas" or "bbr".
+	// If empty, the algorithm set with SetCongestionControl is used, or "cubic" if none was set.
+	// The coupled algorithms OLIA, LIA, BALIA and wVegas are never used on the initial path, they fall back to Cubic there.
+	CongestionControl string
+	// PathCongestionControl overrides the congestion control algorithm for single paths.
+	PathCongestionControl map[PathID]string
+	// MaxRedundancyRatio limits the stream bytes duplicated on redundant paths, e.g. by the "oppRedundant" and
+	// "utilRepair" schedulers, to this fraction of the stream bytes sent, including retransmissions.
+	// If zero, the ratio is not limited.
+	MaxRedundancyRatio float64
+	// MaxRedundantBytesPerSecond limits the rate at which stream bytes are duplicated on redundant paths.
+	// Up to one second of this budget may be spent at once. If zero, the rate is not limited.
+	MaxRedundantBytesPerSecond uint64
+	// Tracer is called for every new session. The returned Tracer receives the events of the session,
+	// if it returns nil, the session is not traced. QlogDir returns a function writing the events as qlog.
+	Tracer func(isClient bool, connectionID ConnectionID) Tracer
+	// MetricsSink is called for every new session. The returned MetricsSink receives the measurements of the session,
+	// if it returns nil, nothing is measured. MetricsDir returns a function writing them to a directory per session.
+	MetricsSink func(isClient bool, connectionID ConnectionID) MetricsSink
+	// KeyLogWriter receives the initial and forward-secure keys of the QUIC crypto handshakes, one line per key derivation.
+	// It can be used by payload-decrypt to decrypt captured packets.
+	// Use of KeyLogWriter compromises security and should only be used for debugging.
+	KeyLogWriter io.Writer
+	// PacketCapture receives a pcapng capture of the packets sent and received by the client or the server,
+	// with the local addresses as interfaces and the path of every packet as a comment.
+	PacketCapture io.Writer
+}
+
+// A Tracer receives the events of a session, e.g. to write a qlog trace.
+// Its methods are called from the goroutines of the session, possibly concurrently, and must not block.
+type Tracer interface {
+	// SentPacket is called for every packet sent, including packets only carrying ACKs
+	SentPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, frames []TracedFrame)
+	// ReceivedPacket is called for every packet that could be decrypted
+	ReceivedPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, frames []TracedFrame)
+	// LostPacket is called when loss detection or a retransmission timeout declares a packet lost
+	LostPacket(pathID PathID, packetNumber PacketNumber)
+	// UpdatedCongestionState is called when the congestion window, the bytes in flight or the RTT of a path changed
+	UpdatedCongestionState(pathID PathID, state CongestionState)
+	// SelectedPath is called when the scheduler chose the path of the next packet,
+	// and the redundant paths carrying a copy of it
+	SelectedPath(pathID PathID, redundantPaths []PathID)
+	// UpdatedPath is called for every PathEvent, also if nobody reads Session.PathEvents
+	UpdatedPath(event PathEvent)
+	// Close is called once the session was closed
+	Close()
+}
+
+// A TracedFrame describes a frame of a traced packet.
+// Only the fields relevant for its type are set.
+type TracedFrame struct {
+	// Type is the qlog name of the frame type, e.g. "stream", "ack" or "path_challenge"
+	Type string
+	// StreamID is set for stream, reset_stream, max_stream_data and stream_data_blocked frames
+	StreamID StreamID
+	// Offset is the offset of the data of stream frames, and the byte offset of reset_stream,
+	// max_data and max_stream_data frames
+	Offset ByteCount
+	// Length is the length of the data of stream frames
+	Length ByteCount
+	Fin    bool
+	// PathID is the path acknowledged by ack and close_path frames, and the path of path_status frames
+	PathID PathID
+	// AckRanges are the ranges of packet numbers acknowledged by ack and close_path frames, the highest first
+	AckRanges [][2]PacketNumber
+	// ErrorCode is set for connection_close, goaway and reset_stream frames
+	ErrorCode uint32
+	// Reason is the reason phrase of connection_close and goaway frames
+	Reason string
+}
+
+// CongestionState is the state of the congestion control of a path
+type CongestionState struct {
+	CongestionWindow uint64
+	BytesInFlight    uint64
+	SmoothedRTT      time.Duration
+	MinRTT           time.Duration
+	LatestRTT        time.Duration
+}
+
+// A MetricsSink receives the measurements of a session, e.g. the timestamps used to calculate frame latencies.
+// Its methods are called from the goroutines of the session and its streams, possibly concurrently.
+type MetricsSink interface {
+	// SentStreamFrame is called for every STREAM frame sent
+	SentStreamFrame(frame StreamFrameMetric)
+	// ReceivedStreamFrame is called for every STREAM frame received
+	ReceivedStreamFrame(frame StreamFrameMetric)
+	// ReadStreamFrame is called when the application starts reading the data of a STREAM frame
+	ReadStreamFrame(frame StreamFrameMetric)
+	// SendRate is called periodically for every path, with the time since the session started,
+	// the send rate in kbit/s since the last call, and the bytes in flight
+	SendRate(pathID PathID, sinceStart time.Duration, rate float64, bytesInFlight ByteCount)
+	// UpdatedSchedulerStats is called periodically with the statistics of the scheduler
+	UpdatedSchedulerStats(stats SchedulerStats)
+	// Close is called once the session was closed
+	Close()
+}
+
+// A StreamFrameMetric describes a STREAM frame for a MetricsSink.
+// PathID and PacketNumber are not set for frames read by the application.
+type StreamFrameMetric struct {
+	PathID       PathID
+	PacketNumber PacketNumber
+	StreamID     StreamID
+	Offset       ByteCount
+	// Data is the data of the frame, it must not be retained
+	Data []byte
+	Time time.Time
+}
+
+// SchedulerStats are the statistics of the scheduler of a session, and of the paths it used.
+// The rates are given in percent.
+type SchedulerStats struct {
+	TotalSentPackets         uint64               `json:"totalSentPackets"`
+	DuplicatedPackets        uint64               `json:"duplicatedPackets"`
+	DuplicatedDroppedPackets uint64               `json:"duplicatedDroppedPackets"`
+	DuplicatedPacketDropRate float64              `json:"duplicatedPacketDropRate"`
+	TotalStreamBytes         uint64               `json:"totalStreamBytes"`
+	DuplicatedStreamBytes    uint64               `json:"duplicatedStreamBytes"`
+	DuplicateStreamRate      float64              `json:"duplicateStreamRate"`
+	BlockedCWHighestTPPath   uint64               `json:"blockedCWhighestTPPath"`
+	LowerRTTSchedules        uint64               `json:"lowerRTTSchedules"`
+	PathSwitches             uint64               `json:"pathSwitches"`
+	ReinjectedPackets        uint64               `json:"reinjectedPackets"`
+	RedundancyBudgetSkips    uint64               `json:"redundancyBudgetSkips"`
+	PathStats                []SchedulerPathStats `json:"pathStats"`
+}
+
+// SchedulerPathStats are the statistics of the scheduler for a single path.
+type SchedulerPathStats struct {
+	PathID               PathID `json:"pathID"`
+	PathIP               string `json:"pathIP"`
+	SentPackets          uint64 `json:"sendPackets"`
+	Retransmissions      uint64 `json:"retransmissions"`
+	Losses               uint64 `json:"losses"`
+	SentStreamFrameBytes uint64 `json:"sentStreamFrameBytes"`
+	SelectedAsBestPath   uint64 `json:"selectedAsBestPath"`
+}
+
+// SchedulerPath is the view of a path given to a Scheduler.
+type SchedulerPath interface {
+	PathID() PathID
+	// SendingAllowed returns true if the path is open and its congestion window allows sending.
+	SendingAllowed() bool
+	// CongestionFree returns true if the path is neither congestion nor tracking limited.
+	CongestionFree() bool
+	// OvershootFree returns true if sending on the path would not overshoot its bufferbloat
+	// limit, given the number of usable paths.
+	OvershootFree(pathNum int) bool
+	// PotentiallyFailed returns true if the path did not show any activity since the last RTO.
+	PotentiallyFailed() bool
+	SmoothedRTT() time.Duration
+	MinRTT() time.Duration
+	CongestionWindow() uint64
+	BytesInFlight() uint64
+	// Quota is the number of packets the scheduler sent on this path so far.
+	Quota() uint
+}
+
+// ScheduleContext describes the sending opportunity a Scheduler decides on.
+type ScheduleContext struct {
+	// Paths are the paths of the session in no particular order, without the initial path.
+	// Backup paths are only included if every active path is potentially failed or closed.
+	Paths []SchedulerPath
+	// HasRetransmission is set if a packet was dequeued for retransmission.
+	HasRetransmission bool
+	// HasStreamRetransmission is set if stream data is waiting to be retransmitted.
+	HasStreamRetransmission bool
+	// RetransmissionPath is the path the retransmitted packet was sent on, or nil.
+	RetransmissionPath SchedulerPath
+	// ConnectionSendWindow is the number of bytes the connection-level flow control still allows to send.
+	ConnectionSendWindow uint64
+	// QueuedBytes is the number of bytes of stream data waiting to be sent, including retransmissions.
+	QueuedBytes uint64
+	// RedundancyBudgetSpent is set while the redundancy budget of the session doesn't allow duplicating a packet.
+	// The redundant paths returned by SelectPath are not used then.
+	RedundancyBudgetSpent bool
+}
+
+// A Scheduler distributes the packets of a session over its paths.
+// A new Scheduler is created for every session, so implementations may keep per-session state.
+// It is only called from the run loop of its session.
+type Scheduler interface {
+	// SelectPath returns the path the next packet is sent on, or nil if no path may send.
+	// The initial path is only handed out by the session itself, while no other path exists.
+	// The returned slice lists the paths that should carry a redundant copy of the packet.
+	SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath)
+	// ShouldDuplicate returns true if the packet just sent on pth should be queued for
+	// retransmission again, so that another path carries a copy of it.
+	ShouldDuplicate(ctx *ScheduleContext, pth SchedulerPath) bool
+	// PiggybackAck returns true if a pending ACK for pth should be bundled with the next
+	// packet sent on pth. Otherwise, it is sent in a separate packet once sending stops.
+	PiggybackAck(pth SchedulerPath) bool
 }
 
 // A Listener for incoming QUIC connections
@@ -133,4 +454,24 @@ type Listener interface {
 	Addr() net.Addr
 	// Accept returns new sessions. It should be called in a loop.
 	Accept() (Session, error)
+	// Stats returns a snapshot of the counters of the listener, and of the paths of its sessions.
+	Stats() ListenerStats
+}
+
+// ListenerStats is a snapshot of the counters of a Listener, as returned by Listener.Stats.
+type ListenerStats struct {
+	// SessionsAccepted counts the sessions that completed the handshake.
+	SessionsAccepted uint64
+	// HandshakesFailed counts the sessions that were closed before completing the handshake.
+	HandshakesFailed uint64
+	// PublicResetsSent counts the Public Reset packets sent by the listener and its sessions.
+	PublicResetsSent uint64
+	// Sessions are the sessions that were not closed yet, including the ones still in the handshake.
+	Sessions []SessionStats
+}
+
+// SessionStats is a snapshot of the state of a session.
+type SessionStats struct {
+	ConnectionID ConnectionID
+	Paths        []PathStats
 }
@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// The labels of the lines of a key log
+const (
+	keyLogLabelInitial       = "QUIC_CRYPTO_INITIAL"
+	keyLogLabelForwardSecure = "QUIC_CRYPTO_FORWARD_SECURE"
+)
+
+// keyLogMutex serializes the writes to all key logs, such that the lines of concurrent handshakes don't interleave
+var keyLogMutex sync.Mutex
+
+// A KeyLogEntry are the keys derived by a handshake, either the initial or the forward-secure ones
+type KeyLogEntry struct {
+	ConnectionID  protocol.ConnectionID
+	ForwardSecure bool
+	ClientKey     []byte
+	ClientIV      []byte
+	ServerKey     []byte
+	ServerIV      []byte
+}
+
+// AEAD creates an AEAD with the keys of the entry, as used by the endpoint with the perspective pers.
+// It opens the packets sent by the peer of pers.
+func (e *KeyLogEntry) AEAD(pers protocol.Perspective) (AEAD, error) {
+	if pers == protocol.PerspectiveClient {
+		return NewAEADAESGCM12(e.ServerKey, e.ClientKey, e.ServerIV, e.ClientIV)
+	}
+	return NewAEADAESGCM12(e.ClientKey, e.ServerKey, e.ClientIV, e.ServerIV)
+}
+
+// DeriveQuicCryptoAESKeysWithKeyLog works like DeriveQuicCryptoAESKeys, and additionally writes the derived keys to w.
+// Every key derivation writes one line "<label> <connection ID> <client key> <client IV> <server key> <server IV>",
+// with the label QUIC_CRYPTO_INITIAL or QUIC_CRYPTO_FORWARD_SECURE, and all values hex encoded.
+// Use of this function compromises the security of the connections, it should only be used for debugging.
+func DeriveQuicCryptoAESKeysWithKeyLog(w io.Writer) func(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective) (AEAD, error) {
+	return func(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective) (AEAD, error) {
+		serverKey, clientKey, serverIV, clientIV, err := deriveKeys(forwardSecure, sharedSecret, nonces, connID, chlo, scfg, cert, divNonce, 16, true)
+		if err != nil {
+			return nil, err
+		}
+		entry := &KeyLogEntry{
+			ConnectionID:  connID,
+			ForwardSecure: forwardSecure,
+			ClientKey:     clientKey,
+			ClientIV:      clientIV,
+			ServerKey:     serverKey,
+			ServerIV:      serverIV,
+		}
+		// The key log is only used for debugging, failing to write it must not break the connection
+		if err := writeKeyLogEntry(w, entry); err != nil {
+			utils.Errorf("Writing the key log failed: %s", err.Error())
+		}
+		return entry.AEAD(pers)
+	}
+}
+
+func writeKeyLogEntry(w io.Writer, e *KeyLogEntry) error {
+	label := keyLogLabelInitial
+	if e.ForwardSecure {
+		label = keyLogLabelForwardSecure
+	}
+	line := fmt.Sprintf("%s %016x %x %x %x %x\n", label, uint64(e.ConnectionID), e.ClientKey, e.ClientIV, e.ServerKey, e.ServerIV)
+	keyLogMutex.Lock()
+	defer keyLogMutex.Unlock()
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+// ParseKeyLog parses a key log written by DeriveQuicCryptoAESKeysWithKeyLog.
+// Empty lines, comments starting with # and lines with unknown labels are skipped.
+func ParseKeyLog(r io.Reader) ([]KeyLogEntry, error) {
+	var entries []KeyLogEntry
+	scanner := bufio.NewScanner(r)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		var forwardSecure bool
+		switch fields[0] {
+		case keyLogLabelInitial:
+		case keyLogLabelForwardSecure:
+			forwardSecure = true
+		default:
+			continue
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("key log line %d: expected 6 fields, got %d", lineNumber, len(fields))
+		}
+		connID, err := strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("key log line %d: invalid connection ID: %s", lineNumber, err.Error())
+		}
+		var values [4][]byte
+		for i := range values {
+			values[i], err = hex.DecodeString(fields[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("key log line %d: %s", lineNumber, err.Error())
+			}
+		}
+		entries = append(entries, KeyLogEntry{
+			ConnectionID:  protocol.ConnectionID(connID),
+			ForwardSecure: forwardSecure,
+			ClientKey:     values[0],
+			ClientIV:      values[1],
+			ServerKey:     values[2],
+			ServerIV:      values[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
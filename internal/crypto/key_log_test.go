@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type errorWriter struct {
+	err error
+}
+
+func (w *errorWriter) Write([]byte) (int, error) { return 0, w.err }
+
+var _ = Describe("Key Log", func() {
+	derive := func(w io.Writer, forwardSecure bool, pers protocol.Perspective) AEAD {
+		aead, err := DeriveQuicCryptoAESKeysWithKeyLog(w)(
+			forwardSecure,
+			[]byte("0123456789012345678901"),
+			[]byte("nonce"),
+			protocol.ConnectionID(0x1337),
+			[]byte("chlo"),
+			[]byte("scfg"),
+			[]byte("cert"),
+			[]byte("divnonce"),
+			pers,
+		)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		return aead
+	}
+
+	It("derives the same keys as without a key log", func() {
+		for _, forwardSecure := range []bool{false, true} {
+			client := derive(&bytes.Buffer{}, forwardSecure, protocol.PerspectiveClient)
+			server, err := DeriveQuicCryptoAESKeys(
+				forwardSecure,
+				[]byte("0123456789012345678901"),
+				[]byte("nonce"),
+				protocol.ConnectionID(0x1337),
+				[]byte("chlo"),
+				[]byte("scfg"),
+				[]byte("cert"),
+				[]byte("divnonce"),
+				protocol.PerspectiveServer,
+			)
+			Expect(err).ToNot(HaveOccurred())
+			sealed := client.Seal(nil, []byte("foobar"), 42, []byte("aad"))
+			opened, err := server.Open(nil, sealed, 42, []byte("aad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opened).To(Equal([]byte("foobar")))
+		}
+	})
+
+	It("derives the keys if the key log can't be written", func() {
+		w := &errorWriter{err: errors.New("disk full")}
+		Expect(derive(w, true, protocol.PerspectiveClient)).ToNot(BeNil())
+	})
+
+	It("writes a line per key derivation", func() {
+		buf := &bytes.Buffer{}
+		derive(buf, false, protocol.PerspectiveClient)
+		derive(buf, true, protocol.PerspectiveServer)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HavePrefix("QUIC_CRYPTO_INITIAL 0000000000001337 "))
+		Expect(lines[1]).To(HavePrefix("QUIC_CRYPTO_FORWARD_SECURE 0000000000001337 "))
+		Expect(strings.Fields(lines[0])).To(HaveLen(6))
+	})
+
+	It("parses the key log and creates AEADs for both perspectives", func() {
+		buf := &bytes.Buffer{}
+		client := derive(buf, true, protocol.PerspectiveClient)
+		server := derive(&bytes.Buffer{}, true, protocol.PerspectiveServer)
+		entries, err := ParseKeyLog(strings.NewReader("# a comment\n\nCLIENT_RANDOM foo bar\n" + buf.String()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ConnectionID).To(Equal(protocol.ConnectionID(0x1337)))
+		Expect(entries[0].ForwardSecure).To(BeTrue())
+		Expect(entries[0].ClientKey).To(HaveLen(16))
+		Expect(entries[0].ClientIV).To(HaveLen(4))
+
+		// packets sent by the client are opened with the keys of the server, and vice versa
+		asServer, err := entries[0].AEAD(protocol.PerspectiveServer)
+		Expect(err).ToNot(HaveOccurred())
+		opened, err := asServer.Open(nil, client.Seal(nil, []byte("foo"), 1, nil), 1, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opened).To(Equal([]byte("foo")))
+		asClient, err := entries[0].AEAD(protocol.PerspectiveClient)
+		Expect(err).ToNot(HaveOccurred())
+		opened, err = asClient.Open(nil, server.Seal(nil, []byte("bar"), 2, nil), 2, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opened).To(Equal([]byte("bar")))
+	})
+
+	It("errors on malformed lines", func() {
+		_, err := ParseKeyLog(strings.NewReader("QUIC_CRYPTO_INITIAL 1337 00\n"))
+		Expect(err).To(MatchError("key log line 1: expected 6 fields, got 3"))
+		_, err = ParseKeyLog(strings.NewReader("QUIC_CRYPTO_INITIAL xyz 00 00 00 00\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = ParseKeyLog(strings.NewReader("QUIC_CRYPTO_INITIAL 1337 00 0g 00 00\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -81,7 +81,12 @@ func NewCryptoSetupClient(
 	aeadChanged chan<- protocol.EncryptionLevel,
 	params *TransportParameters,
 	negotiatedVersions []protocol.VersionNumber,
+	keyLog io.Writer,
 ) (CryptoSetup, error) {
+	keyDerivation := QuicCryptoKeyDerivationFunction(crypto.DeriveQuicCryptoAESKeys)
+	if keyLog != nil {
+		keyDerivation = crypto.DeriveQuicCryptoAESKeysWithKeyLog(keyLog)
+	}
 	return &cryptoSetupClient{
 		hostname:             hostname,
 		connID:               connID,
@@ -89,7 +94,7 @@ func NewCryptoSetupClient(
 		cryptoStream:         cryptoStream,
 		certManager:          crypto.NewCertManager(tlsConfig),
 		connectionParameters: connectionParameters,
-		keyDerivation:        crypto.DeriveQuicCryptoAESKeys,
+		keyDerivation:        keyDerivation,
 		keyExchange:          getEphermalKEX,
 		nullAEAD:             crypto.NewNullAEAD(protocol.PerspectiveClient, version),
 		aeadChanged:          aeadChanged,
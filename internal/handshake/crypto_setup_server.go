@@ -74,12 +74,17 @@ func NewCryptoSetup(
 	supportedVersions []protocol.VersionNumber,
 	acceptSTK func(net.Addr, *Cookie) bool,
 	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLog io.Writer,
 ) (CryptoSetup, error) {
 	stkGenerator, err := NewCookieGenerator()
 	if err != nil {
 		return nil, err
 	}
 
+	keyDerivation := QuicCryptoKeyDerivationFunction(crypto.DeriveQuicCryptoAESKeys)
+	if keyLog != nil {
+		keyDerivation = crypto.DeriveQuicCryptoAESKeysWithKeyLog(keyLog)
+	}
 	return &cryptoSetupServer{
 		connID:               connID,
 		remoteAddr:           remoteAddr,
@@ -87,7 +92,7 @@ func NewCryptoSetup(
 		supportedVersions:    supportedVersions,
 		scfg:                 scfg,
 		stkGenerator:         stkGenerator,
-		keyDerivation:        crypto.DeriveQuicCryptoAESKeys,
+		keyDerivation:        keyDerivation,
 		keyExchange:          getEphermalKEX,
 		nullAEAD:             crypto.NewNullAEAD(protocol.PerspectiveServer, version),
 		cryptoStream:         cryptoStream,
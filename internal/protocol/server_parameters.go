@@ -83,6 +83,15 @@ const SkipPacketAveragePeriodLength PacketNumber = 500
 // MaxTrackedSkippedPackets is the maximum number of skipped packet numbers the SentPacketHandler keep track of for Optimistic ACK attack mitigation
 const MaxTrackedSkippedPackets = 10
 
+// MaxQueuedPathEvents is the maximum number of path events queued for the application, newer events are dropped
+const MaxQueuedPathEvents = 64
+
+// AmplificationFactor limits the bytes sent on a path opened by the peer, relative to the bytes received on it, until the path is validated
+const AmplificationFactor = 3
+
+// MaxPathChallenges is the maximum number of PATH_CHALLENGEs sent on a path before giving up and closing it
+const MaxPathChallenges = 3
+
 // CookieExpiryTime is the valid time of a cookie
 const CookieExpiryTime = 24 * time.Hour
 
@@ -0,0 +1,166 @@
+// Package simnet is a discrete-event network simulator.
+// It emulates links with a bandwidth, a delay, losses and a queue on a virtual clock,
+// so that schedulers and congestion controllers can be tested with exact, repeatable results.
+package simnet
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// A Clock is a virtual clock for discrete-event simulations.
+// Time only passes when the clock is advanced. Timers then fire in the order of their deadlines,
+// timers with the same deadline in the order they were started. Their functions run synchronously
+// in the goroutine advancing the clock, so a simulation driven by a single goroutine is deterministic.
+type Clock struct {
+	mutex sync.Mutex
+
+	now    time.Time
+	timers timerHeap
+	seq    uint64
+}
+
+// NewClock creates a new virtual clock starting at the given time
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current virtual time
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once the clock was advanced by d
+func (c *Clock) AfterFunc(d time.Duration, f func()) *Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	t := &Timer{clock: c, deadline: c.now.Add(d), seq: c.seq, f: f}
+	c.seq++
+	heap.Push(&c.timers, t)
+	return t
+}
+
+// Advance advances the clock by d, firing all timers with a deadline up to the new time
+func (c *Clock) Advance(d time.Duration) {
+	c.RunUntil(c.Now().Add(d))
+}
+
+// RunUntil advances the clock to the given time, firing all timers with a deadline up to this time.
+// Timers started by the fired functions also fire if their deadline is not later.
+func (c *Clock) RunUntil(end time.Time) {
+	for c.step(end) {
+	}
+	c.mutex.Lock()
+	if end.After(c.now) {
+		c.now = end
+	}
+	c.mutex.Unlock()
+}
+
+// Run fires timers until none is left, and returns the time of the last event
+func (c *Clock) Run() time.Time {
+	for c.step(time.Time{}) {
+	}
+	return c.Now()
+}
+
+// RunRealtime advances the clock along with the wall clock until done is closed,
+// e.g. to run sessions using the wall clock over a simulated network. Such runs are not deterministic.
+func (c *Clock) RunRealtime(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			c.Advance(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// NextDeadline returns the deadline of the next timer, and false if no timer is running
+func (c *Clock) NextDeadline() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.timers) == 0 {
+		return time.Time{}, false
+	}
+	return c.timers[0].deadline, true
+}
+
+// step fires the next timer, if its deadline is not after end. A zero end fires any timer.
+func (c *Clock) step(end time.Time) bool {
+	c.mutex.Lock()
+	if len(c.timers) == 0 || (!end.IsZero() && c.timers[0].deadline.After(end)) {
+		c.mutex.Unlock()
+		return false
+	}
+	t := heap.Pop(&c.timers).(*Timer)
+	if t.deadline.After(c.now) {
+		c.now = t.deadline
+	}
+	c.mutex.Unlock()
+	t.f()
+	return true
+}
+
+// A Timer calls a function when the virtual clock reaches its deadline
+type Timer struct {
+	clock    *Clock
+	deadline time.Time
+	seq      uint64
+	index    int
+	f        func()
+}
+
+// Stop prevents the Timer from firing. It returns false if the timer already fired or was stopped.
+func (t *Timer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.clock.timers, t.index)
+	return true
+}
+
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,75 @@
+package simnet
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Clock", func() {
+	var (
+		clock *Clock
+		start time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Unix(1000, 0)
+		clock = NewClock(start)
+	})
+
+	It("only advances when told to", func() {
+		Expect(clock.Now()).To(Equal(start))
+		clock.Advance(time.Second)
+		Expect(clock.Now()).To(Equal(start.Add(time.Second)))
+	})
+
+	It("fires timers in the order of their deadlines", func() {
+		var fired []int
+		clock.AfterFunc(2*time.Millisecond, func() { fired = append(fired, 2) })
+		clock.AfterFunc(time.Millisecond, func() { fired = append(fired, 1) })
+		clock.AfterFunc(2*time.Millisecond, func() { fired = append(fired, 3) })
+		clock.Advance(time.Millisecond)
+		Expect(fired).To(Equal([]int{1}))
+		clock.Advance(time.Millisecond)
+		Expect(fired).To(Equal([]int{1, 2, 3}))
+	})
+
+	It("sets the time of a timer while it fires", func() {
+		var firedAt time.Time
+		clock.AfterFunc(time.Millisecond, func() { firedAt = clock.Now() })
+		clock.Advance(time.Second)
+		Expect(firedAt).To(Equal(start.Add(time.Millisecond)))
+		Expect(clock.Now()).To(Equal(start.Add(time.Second)))
+	})
+
+	It("fires timers started by other timers", func() {
+		var fired bool
+		clock.AfterFunc(time.Millisecond, func() {
+			clock.AfterFunc(time.Millisecond, func() { fired = true })
+		})
+		clock.Advance(2 * time.Millisecond)
+		Expect(fired).To(BeTrue())
+	})
+
+	It("stops timers", func() {
+		var fired bool
+		t := clock.AfterFunc(time.Millisecond, func() { fired = true })
+		Expect(t.Stop()).To(BeTrue())
+		Expect(t.Stop()).To(BeFalse())
+		clock.Advance(time.Second)
+		Expect(fired).To(BeFalse())
+	})
+
+	It("runs until no timer is left", func() {
+		clock.AfterFunc(time.Second, func() {
+			clock.AfterFunc(time.Second, func() {})
+		})
+		deadline, ok := clock.NextDeadline()
+		Expect(ok).To(BeTrue())
+		Expect(deadline).To(Equal(start.Add(time.Second)))
+		Expect(clock.Run()).To(Equal(start.Add(2 * time.Second)))
+		_, ok = clock.NextDeadline()
+		Expect(ok).To(BeFalse())
+	})
+})
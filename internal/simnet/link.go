@@ -0,0 +1,115 @@
+package simnet
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// LinkConfig configures an emulated link
+type LinkConfig struct {
+	// Bandwidth is the rate at which the link transmits packets, in bytes per second.
+	// If zero, the bandwidth is not limited.
+	Bandwidth uint64
+	// Delay is the one-way propagation delay of the link
+	Delay time.Duration
+	// LossRate is the probability that a transmitted packet is lost, between 0 and 1
+	LossRate float64
+	// QueueSize is the number of bytes that may wait for their transmission, packets exceeding it are dropped.
+	// If zero, the queue is not limited.
+	QueueSize int
+}
+
+// LinkStats counts the packets sent on a link
+type LinkStats struct {
+	SentPackets    uint64
+	SentBytes      uint64
+	LostPackets    uint64
+	DroppedPackets uint64
+}
+
+// A Link transmits packets in one direction, one after the other.
+// A packet arrives after it waited for the packets queued before it, was transmitted at the bandwidth of
+// the link, and propagated for its delay.
+type Link struct {
+	mutex sync.Mutex
+
+	config LinkConfig
+	clock  *Clock
+	rand   *rand.Rand
+
+	// busyUntil is the time at which the link finishes transmitting the queued packets
+	busyUntil time.Time
+	stats     LinkStats
+}
+
+// NewLink creates a link. Losses are drawn from a random source with the given seed.
+func NewLink(clock *Clock, config LinkConfig, seed int64) *Link {
+	return &Link{
+		config: config,
+		clock:  clock,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Send transmits a packet of the given size, deliver is called when it arrives.
+// It returns false if the packet was dropped or lost.
+func (l *Link) Send(size int, deliver func()) bool {
+	now := l.clock.Now()
+
+	l.mutex.Lock()
+	if l.busyUntil.Before(now) {
+		l.busyUntil = now
+	}
+	if l.config.QueueSize > 0 && l.queuedBytes(now)+size > l.config.QueueSize {
+		l.stats.DroppedPackets++
+		l.mutex.Unlock()
+		return false
+	}
+	l.busyUntil = l.busyUntil.Add(l.transmissionTime(size))
+	arrival := l.busyUntil.Add(l.config.Delay)
+	l.stats.SentPackets++
+	l.stats.SentBytes += uint64(size)
+	// Lost packets still occupy the link while they are transmitted
+	lost := l.config.LossRate > 0 && l.rand.Float64() < l.config.LossRate
+	if lost {
+		l.stats.LostPackets++
+	}
+	l.mutex.Unlock()
+
+	if lost {
+		return false
+	}
+	l.clock.AfterFunc(arrival.Sub(now), deliver)
+	return true
+}
+
+// Stats returns the statistics of the link
+func (l *Link) Stats() LinkStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
+
+// SetConfig changes the configuration of the link, e.g. to emulate a handover.
+// Packets already sent are not affected.
+func (l *Link) SetConfig(config LinkConfig) {
+	l.mutex.Lock()
+	l.config = config
+	l.mutex.Unlock()
+}
+
+// queuedBytes returns the number of bytes waiting for their transmission
+func (l *Link) queuedBytes(now time.Time) int {
+	if l.config.Bandwidth == 0 {
+		return 0
+	}
+	return int(float64(l.busyUntil.Sub(now)) / float64(time.Second) * float64(l.config.Bandwidth))
+}
+
+func (l *Link) transmissionTime(size int) time.Duration {
+	if l.config.Bandwidth == 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / float64(l.config.Bandwidth) * float64(time.Second))
+}
@@ -0,0 +1,65 @@
+package simnet
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Link", func() {
+	var (
+		clock    *Clock
+		start    time.Time
+		arrivals []time.Duration
+		deliver  = func() { arrivals = append(arrivals, clock.Now().Sub(start)) }
+	)
+
+	BeforeEach(func() {
+		start = time.Unix(1000, 0)
+		clock = NewClock(start)
+		arrivals = nil
+	})
+
+	It("delays packets", func() {
+		link := NewLink(clock, LinkConfig{Delay: 10 * time.Millisecond}, 0)
+		Expect(link.Send(1000, deliver)).To(BeTrue())
+		clock.Run()
+		Expect(arrivals).To(Equal([]time.Duration{10 * time.Millisecond}))
+	})
+
+	It("transmits packets one after the other at its bandwidth", func() {
+		// 1000 bytes per millisecond
+		link := NewLink(clock, LinkConfig{Bandwidth: 1000000, Delay: 10 * time.Millisecond}, 0)
+		link.Send(1000, deliver)
+		link.Send(1000, deliver)
+		clock.Run()
+		Expect(arrivals).To(Equal([]time.Duration{11 * time.Millisecond, 12 * time.Millisecond}))
+		Expect(link.Stats()).To(Equal(LinkStats{SentPackets: 2, SentBytes: 2000}))
+	})
+
+	It("drops packets exceeding the queue", func() {
+		link := NewLink(clock, LinkConfig{Bandwidth: 1000000, QueueSize: 1500}, 0)
+		Expect(link.Send(1000, deliver)).To(BeTrue())
+		Expect(link.Send(1000, deliver)).To(BeFalse())
+		clock.Advance(time.Millisecond)
+		Expect(link.Send(1000, deliver)).To(BeTrue())
+		Expect(link.Stats().DroppedPackets).To(Equal(uint64(1)))
+	})
+
+	It("loses packets at random, but repeatably", func() {
+		lost := func(seed int64) []int {
+			link := NewLink(clock, LinkConfig{LossRate: 0.5}, seed)
+			var lost []int
+			for i := 0; i < 100; i++ {
+				if !link.Send(1000, deliver) {
+					lost = append(lost, i)
+				}
+			}
+			return lost
+		}
+		Expect(len(lost(1))).To(BeNumerically("~", 50, 15))
+		Expect(lost(1)).To(Equal(lost(1)))
+		Expect(lost(1)).ToNot(Equal(lost(2)))
+	})
+})
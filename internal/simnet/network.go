@@ -0,0 +1,220 @@
+package simnet
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	errClosed               = errors.New("simnet: use of closed connection")
+	errAddressInUse         = errors.New("simnet: address already in use")
+	errDeadlineNotSupported = errors.New("simnet: deadlines are not supported")
+)
+
+type route struct {
+	from, to string
+}
+
+// A Network connects PacketConns by links.
+// Every pair of addresses has its own link in each direction, packets between addresses without a link are dropped.
+type Network struct {
+	mutex sync.Mutex
+
+	clock *Clock
+	seed  int64
+
+	links map[route]*Link
+	conns map[string]*PacketConn
+}
+
+// NewNetwork creates a network. The losses of its links are drawn from random sources derived from the seed.
+func NewNetwork(clock *Clock, seed int64) *Network {
+	return &Network{
+		clock: clock,
+		seed:  seed,
+		links: make(map[route]*Link),
+		conns: make(map[string]*PacketConn),
+	}
+}
+
+// Clock returns the clock of the network
+func (n *Network) Clock() *Clock {
+	return n.clock
+}
+
+// AddLink adds a link carrying the packets sent from one address to another
+func (n *Network) AddLink(from, to net.Addr, config LinkConfig) *Link {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	link := NewLink(n.clock, config, n.seed+int64(len(n.links)))
+	n.links[route{from.String(), to.String()}] = link
+	return link
+}
+
+// AddPath adds links with the same configuration in both directions between two addresses
+func (n *Network) AddPath(a, b net.Addr, config LinkConfig) (*Link, *Link) {
+	return n.AddLink(a, b, config), n.AddLink(b, a, config)
+}
+
+// ListenPacket creates a PacketConn on a local address
+func (n *Network) ListenPacket(addr *net.UDPAddr) (*PacketConn, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if _, ok := n.conns[addr.String()]; ok {
+		return nil, errAddressInUse
+	}
+	c := &PacketConn{
+		network: n,
+		addr:    addr,
+		signal:  make(chan struct{}, 1),
+	}
+	n.conns[addr.String()] = c
+	return c, nil
+}
+
+func (n *Network) send(from net.Addr, to net.Addr, data []byte) {
+	n.mutex.Lock()
+	link, ok := n.links[route{from.String(), to.String()}]
+	n.mutex.Unlock()
+	if !ok {
+		return
+	}
+	link.Send(len(data), func() {
+		n.mutex.Lock()
+		dst, ok := n.conns[to.String()]
+		n.mutex.Unlock()
+		if ok {
+			dst.deliver(data, from)
+		}
+	})
+}
+
+func (n *Network) remove(c *PacketConn) {
+	n.mutex.Lock()
+	if n.conns[c.addr.String()] == c {
+		delete(n.conns, c.addr.String())
+	}
+	n.mutex.Unlock()
+}
+
+type receivedPacket struct {
+	data []byte
+	from net.Addr
+}
+
+// A PacketConn is a net.PacketConn sending its packets over the links of a Network
+type PacketConn struct {
+	mutex sync.Mutex
+
+	network *Network
+	addr    *net.UDPAddr
+
+	// onReceive, if set, handles the received packets instead of ReadFrom
+	onReceive func(data []byte, from net.Addr)
+	queue     []receivedPacket
+	signal    chan struct{}
+	closed    bool
+}
+
+var _ net.PacketConn = &PacketConn{}
+
+// SetReceiveHandler makes the PacketConn hand its packets to f instead of queueing them for ReadFrom.
+// f is called in the goroutine advancing the clock.
+func (c *PacketConn) SetReceiveHandler(f func(data []byte, from net.Addr)) {
+	c.mutex.Lock()
+	c.onReceive = f
+	c.mutex.Unlock()
+}
+
+// ReadFrom reads the next packet, blocking until one arrives
+func (c *PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		c.mutex.Lock()
+		if len(c.queue) > 0 {
+			packet := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mutex.Unlock()
+			return copy(p, packet.data), packet.from, nil
+		}
+		closed := c.closed
+		c.mutex.Unlock()
+		if closed {
+			return 0, nil, errClosed
+		}
+		<-c.signal
+	}
+}
+
+// WriteTo sends a packet. Packets to addresses without a link are dropped silently, like UDP datagrams.
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+	if closed {
+		return 0, errClosed
+	}
+	data := make([]byte, len(p))
+	copy(data, p)
+	c.network.send(c.addr, addr, data)
+	return len(p), nil
+}
+
+// Close closes the PacketConn, blocked ReadFrom calls return an error
+func (c *PacketConn) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return errClosed
+	}
+	c.closed = true
+	c.mutex.Unlock()
+	c.network.remove(c)
+	c.notify()
+	return nil
+}
+
+// LocalAddr returns the local address
+func (c *PacketConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline is not supported
+func (c *PacketConn) SetDeadline(time.Time) error {
+	return errDeadlineNotSupported
+}
+
+// SetReadDeadline is not supported
+func (c *PacketConn) SetReadDeadline(time.Time) error {
+	return errDeadlineNotSupported
+}
+
+// SetWriteDeadline is not supported
+func (c *PacketConn) SetWriteDeadline(time.Time) error {
+	return errDeadlineNotSupported
+}
+
+func (c *PacketConn) deliver(data []byte, from net.Addr) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	if c.onReceive != nil {
+		onReceive := c.onReceive
+		c.mutex.Unlock()
+		onReceive(data, from)
+		return
+	}
+	c.queue = append(c.queue, receivedPacket{data: data, from: from})
+	c.mutex.Unlock()
+	c.notify()
+}
+
+func (c *PacketConn) notify() {
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
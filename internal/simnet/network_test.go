@@ -0,0 +1,83 @@
+package simnet
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Network", func() {
+	var (
+		network        *Network
+		addrA, addrB   *net.UDPAddr
+		connA, connB   *PacketConn
+		linkAB, linkBA *Link
+	)
+
+	BeforeEach(func() {
+		network = NewNetwork(NewClock(time.Unix(1000, 0)), 42)
+		addrA = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+		addrB = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 2000}
+		linkAB, linkBA = network.AddPath(addrA, addrB, LinkConfig{Delay: 10 * time.Millisecond})
+		var err error
+		connA, err = network.ListenPacket(addrA)
+		Expect(err).ToNot(HaveOccurred())
+		connB, err = network.ListenPacket(addrB)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("doesn't listen twice on an address", func() {
+		_, err := network.ListenPacket(addrA)
+		Expect(err).To(MatchError(errAddressInUse))
+	})
+
+	It("sends packets over the links", func() {
+		n, err := connA.WriteTo([]byte("foobar"), addrB)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+		network.Clock().Advance(10 * time.Millisecond)
+		b := make([]byte, 100)
+		n, from, err := connB.ReadFrom(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b[:n]).To(Equal([]byte("foobar")))
+		Expect(from).To(Equal(addrA))
+		Expect(linkAB.Stats().SentPackets).To(Equal(uint64(1)))
+		Expect(linkBA.Stats().SentPackets).To(BeZero())
+	})
+
+	It("hands packets to the receive handler", func() {
+		var received []byte
+		connB.SetReceiveHandler(func(data []byte, from net.Addr) {
+			received = data
+			Expect(from).To(Equal(addrA))
+		})
+		connA.WriteTo([]byte("foobar"), addrB)
+		network.Clock().Run()
+		Expect(received).To(Equal([]byte("foobar")))
+	})
+
+	It("drops packets to addresses without a link", func() {
+		n, err := connA.WriteTo([]byte("foobar"), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 3000})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+		_, ok := network.Clock().NextDeadline()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("unblocks ReadFrom when closed", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			_, _, err := connB.ReadFrom(make([]byte, 100))
+			Expect(err).To(MatchError(errClosed))
+			close(done)
+		}()
+		Consistently(done).ShouldNot(BeClosed())
+		Expect(connB.Close()).To(Succeed())
+		Eventually(done).Should(BeClosed())
+		_, err := connB.WriteTo([]byte("foobar"), addrA)
+		Expect(err).To(MatchError(errClosed))
+	})
+})
@@ -0,0 +1,13 @@
+package simnet
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSimnet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Simnet Suite")
+}
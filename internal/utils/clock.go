@@ -0,0 +1,27 @@
+package utils
+
+import "time"
+
+// A Clock tells the time and calls functions after a duration.
+// Sessions use the system clock, simulations replace it by a virtual clock.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once d elapsed, possibly in another goroutine.
+	// The returned function stops the timer, it returns false if f was already called.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock of the operating system
+type SystemClock struct{}
+
+var _ Clock = SystemClock{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f in its own goroutine once d elapsed
+func (SystemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
@@ -1,12 +1,23 @@
 package utils
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // A Timer wrapper that behaves correctly when resetting
 type Timer struct {
 	t        *time.Timer
 	read     bool
 	deadline time.Time
+
+	// clock is set if the timer runs on a Clock, see NewClockTimer
+	clock Clock
+	mutex sync.Mutex
+	c     chan time.Time
+	stop  func() bool
+	// generation is incremented on every reset, such that a timer that couldn't be stopped doesn't fire anymore
+	generation uint64
 }
 
 // NewTimer creates a new timer that is not set
@@ -14,8 +25,20 @@ func NewTimer() *Timer {
 	return &Timer{t: time.NewTimer(0)}
 }
 
+// NewClockTimer creates a new timer running on the clock that is not set.
+// If the clock is nil, the timer runs on the system clock.
+func NewClockTimer(clock Clock) *Timer {
+	if clock == nil {
+		return NewTimer()
+	}
+	return &Timer{clock: clock, c: make(chan time.Time, 1)}
+}
+
 // Chan returns the channel of the wrapped timer
 func (t *Timer) Chan() <-chan time.Time {
+	if t.clock != nil {
+		return t.c
+	}
 	return t.t.C
 }
 
@@ -26,17 +49,48 @@ func (t *Timer) Reset(deadline time.Time) {
 		return
 	}
 
-	// We need to drain the timer if the value from its channel was not read yet.
-	// See https://groups.google.com/forum/#!topic/golang-dev/c9UUfASVPoU
-	if !t.t.Stop() && !t.read {
-		<-t.t.C
+	if t.clock != nil {
+		t.resetOnClock(deadline)
+	} else {
+		// We need to drain the timer if the value from its channel was not read yet.
+		// See https://groups.google.com/forum/#!topic/golang-dev/c9UUfASVPoU
+		if !t.t.Stop() && !t.read {
+			<-t.t.C
+		}
+		t.t.Reset(deadline.Sub(time.Now()))
 	}
-	t.t.Reset(deadline.Sub(time.Now()))
 
 	t.read = false
 	t.deadline = deadline
 }
 
+func (t *Timer) resetOnClock(deadline time.Time) {
+	t.mutex.Lock()
+	if t.stop != nil {
+		t.stop()
+	}
+	t.generation++
+	generation := t.generation
+	// Drop a value that was not read yet
+	select {
+	case <-t.c:
+	default:
+	}
+	t.mutex.Unlock()
+
+	t.stop = t.clock.AfterFunc(deadline.Sub(t.clock.Now()), func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if t.generation != generation {
+			return
+		}
+		select {
+		case t.c <- t.clock.Now():
+		default:
+		}
+	})
+}
+
 // SetRead should be called after the value from the chan was read
 func (t *Timer) SetRead() {
 	t.read = true
@@ -10,36 +10,56 @@ import (
 var _ = Describe("Timer", func() {
 	const d = 10 * time.Millisecond
 
-	It("works", func() {
-		t := NewTimer()
-		t.Reset(time.Now().Add(d))
-		Eventually(t.Chan()).Should(Receive())
-	})
+	for _, tc := range []struct {
+		name     string
+		newTimer func() *Timer
+	}{
+		{"the system timer", NewTimer},
+		{"a timer on a clock", func() *Timer { return NewClockTimer(SystemClock{}) }},
+	} {
+		tc := tc
 
-	It("works multiple times with reading", func() {
-		t := NewTimer()
-		for i := 0; i < 10; i++ {
-			t.Reset(time.Now().Add(d))
-			Eventually(t.Chan()).Should(Receive())
-			t.SetRead()
-		}
-	})
+		Context(tc.name, func() {
+			It("works", func() {
+				t := tc.newTimer()
+				t.Reset(time.Now().Add(d))
+				Eventually(t.Chan()).Should(Receive())
+			})
 
-	It("works multiple times without reading", func() {
-		t := NewTimer()
-		for i := 0; i < 10; i++ {
-			t.Reset(time.Now().Add(d))
-			time.Sleep(d * 2)
-		}
-		Eventually(t.Chan()).Should(Receive())
-	})
+			It("works multiple times with reading", func() {
+				t := tc.newTimer()
+				for i := 0; i < 10; i++ {
+					t.Reset(time.Now().Add(d))
+					Eventually(t.Chan()).Should(Receive())
+					t.SetRead()
+				}
+			})
+
+			It("works multiple times without reading", func() {
+				t := tc.newTimer()
+				for i := 0; i < 10; i++ {
+					t.Reset(time.Now().Add(d))
+					time.Sleep(d * 2)
+				}
+				Eventually(t.Chan()).Should(Receive())
+			})
+
+			It("works when resetting without expiration", func() {
+				t := tc.newTimer()
+				for i := 0; i < 10; i++ {
+					t.Reset(time.Now().Add(time.Hour))
+				}
+				t.Reset(time.Now().Add(d))
+				Eventually(t.Chan()).Should(Receive())
+			})
+		})
+	}
 
-	It("works when resetting without expiration", func() {
-		t := NewTimer()
-		for i := 0; i < 10; i++ {
-			t.Reset(time.Now().Add(time.Hour))
-		}
+	It("doesn't fire for an earlier deadline after a reset", func() {
+		t := NewClockTimer(SystemClock{})
 		t.Reset(time.Now().Add(d))
-		Eventually(t.Chan()).Should(Receive())
+		time.Sleep(d * 2)
+		t.Reset(time.Now().Add(time.Hour))
+		Consistently(t.Chan(), d*2).ShouldNot(Receive())
 	})
 })
@@ -24,6 +24,14 @@ func LogFrame(frame Frame, sent bool) {
 		utils.Debugf("\t%s &wire.AckFrame{PathID: 0x%x, LargestAcked: 0x%x, LowestAcked: 0x%x, AckRanges: %#v, DelayTime: %s}", dir, f.PathID, f.LargestAcked, f.LowestAcked, f.AckRanges, f.DelayTime.String())
 	case *AddAddressFrame:
 		utils.Debugf("\t%s &wire.AddAddressFrame{IPVersion: %d, Addr: %s}", dir, f.IPVersion, f.Addr.String())
+	case *RemoveAddressFrame:
+		utils.Debugf("\t%s &wire.RemoveAddressFrame{IPVersion: %d, Addr: %s}", dir, f.IPVersion, f.Addr.String())
+	case *PathChallengeFrame:
+		utils.Debugf("\t%s &wire.PathChallengeFrame{Data: %#x}", dir, f.Data)
+	case *PathResponseFrame:
+		utils.Debugf("\t%s &wire.PathResponseFrame{Data: %#x}", dir, f.Data)
+	case *PathStatusFrame:
+		utils.Debugf("\t%s &wire.PathStatusFrame{PathID: 0x%x, StatusSequence: %d, Backup: %t}", dir, f.PathID, f.StatusSequence, f.Backup)
 	case *ClosePathFrame:
 		utils.Debugf("\t%s &wire.ClosePathFrame{PathID: 0x%x, LargestAcked: 0x%x, LowestAcked: 0x%x, AckRanges: %#v}", dir, f.PathID, f.LargestAcked, f.LowestAcked, f.AckRanges)
 	default:
@@ -0,0 +1,44 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A PathResponseFrame answers a PathChallengeFrame, echoing its data
+type PathResponseFrame struct {
+	Data [8]byte
+}
+
+// ParsePathResponseFrame parses a PATH_RESPONSE frame
+func ParsePathResponseFrame(r *bytes.Reader, version protocol.VersionNumber) (*PathResponseFrame, error) {
+	frame := &PathResponseFrame{}
+
+	// read the TypeByte
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(r, frame.Data[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func (f *PathResponseFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	typeByte := uint8(0x16)
+	b.WriteByte(typeByte)
+	b.Write(f.Data[:])
+	return nil
+}
+
+// MinLength of a written frame
+func (f *PathResponseFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	return 1 + 8, nil
+}
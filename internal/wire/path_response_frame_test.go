@@ -0,0 +1,47 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PathResponseFrame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			b := bytes.NewReader([]byte{0x16, 1, 2, 3, 4, 5, 6, 7, 8})
+			frame, err := ParsePathResponseFrame(b, protocol.VersionWhatever)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Data).To(Equal([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x16, 1, 2, 3, 4, 5, 6, 7, 8}
+			_, err := ParsePathResponseFrame(bytes.NewReader(data), protocol.VersionWhatever)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParsePathResponseFrame(bytes.NewReader(data[0:i]), protocol.VersionWhatever)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+			Expect(frame.Write(b, protocol.VersionWhatever)).To(Succeed())
+			Expect(b.Bytes()).To(Equal([]byte{0x16, 1, 2, 3, 4, 5, 6, 7, 8}))
+		})
+
+		It("has the correct min length", func() {
+			b := &bytes.Buffer{}
+			frame := PathResponseFrame{}
+			Expect(frame.Write(b, protocol.VersionWhatever)).To(Succeed())
+			Expect(frame.MinLength(protocol.VersionWhatever)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const pathStatusBackupFlag = 0x01
+
+// A PathStatusFrame announces the priority of a path
+type PathStatusFrame struct {
+	PathID protocol.PathID
+	// StatusSequence increases with every status sent for the path, such that reordered frames can be ignored
+	StatusSequence uint32
+	// Backup paths should only be used if no other path works
+	Backup bool
+}
+
+// ParsePathStatusFrame parses a PATH_STATUS frame
+func ParsePathStatusFrame(r *bytes.Reader, version protocol.VersionNumber) (*PathStatusFrame, error) {
+	frame := &PathStatusFrame{}
+
+	// read the TypeByte
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	pathID, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	frame.PathID = protocol.PathID(pathID)
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	frame.Backup = flags&pathStatusBackupFlag != 0
+
+	seq, err := utils.GetByteOrder(version).ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	frame.StatusSequence = seq
+
+	return frame, nil
+}
+
+func (f *PathStatusFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	typeByte := uint8(0x13)
+	b.WriteByte(typeByte)
+	b.WriteByte(uint8(f.PathID))
+	var flags uint8
+	if f.Backup {
+		flags |= pathStatusBackupFlag
+	}
+	b.WriteByte(flags)
+	utils.GetByteOrder(version).WriteUint32(b, f.StatusSequence)
+	return nil
+}
+
+// MinLength of a written frame
+func (f *PathStatusFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	return 1 + 1 + 1 + 4, nil
+}
@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PathStatusFrame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			b := bytes.NewReader([]byte{0x13, 0x3, 0x1, 0x0, 0x0, 0x0, 0x2a})
+			frame, err := ParsePathStatusFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.PathID).To(Equal(protocol.PathID(3)))
+			Expect(frame.Backup).To(BeTrue())
+			Expect(frame.StatusSequence).To(Equal(uint32(42)))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("parses an active path", func() {
+			b := bytes.NewReader([]byte{0x13, 0x3, 0x0, 0x2a, 0x0, 0x0, 0x0})
+			frame, err := ParsePathStatusFrame(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Backup).To(BeFalse())
+			Expect(frame.StatusSequence).To(Equal(uint32(42)))
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x13, 0x3, 0x1, 0x0, 0x0, 0x0, 0x2a}
+			_, err := ParsePathStatusFrame(bytes.NewReader(data), protocol.VersionWhatever)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParsePathStatusFrame(bytes.NewReader(data[0:i]), protocol.VersionWhatever)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := PathStatusFrame{PathID: 3, StatusSequence: 42, Backup: true}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(b.Bytes()).To(Equal([]byte{0x13, 0x3, 0x1, 0x0, 0x0, 0x0, 0x2a}))
+		})
+
+		It("has the correct min length", func() {
+			b := &bytes.Buffer{}
+			frame := PathStatusFrame{PathID: 3}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(frame.MinLength(versionBigEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package wire
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A RemoveAddressFrame withdraws an address previously advertised with an AddAddressFrame
+type RemoveAddressFrame struct {
+	IPVersion uint8
+	Addr      net.UDPAddr
+}
+
+// ParseRemoveAddressFrame parses a REMOVE_ADDRESS frame
+func ParseRemoveAddressFrame(r *bytes.Reader, version protocol.VersionNumber) (*RemoveAddressFrame, error) {
+	frame := &RemoveAddressFrame{}
+
+	// read the TypeByte
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	ipv, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	frame.IPVersion = ipv
+
+	var ipLen int
+	switch frame.IPVersion {
+	case 4:
+		ipLen = net.IPv4len
+	case 6:
+		ipLen = net.IPv6len
+	default:
+		return nil, ErrUnknownIPVersion
+	}
+	ip := make([]byte, ipLen)
+	for i := range ip {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		ip[i] = b
+	}
+	if frame.IPVersion == 4 {
+		frame.Addr.IP = net.IPv4(ip[0], ip[1], ip[2], ip[3])
+	} else {
+		frame.Addr.IP = net.IP(ip)
+	}
+
+	port, err := utils.GetByteOrder(version).ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+	frame.Addr.Port = int(port)
+
+	return frame, nil
+}
+
+func (f *RemoveAddressFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	typeByte := uint8(0x14)
+	b.WriteByte(typeByte)
+	b.WriteByte(f.IPVersion)
+
+	var ip net.IP
+	switch f.IPVersion {
+	case 4:
+		ip = f.Addr.IP.To4()
+	case 6:
+		ip = f.Addr.IP.To16()
+	default:
+		return ErrUnknownIPVersion
+	}
+	if ip == nil {
+		return errInconsistentAddrIPVersion
+	}
+	b.Write(ip)
+
+	utils.GetByteOrder(version).WriteUint16(b, uint16(f.Addr.Port))
+
+	return nil
+}
+
+// MinLength of a written frame
+func (f *RemoveAddressFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	switch f.IPVersion {
+	case 4:
+		return 1 + 1 + 4 + 2, nil
+	case 6:
+		return 1 + 1 + 16 + 2, nil
+	default:
+		return 0, ErrUnknownIPVersion
+	}
+}
@@ -0,0 +1,70 @@
+package wire
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RemoveAddressFrame", func() {
+	Context("when parsing", func() {
+		It("accepts an IPv4 address", func() {
+			b := bytes.NewReader([]byte{0x14, 0x4, 0xa, 0x0, 0x0, 0x1, 0x1, 0xbb})
+			frame, err := ParseRemoveAddressFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.IPVersion).To(Equal(uint8(4)))
+			Expect(frame.Addr.IP.Equal(net.IPv4(10, 0, 0, 1))).To(BeTrue())
+			Expect(frame.Addr.Port).To(Equal(443))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("accepts an IPv6 address", func() {
+			data := append([]byte{0x14, 0x6}, net.IPv6loopback...)
+			data = append(data, 0x1, 0xbb)
+			frame, err := ParseRemoveAddressFrame(bytes.NewReader(data), versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Addr.IP.Equal(net.IPv6loopback)).To(BeTrue())
+			Expect(frame.Addr.Port).To(Equal(443))
+		})
+
+		It("errors on unknown IP versions", func() {
+			_, err := ParseRemoveAddressFrame(bytes.NewReader([]byte{0x14, 0x5}), versionBigEndian)
+			Expect(err).To(MatchError(ErrUnknownIPVersion))
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x14, 0x4, 0xa, 0x0, 0x0, 0x1, 0x1, 0xbb}
+			_, err := ParseRemoveAddressFrame(bytes.NewReader(data), protocol.VersionWhatever)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParseRemoveAddressFrame(bytes.NewReader(data[0:i]), protocol.VersionWhatever)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes an IPv4 address", func() {
+			b := &bytes.Buffer{}
+			frame := RemoveAddressFrame{IPVersion: 4, Addr: net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(b.Bytes()).To(Equal([]byte{0x14, 0x4, 0xa, 0x0, 0x0, 0x1, 0x1, 0xbb}))
+			Expect(frame.MinLength(versionBigEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+
+		It("writes an IPv6 address", func() {
+			b := &bytes.Buffer{}
+			frame := RemoveAddressFrame{IPVersion: 6, Addr: net.UDPAddr{IP: net.IPv6loopback, Port: 443}}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(frame.MinLength(versionBigEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+
+		It("refuses addresses not matching the IP version", func() {
+			frame := RemoveAddressFrame{IPVersion: 4, Addr: net.UDPAddr{IP: net.IPv6loopback}}
+			Expect(frame.Write(&bytes.Buffer{}, versionBigEndian)).To(MatchError(errInconsistentAddrIPVersion))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package quic
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A fileMetricsSink writes the measurements of a session to files in a directory:
+// the timestamps of STREAM frames to Lat_send_F.log, Lat_recv_F.log and Lat_read_F.log,
+// the frames carrying messages to sender-frame.log and receiver-frame.log,
+// the send rates to P<pathID>_send.log, and the scheduler statistics to scheduler_stats.json
+type fileMetricsSink struct {
+	mutex sync.Mutex
+
+	dir    string
+	files  map[string]*os.File
+	closed bool
+}
+
+var _ MetricsSink = &fileMetricsSink{}
+
+// NewFileMetricsSink creates a MetricsSink writing the measurements of a session to files in dir.
+// The directory is created if it doesn't exist yet.
+func NewFileMetricsSink(dir string) (MetricsSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileMetricsSink{
+		dir:   dir,
+		files: make(map[string]*os.File),
+	}, nil
+}
+
+// MetricsDir returns a function for Config.MetricsSink, that writes the measurements of every session to its own
+// directory in dir. The directories are named after the connection ID and the perspective, e.g. "0123456789abcdef_client".
+// Sessions are not measured if the directory can't be created.
+func MetricsDir(dir string) func(isClient bool, connectionID ConnectionID) MetricsSink {
+	return func(isClient bool, connectionID ConnectionID) MetricsSink {
+		perspective := "server"
+		if isClient {
+			perspective = "client"
+		}
+		sink, err := NewFileMetricsSink(filepath.Join(dir, fmt.Sprintf("%016x_%s", uint64(connectionID), perspective)))
+		if err != nil {
+			utils.Errorf("Not measuring connection %x: %s", connectionID, err.Error())
+			return nil
+		}
+		return sink
+	}
+}
+
+// defaultMetricsSink returns the MetricsSink of sessions not setting Config.MetricsSink
+func defaultMetricsSink() func(isClient bool, connectionID ConnectionID) MetricsSink {
+	if LogPayload {
+		return MetricsDir(".")
+	}
+	return nil
+}
+
+func (m *fileMetricsSink) SentStreamFrame(frame StreamFrameMetric) {
+	m.writeLatency("Lat_send_F.log", frame)
+	m.writeMessage("sender-frame.log", frame)
+}
+
+func (m *fileMetricsSink) ReceivedStreamFrame(frame StreamFrameMetric) {
+	m.writeLatency("Lat_recv_F.log", frame)
+	m.writeMessage("receiver-frame.log", frame)
+}
+
+func (m *fileMetricsSink) ReadStreamFrame(frame StreamFrameMetric) {
+	m.writeLatency("Lat_read_F.log", frame)
+}
+
+func (m *fileMetricsSink) SendRate(pathID PathID, sinceStart time.Duration, rate float64, bytesInFlight ByteCount) {
+	m.write(fmt.Sprintf("P%d_send.log", pathID), fmt.Sprintf("%s;%s;%d\n",
+		strconv.FormatFloat(float64(sinceStart)/1e6, 'f', -1, 64),
+		strconv.FormatFloat(rate, 'g', -1, 64),
+		bytesInFlight,
+	))
+}
+
+func (m *fileMetricsSink) UpdatedSchedulerStats(stats SchedulerStats) {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		utils.Errorf("Failed to encode the scheduler statistics: %s", err.Error())
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(m.dir, "scheduler_stats.json"), data, 0644); err != nil {
+		utils.Errorf("Failed to write the scheduler statistics: %s", err.Error())
+	}
+}
+
+func (m *fileMetricsSink) Close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	for name, f := range m.files {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil {
+			utils.Errorf("Failed to close %s: %s", name, err.Error())
+		}
+	}
+}
+
+// writeLatency writes the stream ID, offset and timestamp of a frame, used to calculate frame latencies
+func (m *fileMetricsSink) writeLatency(name string, frame StreamFrameMetric) {
+	m.write(name, fmt.Sprintf("%d;%d;%d\n", frame.StreamID, frame.Offset, frame.Time.UnixNano()))
+}
+
+// writeMessage writes a frame carrying a message of the traffic generator, the first 4 bytes of its data are the message ID
+func (m *fileMetricsSink) writeMessage(name string, frame StreamFrameMetric) {
+	if len(frame.Data) <= 8 {
+		return
+	}
+	m.write(name, fmt.Sprintf("%d %d %d %d %d %d\n",
+		frame.PathID, frame.PacketNumber, frame.StreamID, frame.Offset, binary.BigEndian.Uint32(frame.Data[0:4]), frame.Time.UnixNano()))
+}
+
+func (m *fileMetricsSink) write(name string, line string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return
+	}
+	f, ok := m.files[name]
+	if !ok {
+		var err error
+		f, err = os.OpenFile(filepath.Join(m.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			utils.Errorf("Failed to open %s: %s", name, err.Error())
+		}
+		// a file that can't be opened is stored as nil, such that it isn't tried again for every line
+		m.files[name] = f
+	}
+	if f == nil {
+		return
+	}
+	if _, err := f.WriteString(line); err != nil {
+		utils.Errorf("Failed to write %s: %s", name, err.Error())
+	}
+}
@@ -0,0 +1,190 @@
+// Package metrics exports the counters of QUIC listeners and the state of the paths of their sessions
+// in the OpenMetrics text format, e.g. to be scraped by Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// ContentType is the content type of the OpenMetrics text format
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// An Exporter exposes the metrics of listeners through an http.Handler.
+//
+// Every listener exports the counters sessions_accepted, handshakes_failed and public_resets_sent,
+// and the gauge active_paths. Every open path of its sessions exports gauges for the smoothed RTT,
+// the congestion window, the bytes in flight, the send rate and the ratio of duplicated stream bytes.
+type Exporter struct {
+	mutex sync.Mutex
+
+	listeners map[string]quic.Listener
+	// sent are the stream bytes sent on the paths at the last scrape, used to calculate the send rates
+	sent map[pathKey]sentSample
+
+	now func() time.Time
+}
+
+var _ http.Handler = &Exporter{}
+
+type pathKey struct {
+	listener     string
+	connectionID quic.ConnectionID
+	pathID       quic.PathID
+}
+
+type sentSample struct {
+	bytes uint64
+	time  time.Time
+}
+
+// NewExporter creates a new Exporter without any listeners
+func NewExporter() *Exporter {
+	return &Exporter{
+		listeners: make(map[string]quic.Listener),
+		sent:      make(map[pathKey]sentSample),
+		now:       time.Now,
+	}
+}
+
+// AddListener exports the metrics of a listener, with the label listener set to name.
+// A listener that was added with the same name before is replaced.
+func (e *Exporter) AddListener(name string, ln quic.Listener) {
+	e.mutex.Lock()
+	e.listeners[name] = ln
+	e.mutex.Unlock()
+}
+
+// RemoveListener stops exporting the metrics of a listener
+func (e *Exporter) RemoveListener(name string) {
+	e.mutex.Lock()
+	delete(e.listeners, name)
+	e.mutex.Unlock()
+}
+
+// ServeHTTP writes the metrics of all listeners
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = e.WriteMetrics(w)
+}
+
+// WriteMetrics writes the metrics of all listeners to w.
+// The send rates are averaged over the time since the previous call, they are not written by the first one.
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	names := make([]string, 0, len(e.listeners))
+	for name := range e.listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		accepted    = family{name: "quic_listener_sessions_accepted", typ: "counter", help: "Sessions that completed the handshake."}
+		failed      = family{name: "quic_listener_handshakes_failed", typ: "counter", help: "Sessions that were closed before completing the handshake."}
+		resets      = family{name: "quic_listener_public_resets_sent", typ: "counter", help: "Public Reset packets sent by the listener and its sessions."}
+		activePaths = family{name: "quic_listener_active_paths", typ: "gauge", help: "Open paths of all sessions."}
+		rtt         = family{name: "quic_path_smoothed_rtt_seconds", typ: "gauge", unit: "seconds", help: "Smoothed RTT of the path."}
+		cwnd        = family{name: "quic_path_congestion_window_bytes", typ: "gauge", unit: "bytes", help: "Congestion window of the path."}
+		inFlight    = family{name: "quic_path_in_flight_bytes", typ: "gauge", unit: "bytes", help: "Bytes in flight on the path."}
+		sendRate    = family{name: "quic_path_send_rate_bytes_per_second", typ: "gauge", help: "Stream bytes sent on the path per second, since the previous scrape."}
+		dupRatio    = family{name: "quic_path_duplicate_ratio", typ: "gauge", help: "Fraction of the stream bytes sent on the path that were copies of packets sent on other paths."}
+	)
+
+	now := e.now()
+	sent := make(map[pathKey]sentSample)
+	for _, name := range names {
+		stats := e.listeners[name].Stats()
+		labels := fmt.Sprintf(`listener="%s"`, escapeLabel(name))
+		accepted.add(labels, float64(stats.SessionsAccepted))
+		failed.add(labels, float64(stats.HandshakesFailed))
+		resets.add(labels, float64(stats.PublicResetsSent))
+
+		var open int
+		for _, sess := range stats.Sessions {
+			for _, pth := range sess.Paths {
+				if !pth.Open {
+					continue
+				}
+				open++
+				pathLabels := fmt.Sprintf(`%s,connection_id="%016x",path_id="%d"`, labels, uint64(sess.ConnectionID), pth.PathID)
+				rtt.add(pathLabels, pth.SmoothedRTT.Seconds())
+				cwnd.add(pathLabels, float64(pth.CongestionWindow))
+				inFlight.add(pathLabels, float64(pth.BytesInFlight))
+
+				key := pathKey{listener: name, connectionID: sess.ConnectionID, pathID: pth.PathID}
+				sent[key] = sentSample{bytes: pth.SentStreamBytes, time: now}
+				if last, ok := e.sent[key]; ok && now.After(last.time) && pth.SentStreamBytes >= last.bytes {
+					sendRate.add(pathLabels, float64(pth.SentStreamBytes-last.bytes)/now.Sub(last.time).Seconds())
+				}
+
+				var ratio float64
+				if pth.SentStreamBytes > 0 {
+					ratio = float64(pth.DuplicatedStreamBytes) / float64(pth.SentStreamBytes)
+				}
+				dupRatio.add(pathLabels, ratio)
+			}
+		}
+		activePaths.add(labels, float64(open))
+	}
+	// forget the paths that were closed
+	e.sent = sent
+
+	bw := bufio.NewWriter(w)
+	for _, f := range []*family{&accepted, &failed, &resets, &activePaths, &rtt, &cwnd, &inFlight, &sendRate, &dupRatio} {
+		f.write(bw)
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// A family is a metric family, and its samples
+type family struct {
+	name string
+	typ  string
+	unit string
+	help string
+
+	samples []sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func (f *family) add(labels string, value float64) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+func (f *family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	if f.unit != "" {
+		fmt.Fprintf(w, "# UNIT %s %s\n", f.name, f.unit)
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	name := f.name
+	// the samples of counters carry the suffix _total
+	if f.typ == "counter" {
+		name += "_total"
+	}
+	for _, s := range f.samples {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockListener struct {
+	stats quic.ListenerStats
+}
+
+var _ quic.Listener = &mockListener{}
+
+func (*mockListener) Close() error                  { return nil }
+func (*mockListener) Addr() net.Addr                { return nil }
+func (*mockListener) Accept() (quic.Session, error) { panic("not implemented") }
+func (l *mockListener) Stats() quic.ListenerStats   { return l.stats }
+
+var _ = Describe("Exporter", func() {
+	var (
+		exporter *Exporter
+		ln       *mockListener
+		now      time.Time
+	)
+
+	scrape := func() string {
+		buf := &bytes.Buffer{}
+		ExpectWithOffset(1, exporter.WriteMetrics(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(1000, 0)
+		exporter = NewExporter()
+		exporter.now = func() time.Time { return now }
+		ln = &mockListener{stats: quic.ListenerStats{
+			SessionsAccepted: 3,
+			HandshakesFailed: 1,
+			PublicResetsSent: 2,
+			Sessions: []quic.SessionStats{{
+				ConnectionID: 0xdeadbeef,
+				Paths: []quic.PathStats{
+					{PathID: 0, Open: true, SmoothedRTT: 25 * time.Millisecond, CongestionWindow: 14600, BytesInFlight: 1200, SentStreamBytes: 1000},
+					{PathID: 1, Open: true, SentStreamBytes: 4000, DuplicatedStreamBytes: 1000},
+					{PathID: 2, Open: false},
+				},
+			}},
+		}}
+		exporter.AddListener("main", ln)
+	})
+
+	It("writes the counters of the listeners", func() {
+		metrics := scrape()
+		Expect(metrics).To(ContainSubstring("# TYPE quic_listener_sessions_accepted counter\n"))
+		Expect(metrics).To(ContainSubstring(`quic_listener_sessions_accepted_total{listener="main"} 3` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_listener_handshakes_failed_total{listener="main"} 1` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_listener_public_resets_sent_total{listener="main"} 2` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_listener_active_paths{listener="main"} 2` + "\n"))
+		Expect(metrics).To(HaveSuffix("# EOF\n"))
+	})
+
+	It("writes the gauges of the open paths", func() {
+		metrics := scrape()
+		Expect(metrics).To(ContainSubstring("# UNIT quic_path_smoothed_rtt_seconds seconds\n"))
+		Expect(metrics).To(ContainSubstring(`quic_path_smoothed_rtt_seconds{listener="main",connection_id="00000000deadbeef",path_id="0"} 0.025` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_path_congestion_window_bytes{listener="main",connection_id="00000000deadbeef",path_id="0"} 14600` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_path_in_flight_bytes{listener="main",connection_id="00000000deadbeef",path_id="0"} 1200` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_path_duplicate_ratio{listener="main",connection_id="00000000deadbeef",path_id="1"} 0.25` + "\n"))
+		Expect(metrics).ToNot(ContainSubstring(`path_id="2"`))
+	})
+
+	It("calculates the send rates since the previous scrape", func() {
+		Expect(scrape()).ToNot(ContainSubstring("quic_path_send_rate_bytes_per_second{"))
+		now = now.Add(2 * time.Second)
+		ln.stats.Sessions[0].Paths[0].SentStreamBytes = 3000
+		metrics := scrape()
+		Expect(metrics).To(ContainSubstring(`quic_path_send_rate_bytes_per_second{listener="main",connection_id="00000000deadbeef",path_id="0"} 1000` + "\n"))
+		Expect(metrics).To(ContainSubstring(`quic_path_send_rate_bytes_per_second{listener="main",connection_id="00000000deadbeef",path_id="1"} 0` + "\n"))
+	})
+
+	It("writes every metric family once", func() {
+		exporter.AddListener("second", &mockListener{})
+		var types []string
+		for _, line := range strings.Split(scrape(), "\n") {
+			if strings.HasPrefix(line, "# TYPE ") {
+				types = append(types, line)
+			}
+		}
+		Expect(types).To(HaveLen(9))
+		Expect(scrape()).To(ContainSubstring(`quic_listener_active_paths{listener="second"} 0` + "\n"))
+	})
+
+	It("removes listeners", func() {
+		exporter.RemoveListener("main")
+		Expect(scrape()).ToNot(ContainSubstring(`listener="main"`))
+	})
+
+	It("escapes the names of the listeners", func() {
+		exporter.AddListener("a \"quoted\"\\name", &mockListener{})
+		Expect(scrape()).To(ContainSubstring(`quic_listener_active_paths{listener="a \"quoted\"\\name"} 0`))
+	})
+
+	It("serves the metrics over HTTP", func() {
+		w := httptest.NewRecorder()
+		exporter.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(w.Header().Get("Content-Type")).To(Equal(ContentType))
+		Expect(w.Body.String()).To(ContainSubstring(`quic_listener_sessions_accepted_total{listener="main"} 3`))
+	})
+})
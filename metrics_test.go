@@ -0,0 +1,202 @@
+package quic
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockMetricsSink struct {
+	mutex sync.Mutex
+
+	sentFrames     []StreamFrameMetric
+	receivedFrames []StreamFrameMetric
+	readFrames     []StreamFrameMetric
+	schedulerStats []SchedulerStats
+	closed         bool
+}
+
+var _ MetricsSink = &mockMetricsSink{}
+
+func (m *mockMetricsSink) SentStreamFrame(frame StreamFrameMetric) {
+	m.mutex.Lock()
+	m.sentFrames = append(m.sentFrames, frame)
+	m.mutex.Unlock()
+}
+
+func (m *mockMetricsSink) ReceivedStreamFrame(frame StreamFrameMetric) {
+	m.mutex.Lock()
+	m.receivedFrames = append(m.receivedFrames, frame)
+	m.mutex.Unlock()
+}
+
+func (m *mockMetricsSink) ReadStreamFrame(frame StreamFrameMetric) {
+	m.mutex.Lock()
+	m.readFrames = append(m.readFrames, frame)
+	m.mutex.Unlock()
+}
+
+func (m *mockMetricsSink) SendRate(PathID, time.Duration, float64, ByteCount) {}
+
+func (m *mockMetricsSink) UpdatedSchedulerStats(stats SchedulerStats) {
+	m.mutex.Lock()
+	m.schedulerStats = append(m.schedulerStats, stats)
+	m.mutex.Unlock()
+}
+
+func (m *mockMetricsSink) Close() {
+	m.mutex.Lock()
+	m.closed = true
+	m.mutex.Unlock()
+}
+
+var _ = Describe("Metrics", func() {
+	Context("writing files", func() {
+		var (
+			dir  string
+			sink MetricsSink
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "metrics")
+			Expect(err).ToNot(HaveOccurred())
+			sink, err = NewFileMetricsSink(dir)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		readFile := func(name string) string {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			ExpectWithOffset(1, err).ToNot(HaveOccurred())
+			return string(data)
+		}
+
+		It("writes the timestamps of frames", func() {
+			sink.SentStreamFrame(StreamFrameMetric{StreamID: 5, Offset: 100, Time: time.Unix(1, 2)})
+			sink.ReceivedStreamFrame(StreamFrameMetric{StreamID: 5, Offset: 100, Time: time.Unix(1, 3)})
+			sink.ReadStreamFrame(StreamFrameMetric{StreamID: 5, Offset: 100, Time: time.Unix(1, 4)})
+			sink.Close()
+			Expect(readFile("Lat_send_F.log")).To(Equal("5;100;1000000002\n"))
+			Expect(readFile("Lat_recv_F.log")).To(Equal("5;100;1000000003\n"))
+			Expect(readFile("Lat_read_F.log")).To(Equal("5;100;1000000004\n"))
+		})
+
+		It("writes frames carrying messages", func() {
+			data := []byte{0, 0, 0x1, 0x2, 0, 0, 0, 0, 0}
+			sink.SentStreamFrame(StreamFrameMetric{PathID: 1, PacketNumber: 7, StreamID: 5, Offset: 100, Data: data, Time: time.Unix(0, 42)})
+			sink.ReceivedStreamFrame(StreamFrameMetric{PathID: 3, PacketNumber: 8, StreamID: 5, Offset: 100, Data: data[:8], Time: time.Unix(0, 43)})
+			sink.Close()
+			Expect(readFile("sender-frame.log")).To(Equal("1 7 5 100 258 42\n"))
+			_, err := os.Stat(filepath.Join(dir, "receiver-frame.log"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("writes the send rates per path", func() {
+			sink.SendRate(1, 1500*time.Millisecond, 12.5, 1200)
+			sink.SendRate(1, 2500*time.Millisecond, 8, 0)
+			sink.SendRate(2, 1500*time.Millisecond, 0, 0)
+			sink.Close()
+			Expect(readFile("P1_send.log")).To(Equal("1500;12.5;1200\n2500;8;0\n"))
+			Expect(readFile("P2_send.log")).To(Equal("1500;0;0\n"))
+		})
+
+		It("replaces the scheduler statistics", func() {
+			sink.UpdatedSchedulerStats(SchedulerStats{TotalSentPackets: 1})
+			sink.UpdatedSchedulerStats(SchedulerStats{
+				TotalSentPackets: 10,
+				PathStats:        []SchedulerPathStats{{PathID: 1, PathIP: "10.0.0.1:4242", SentPackets: 10}},
+			})
+			sink.Close()
+			var stats map[string]interface{}
+			Expect(json.Unmarshal([]byte(readFile("scheduler_stats.json")), &stats)).To(Succeed())
+			Expect(stats).To(HaveKeyWithValue("totalSentPackets", 10.0))
+			Expect(stats["pathStats"]).To(Equal([]interface{}{map[string]interface{}{
+				"pathID":               1.0,
+				"pathIP":               "10.0.0.1:4242",
+				"sendPackets":          10.0,
+				"retransmissions":      0.0,
+				"losses":               0.0,
+				"sentStreamFrameBytes": 0.0,
+				"selectedAsBestPath":   0.0,
+			}}))
+		})
+
+		It("ignores measurements after it was closed", func() {
+			sink.Close()
+			sink.SendRate(1, time.Second, 1, 1)
+			sink.Close()
+			_, err := os.Stat(filepath.Join(dir, "P1_send.log"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("writes a directory per session", func() {
+			client := MetricsDir(dir)(true, 0x1337)
+			server := MetricsDir(dir)(false, 0x1337)
+			Expect(client).ToNot(BeNil())
+			Expect(server).ToNot(BeNil())
+			client.ReadStreamFrame(StreamFrameMetric{StreamID: 3})
+			server.SentStreamFrame(StreamFrameMetric{StreamID: 3})
+			client.Close()
+			server.Close()
+			Expect(readFile(filepath.Join("0000000000001337_client", "Lat_read_F.log"))).ToNot(BeEmpty())
+			Expect(readFile(filepath.Join("0000000000001337_server", "Lat_send_F.log"))).ToNot(BeEmpty())
+		})
+
+		It("doesn't measure sessions if the directory can't be created", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644)).To(Succeed())
+			Expect(MetricsDir(filepath.Join(dir, "file"))(true, 1)).To(BeNil())
+		})
+	})
+
+	Context("sessions", func() {
+		var (
+			sess *session
+			sink *mockMetricsSink
+		)
+
+		BeforeEach(func() {
+			sink = &mockMetricsSink{}
+			sess = &session{metrics: sink}
+		})
+
+		It("measures sent STREAM frames", func() {
+			sess.logPacket(&packedPacket{
+				number: 42,
+				frames: []wire.Frame{&wire.PingFrame{}, &wire.StreamFrame{StreamID: 5, Offset: 100, Data: []byte("foobar")}},
+			}, 2)
+			Expect(sink.sentFrames).To(HaveLen(1))
+			Expect(sink.sentFrames[0].PathID).To(Equal(PathID(2)))
+			Expect(sink.sentFrames[0].PacketNumber).To(Equal(PacketNumber(42)))
+			Expect(sink.sentFrames[0].StreamID).To(Equal(StreamID(5)))
+			Expect(sink.sentFrames[0].Offset).To(Equal(ByteCount(100)))
+			Expect(sink.sentFrames[0].Data).To(Equal([]byte("foobar")))
+		})
+
+		It("measures frames read by the application", func() {
+			sess.readStreamFrame(&wire.StreamFrame{StreamID: 5, Offset: 100})
+			Expect(sink.readFrames).To(HaveLen(1))
+			Expect(sink.readFrames[0].StreamID).To(Equal(StreamID(5)))
+			Expect(sink.readFrames[0].Time).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("doesn't measure anything without a MetricsSink", func() {
+			sess.metrics = nil
+			sess.logPacket(&packedPacket{frames: []wire.Frame{&wire.StreamFrame{StreamID: 5}}}, 1)
+			sess.readStreamFrame(&wire.StreamFrame{StreamID: 5})
+			Expect(sink.sentFrames).To(BeEmpty())
+			Expect(sink.readFrames).To(BeEmpty())
+		})
+	})
+})
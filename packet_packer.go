@@ -2,12 +2,8 @@ package quic
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"os"
-	"time"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
@@ -79,6 +75,38 @@ func (p *packetPacker) PackPing(pf *wire.PingFrame, pth *path) (*packedPacket, e
 	return p.PackPacket(pth)
 }
 
+// PackPathFrame packs a packet that ONLY contains a frame probing the path, i.e. a PathChallengeFrame or a PathResponseFrame
+func (p *packetPacker) PackPathFrame(f wire.Frame, pth *path) (*packedPacket, error) {
+	pth.SetLeastUnacked(pth.sentPacketHandler.GetLeastUnacked())
+	p.controlFrames = append([]wire.Frame{f}, p.controlFrames...)
+	return p.PackPacket(pth)
+}
+
+// PacketLength returns the length of a packet only containing the frames, as packed by PackPathFrame, PackPing or PackAckPacket
+func (p *packetPacker) PacketLength(frames []wire.Frame, pth *path) (protocol.ByteCount, error) {
+	// The length of the packet number depends on the least unacked packet, like when packing
+	pth.SetLeastUnacked(pth.sentPacketHandler.GetLeastUnacked())
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	publicHeader := p.getPublicHeader(encLevel, pth)
+	length, err := publicHeader.GetLength(p.perspective)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range frames {
+		if swf, ok := f.(*wire.StopWaitingFrame); ok {
+			// The STOP_WAITING frame is written with the packet number length of the packet
+			swf.PacketNumber = publicHeader.PacketNumber
+			swf.PacketNumberLen = publicHeader.PacketNumberLen
+		}
+		l, err := f.MinLength(p.version)
+		if err != nil {
+			return 0, err
+		}
+		length += l
+	}
+	return length + protocol.ByteCount(sealer.Overhead()), nil
+}
+
 func (p *packetPacker) PackAckPacket(pth *path) (*packedPacket, error) {
 	if p.ackFrame[pth.pathID] == nil {
 		return nil, errors.New("packet packer BUG: no ack frame queued")
@@ -150,7 +178,10 @@ func (p *packetPacker) PackPacket(pth *path) (*packedPacket, error) {
 	// TODO (QDC): rework this part with PING
 	var isPing bool
 	if len(p.controlFrames) > 0 {
-		_, isPing = p.controlFrames[0].(*wire.PingFrame)
+		switch p.controlFrames[0].(type) {
+		case *wire.PingFrame, *wire.PathChallengeFrame, *wire.PathResponseFrame:
+			isPing = true
+		}
 	}
 
 	var payloadFrames []wire.Frame
@@ -263,7 +294,7 @@ func (p *packetPacker) composeNextPacket(
 	// however, for the last StreamFrame in the packet, we can omit the DataLen, thus saving 2 bytes and yielding a packet of exactly the correct size
 	maxFrameSize += 2
 
-	fs := p.streamFramer.PopStreamFrames(maxFrameSize - payloadLength)
+	fs := p.streamFramer.PopStreamFrames(maxFrameSize-payloadLength, pth.pathID)
 	if len(fs) != 0 {
 		fs[len(fs)-1].DataLenPresent = false
 	}
@@ -336,30 +367,7 @@ func (p *packetPacker) writeAndSealPacket(
 
 	payloadStartIndex := buffer.Len()
 	for _, frame := range payloadFrames {
-		prevLen := buffer.Len()
 		err := frame.Write(buffer, p.version)
-
-		// VUVA: Log frame and message
-		frameByte := buffer.Bytes()[prevLen:buffer.Len()]
-		if frameByte[0]&0x80 == 0x80 && p.perspective == protocol.PerspectiveClient {
-			streamFrame, _ := wire.ParseStreamFrame(bytes.NewReader(frameByte), p.version)
-
-			logfile, err := os.OpenFile("sender-frame.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-
-			if err != nil {
-				panic("cannot create logfile!!")
-			}
-
-			defer logfile.Close()
-			// utils.Debugf("\n path: %d, pk: %d, frame: %x", pth.pathID, publicHeader.PacketNumber, frameByte[4:8])
-			if streamFrame.DataLen() > 8 {
-				io.WriteString(logfile, fmt.Sprintf("%d %d %d %d %d %d\n", pth.pathID, publicHeader.PacketNumber, streamFrame.StreamID, streamFrame.Offset, uint(binary.BigEndian.Uint32(streamFrame.Data[0:4])), uint(time.Now().UnixNano())))
-
-			}
-
-		}
-		//END VUVA
-
 		if err != nil {
 			fmt.Println("ERROR FRAME WRITE")
 			return nil, err
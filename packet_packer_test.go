@@ -73,7 +73,7 @@ var _ = Describe("Packet packer", func() {
 		streamFramer = newStreamFramer(streamsMap, nil)
 
 		pth = &path{
-			sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil, pth.pathID, nil),
+			sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil, pth.pathID, nil, nil, nil),
 			packetNumberGenerator: newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength),
 		}
 
@@ -2,12 +2,8 @@ package quic
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"os"
-	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
@@ -63,20 +59,6 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 					err = qerr.Error(qerr.UnencryptedStreamData, fmt.Sprintf("received unencrypted stream data on stream %d", streamID))
 				}
 			}
-			// VUVA: log received frame
-			streamFrame := frame.(*wire.StreamFrame)
-			logfile, err := os.OpenFile("receiver-frame.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-
-			if err != nil {
-				panic("cannot create logfile!!")
-			}
-
-			defer logfile.Close()
-			if streamFrame.DataLen() > 8 {
-				io.WriteString(logfile, fmt.Sprintf("%d %d %d %d %d %d\n", hdr.PathID, hdr.PacketNumber, streamFrame.StreamID, streamFrame.Offset, uint(binary.BigEndian.Uint32(streamFrame.Data[0:4])), uint(time.Now().UnixNano())))
-
-			}
-			// END VUVA
 		} else if typeByte&0xc0 == 0x40 {
 			frame, err = wire.ParseAckFrame(r, u.version)
 			if err != nil {
@@ -124,6 +106,14 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 				frame, err = wire.ParseClosePathFrame(r, u.version)
 			case 0x12:
 				frame, err = wire.ParsePathsFrame(r, u.version)
+			case 0x13:
+				frame, err = wire.ParsePathStatusFrame(r, u.version)
+			case 0x14:
+				frame, err = wire.ParseRemoveAddressFrame(r, u.version)
+			case 0x15:
+				frame, err = wire.ParsePathChallengeFrame(r, u.version)
+			case 0x16:
+				frame, err = wire.ParsePathResponseFrame(r, u.version)
 			default:
 				err = qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("unknown type byte 0x%x", typeByte))
 			}
@@ -189,6 +189,42 @@ var _ = Describe("Packet unpacker", func() {
 		}))
 	})
 
+	It("unpacks PATH_STATUS frames", func() {
+		setData([]byte{0x13, 0x03, 0x01, 0x01, 0x00, 0x00, 0x00})
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(Equal([]wire.Frame{
+			&wire.PathStatusFrame{PathID: 3, StatusSequence: 1, Backup: true},
+		}))
+	})
+
+	It("unpacks REMOVE_ADDRESS frames", func() {
+		setData([]byte{0x14, 0x04, 0x0a, 0x00, 0x00, 0x01, 0xbb, 0x01})
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(HaveLen(1))
+		frame := packet.frames[0].(*wire.RemoveAddressFrame)
+		Expect(frame.Addr.String()).To(Equal("10.0.0.1:443"))
+	})
+
+	It("accepts PATH_CHALLENGE frames", func() {
+		setData([]byte{0x15, 1, 2, 3, 4, 5, 6, 7, 8})
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(Equal([]wire.Frame{
+			&wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		}))
+	})
+
+	It("accepts PATH_RESPONSE frames", func() {
+		setData([]byte{0x16, 1, 2, 3, 4, 5, 6, 7, 8})
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(Equal([]wire.Frame{
+			&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		}))
+	})
+
 	It("accepts PING frames", func() {
 		setData([]byte{0x07})
 		packet, err := unpacker.Unpack(hdrBin, hdr, data)
@@ -1,6 +1,9 @@
 package quic
 
 import (
+	"fmt"
+	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
@@ -22,7 +25,8 @@ type path struct {
 	conn   connection
 	sess   *session
 
-	rttStats *congestion.RTTStats
+	rttStats   *congestion.RTTStats
+	congestion congestion.SendAlgorithm
 
 	sentPacketHandler     ackhandler.SentPacketHandler
 	receivedPacketHandler ackhandler.ReceivedPacketHandler
@@ -32,6 +36,34 @@ type path struct {
 	runClosed chan struct{}
 
 	potentiallyFailed utils.AtomicBool
+	// reinject is set when the path fails, such that the scheduler copies its packets in flight onto other paths
+	reinject bool
+	// validated is set once the peer proved that it is reachable on the path.
+	// The initial path is validated by the handshake, the other ones by answering a PATH_CHALLENGE.
+	validated utils.AtomicBool
+	// challenge holds the data of the last PATH_CHALLENGE sent on the path
+	challenge      [8]byte
+	challengesSent int
+	// challengeWithheld is set when the anti-amplification budget didn't allow sending a PATH_CHALLENGE,
+	// it is sent once the peer sent enough on the path
+	challengeWithheld bool
+
+	// antiAmplification is set when sending to an address of the peer that was not validated yet,
+	// i.e. on paths opened by the peer and after the peer migrated. Until the path is validated,
+	// this host sends at most protocol.AmplificationFactor times the bytes it received on it.
+	antiAmplification bool
+	bytesSent         protocol.ByteCount
+	bytesReceived     protocol.ByteCount
+	// duplicatedStreamBytes counts the stream bytes of redundant copies sent on the path, it is accessed atomically
+	duplicatedStreamBytes uint64
+	// validatedRemoteAddr is the last validated address of the peer while a migration is validated
+	validatedRemoteAddr net.Addr
+
+	// localBackup is the priority announced by this host, remoteBackup the one announced by the peer
+	localBackup          utils.AtomicBool
+	remoteBackup         utils.AtomicBool
+	statusSequence       uint32
+	remoteStatusSequence uint32
 
 	sentPacket chan struct{}
 
@@ -47,26 +79,28 @@ type path struct {
 
 	lastNetworkActivityTime time.Time
 
+	// tracedState is the congestion state last reported to the tracer of the session
+	tracedState CongestionState
+
 	timer *utils.Timer
 }
 
-// setup for OLIA initializes values that are independent of the perspective
-func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
+// setup initializes values that are independent of the perspective
+// coupling holds the senders of the other paths for coupled congestion control, it may be nil
+func (p *path) setup(coupling *congestionCoupling) {
 	p.rttStats = &congestion.RTTStats{}
+	clock := p.sess.getClock()
 
-	var cong congestion.SendAlgorithm
-
-	if p.sess.version >= protocol.VersionMP && oliaSenders != nil && p.pathID != protocol.InitialPathID && CongestionControl != "cubic" {
-		cong = congestion.NewOliaSender(oliaSenders, p.rttStats, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
-		oliaSenders[p.pathID] = cong.(*congestion.OliaSender)
+	if newCongestionControl, ok := congestionControls[p.sess.congestionControl(p.pathID)]; ok {
+		p.congestion = newCongestionControl(p, coupling)
 	}
-	// When cong is nil, Cubic is used as default
-	sentPacketHandler := ackhandler.NewSentPacketHandler(p.rttStats, cong, p.onRTO, p.pathID, p.sess.scheduler.crossAckHandling)
+	// When p.congestion is nil, Cubic is used as default
+	sentPacketHandler := ackhandler.NewSentPacketHandler(p.rttStats, p.congestion, p.onRTO, p.pathID, p.sess.scheduler.crossAckHandling, p.sess.traceLostPacket, clock)
 
-	now := time.Now()
+	now := clock.Now()
 
 	p.sentPacketHandler = sentPacketHandler
-	p.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(p.sess.version)
+	p.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(p.sess.version, clock)
 
 	p.packetNumberGenerator = newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength)
 
@@ -74,7 +108,7 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 	p.runClosed = make(chan struct{}, 1)
 	p.sentPacket = make(chan struct{}, 1)
 
-	p.timer = utils.NewTimer()
+	p.timer = utils.NewClockTimer(clock)
 	p.lastNetworkActivityTime = now
 
 	p.open.Set(true)
@@ -84,38 +118,31 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 	go p.run()
 }
 
-// Setup2 for Vegas
-func (p *path) setup2(VegasSenders map[protocol.PathID]*congestion.VegasSender) {
-	p.rttStats = &congestion.RTTStats{}
-
-	var cong congestion.SendAlgorithmVegas
-	var checkDup = ackhandler.DupAck
-
-	cong = congestion.NewVegasSender(congestion.DefaultClock{}, p.rttStats, false, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow, checkDup)
-	VegasSenders[p.pathID] = cong.(*congestion.VegasSender)
-
-	// func setup2 can't run without this line of code
-	sentPacketHandlerV := ackhandler.NewVegasSentPacketHandler(p.rttStats, cong, p.onRTO, p.pathID, p.sess.scheduler.crossAckHandling, checkDup)
-
-	now := time.Now()
-
-	p.sentPacketHandler = sentPacketHandlerV
-	p.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(p.sess.version)
-
-	p.packetNumberGenerator = newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength)
-
-	p.closeChan = make(chan *qerr.QuicError, 1)
-	p.runClosed = make(chan struct{}, 1)
-	p.sentPacket = make(chan struct{}, 1)
-
-	p.timer = utils.NewTimer()
-	p.lastNetworkActivityTime = now
-
-	p.open.Set(true)
-	p.potentiallyFailed.Set(false)
-
-	// Once the path is setup, run it
-	go p.run()
+// stats returns a snapshot of the statistics of the path
+func (p *path) stats() PathStats {
+	sntPkts, sntRetrans, sntLost, sntBytes := p.sentPacketHandler.GetStatistics()
+	rcvPkts, rcvBytes := p.receivedPacketHandler.GetStatistics()
+	return PathStats{
+		PathID:               p.pathID,
+		LocalAddr:            p.conn.LocalAddr(),
+		RemoteAddr:           p.conn.RemoteAddr(),
+		Open:                 p.open.Get(),
+		PotentiallyFailed:    p.potentiallyFailed.Get(),
+		Validated:            p.validated.Get(),
+		Backup:               p.isBackup(),
+		SmoothedRTT:          p.rttStats.SmoothedRTT(),
+		MinRTT:               p.rttStats.MinRTT(),
+		CongestionWindow:     p.sentPacketHandler.GetCongestionWindow(),
+		BytesInFlight:        p.sentPacketHandler.GetBytesInFlight(),
+		SentPackets:          sntPkts,
+		SentStreamBytes:      sntBytes,
+		RetransmittedPackets: sntRetrans,
+		LostPackets:          sntLost,
+		ReceivedPackets:      rcvPkts,
+		ReceivedStreamBytes:  rcvBytes,
+
+		DuplicatedStreamBytes: atomic.LoadUint64(&p.duplicatedStreamBytes),
+	}
 }
 
 func (p *path) close() error {
@@ -158,7 +185,45 @@ runLoop:
 }
 
 func (p *path) SendingAllowed() bool {
-	return p.open.Get() && p.sentPacketHandler.SendingAllowed()
+	return p.open.Get() && p.sentPacketHandler.SendingAllowed() && !p.amplificationLimited(protocol.MaxPacketSize)
+}
+
+// PathID returns the ID of the path
+func (p *path) PathID() protocol.PathID {
+	return p.pathID
+}
+
+func (p *path) CongestionFree() bool {
+	return p.sentPacketHandler.CongestionFree()
+}
+
+func (p *path) OvershootFree(pathNum int) bool {
+	return p.sentPacketHandler.OvershootFree(pathNum)
+}
+
+func (p *path) PotentiallyFailed() bool {
+	return p.potentiallyFailed.Get()
+}
+
+func (p *path) SmoothedRTT() time.Duration {
+	return p.rttStats.SmoothedRTT()
+}
+
+func (p *path) MinRTT() time.Duration {
+	return p.rttStats.MinRTT()
+}
+
+func (p *path) CongestionWindow() uint64 {
+	return p.sentPacketHandler.GetCongestionWindow()
+}
+
+func (p *path) BytesInFlight() uint64 {
+	return p.sentPacketHandler.GetBytesInFlight()
+}
+
+// Quota returns the number of packets the scheduler sent on the path
+func (p *path) Quota() uint {
+	return p.sess.scheduler.quotas[p.pathID]
 }
 
 func (p *path) GetStopWaitingFrame(force bool) *wire.StopWaitingFrame {
@@ -193,7 +258,8 @@ func (p *path) maybeResetTimer() {
 		deadline = utils.MinTime(deadline, lossTime)
 	}
 
-	deadline = utils.MinTime(utils.MaxTime(deadline, time.Now().Add(minPathTimer)), time.Now().Add(maxPathTimer))
+	now := p.sess.now()
+	deadline = utils.MinTime(utils.MaxTime(deadline, now.Add(minPathTimer)), now.Add(maxPathTimer))
 
 	p.timer.Reset(deadline)
 }
@@ -223,6 +289,9 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	data := pkt.data
 
 	// We just received a new packet on that path, so it works
+	if p.validated.Get() && p.potentiallyFailed.Get() {
+		p.sess.notifyPathEvent(p, PathValidated, "received a packet after a failure")
+	}
 	p.potentiallyFailed.Set(false)
 
 	// Calculate packet number
@@ -246,14 +315,19 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	if quicErr, ok := err.(*qerr.QuicError); ok && quicErr.ErrorCode == qerr.DecryptionFailure {
 		return err
 	}
-	if p.sess.perspective == protocol.PerspectiveServer {
-		// update the remote address, even if unpacking failed for any other reason than a decryption error
-		p.conn.SetCurrentRemoteAddr(pkt.remoteAddr)
-	}
 	if err != nil {
 		return err
 	}
 
+	// Only follow the newest packets to a new address of the client, e.g. after a NAT rebinding,
+	// such that reordered packets don't move the path back and forth
+	migrated := false
+	if p.sess.perspective == protocol.PerspectiveServer && hdr.PacketNumber > p.largestRcvdPacketNumber &&
+		pkt.remoteAddr != nil && pkt.remoteAddr.String() != p.conn.RemoteAddr().String() {
+		migrated = p.migrate(pkt.remoteAddr)
+	}
+
+	p.bytesReceived += protocol.ByteCount(len(data) + len(hdr.Raw))
 	p.lastRcvdPacketNumber = hdr.PacketNumber
 	// Only do this after decrupting, so we are sure the packet is not attacker-controlled
 	p.largestRcvdPacketNumber = utils.MaxPacketNumber(p.largestRcvdPacketNumber, hdr.PacketNumber)
@@ -264,24 +338,97 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	if err = p.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, isRetransmittable, packetStreamFrameLength); err != nil {
 		return err
 	}
+	if p.sess.tracer != nil {
+		p.sess.tracer.ReceivedPacket(p.pathID, hdr.PacketNumber, protocol.ByteCount(len(data)+len(hdr.Raw)), traceFrames(packet.frames))
+	}
 
 	if err != nil {
 		return err
 	}
 
-	return p.sess.handleFrames(packet.frames, p, pkt.rcvTime)
+	if err = p.sess.handleFrames(packet.frames, p, pkt.rcvTime); err != nil {
+		return err
+	}
+	if migrated || p.challengeWithheld {
+		// The client has to prove that it is reachable on its new address
+		return p.sess.sendPathChallenge(p)
+	}
+	return nil
+}
+
+// migrate moves the path to a new address of the peer. It returns true if the new address has to be validated.
+func (p *path) migrate(remoteAddr net.Addr) bool {
+	oldAddr := p.conn.RemoteAddr()
+	p.conn.SetCurrentRemoteAddr(remoteAddr)
+	// The RTT and the available bandwidth probably changed with the address
+	p.sentPacketHandler.OnConnectionMigration()
+	p.sess.notifyPathEvent(p, PathMigrated, fmt.Sprintf("peer moved from %s to %s", oldAddr, remoteAddr))
+
+	if p.validatedRemoteAddr != nil && p.validatedRemoteAddr.String() == remoteAddr.String() {
+		// Back to the validated address before the migration was validated
+		p.validatedRemoteAddr = nil
+		p.validated.Set(true)
+		return false
+	}
+	if p.validated.Get() {
+		p.validatedRemoteAddr = oldAddr
+	}
+	p.validated.Set(false)
+	p.antiAmplification = true
+	p.bytesSent = 0
+	p.bytesReceived = 0
+	p.challengesSent = 0
+	p.challengeWithheld = false
+	return true
+}
+
+// revertMigration moves the path back to the last validated address of the peer.
+// It returns false if there is no such address.
+func (p *path) revertMigration() bool {
+	if p.validatedRemoteAddr == nil {
+		return false
+	}
+	p.sess.notifyPathEvent(p, PathMigrated, fmt.Sprintf("peer not reachable on %s, back to %s", p.conn.RemoteAddr(), p.validatedRemoteAddr))
+	p.conn.SetCurrentRemoteAddr(p.validatedRemoteAddr)
+	p.validatedRemoteAddr = nil
+	p.validated.Set(true)
+	return true
 }
 
 func (p *path) onRTO(lastSentTime time.Time) bool {
+	p.reinject = true
 	// Was there any activity since last sent packet?
 	if p.lastNetworkActivityTime.Before(lastSentTime) {
-		p.potentiallyFailed.Set(true)
+		p.setPotentiallyFailed("no activity since the retransmission timeout")
 		p.sess.schedulePathsFrame()
 		return true
 	}
 	return false
 }
 
+// isBackup returns true if this host or the peer gave the path the backup priority
+func (p *path) isBackup() bool {
+	return p.localBackup.Get() || p.remoteBackup.Get()
+}
+
+// amplificationLimited returns true if sending a packet of the given size on a path
+// that the peer opened would exceed the anti-amplification budget
+func (p *path) amplificationLimited(size protocol.ByteCount) bool {
+	if !p.antiAmplification || p.validated.Get() {
+		return false
+	}
+	return p.bytesSent+size > protocol.AmplificationFactor*p.bytesReceived
+}
+
+// setPotentiallyFailed marks the path as potentially failed, reporting it once
+func (p *path) setPotentiallyFailed(reason string) {
+	if !p.potentiallyFailed.Get() {
+		p.potentiallyFailed.Set(true)
+		p.reinject = true
+		p.sess.notifyPathEvent(p, PathPotentiallyFailed, reason)
+	}
+}
+
 func (p *path) SetLeastUnacked(leastUnacked protocol.PacketNumber) {
 	p.leastUnacked = leastUnacked
 }
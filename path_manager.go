@@ -24,9 +24,7 @@ type pathManager struct {
 
 	advertisedLocAddrs map[string]bool
 
-	// TODO (QDC): find a cleaner way
-	oliaSenders        map[protocol.PathID]*congestion.OliaSender
-	vegasSenders       map[protocol.PathID]*congestion.VegasSender
+	coupling           *congestionCoupling
 	handshakeCompleted chan struct{}
 	runClosed          chan struct{}
 	timer              *time.Timer
@@ -50,8 +48,7 @@ func (pm *pathManager) setup(conn connection) {
 	pm.timer = time.NewTimer(0)
 	pm.nbPaths = 0
 
-	pm.oliaSenders = make(map[protocol.PathID]*congestion.OliaSender)
-	pm.vegasSenders = make(map[protocol.PathID]*congestion.VegasSender)
+	pm.coupling = newCongestionCoupling()
 	// Setup the first path of the connection
 	pm.sess.paths[protocol.InitialPathID] = &path{
 		pathID: protocol.InitialPathID,
@@ -59,16 +56,8 @@ func (pm *pathManager) setup(conn connection) {
 		conn:   conn,
 	}
 
-	// Setup this first path bases on Congestion Algorithms
-	if CongestionControl == "olia" {
-		pm.sess.paths[protocol.InitialPathID].setup(pm.oliaSenders)
-	} else if CongestionControl == "cubic" {
-		pm.sess.paths[protocol.InitialPathID].setup(pm.oliaSenders)
-	} else if CongestionControl == "vegas" {
-		pm.sess.paths[protocol.InitialPathID].setup2(pm.vegasSenders)
-	} else {
-		fmt.Println("-- Wrong Congestion Algorithms --")
-	}
+	pm.sess.paths[protocol.InitialPathID].setup(pm.coupling)
+	pm.sess.notifyPathEvent(pm.sess.paths[protocol.InitialPathID], PathOpened, "initial path")
 	// With the initial path, get the remoteAddr to create paths accordingly
 	if conn.RemoteAddr() != nil {
 		remAddr, err := net.ResolveUDPAddr("udp", conn.RemoteAddr().String())
@@ -108,6 +97,7 @@ runLoop:
 		case <-pm.runClosed:
 			break runLoop
 		case <-pm.pconnMgr.changePaths:
+			pm.withdrawAddresses()
 			if pm.sess.createPaths {
 				pm.createPaths()
 			}
@@ -137,7 +127,47 @@ func (pm *pathManager) advertiseAddresses() {
 	}
 }
 
-func (pm *pathManager) createPath(locAddr net.UDPAddr, remAddr net.UDPAddr) error {
+// withdrawAddresses closes the paths on local addresses that disappeared, and withdraws them from the peer
+func (pm *pathManager) withdrawAddresses() {
+	pm.pconnMgr.mutex.Lock()
+	for locAddrStr := range pm.advertisedLocAddrs {
+		if _, ok := pm.pconnMgr.pconns[locAddrStr]; ok {
+			continue
+		}
+		locAddr, err := net.ResolveUDPAddr("udp", locAddrStr)
+		if err != nil {
+			continue
+		}
+		pm.sess.streamFramer.AddRemoveAddressForTransmission(uint8(getIPVersion(locAddr.IP)), *locAddr)
+		delete(pm.advertisedLocAddrs, locAddrStr)
+	}
+
+	var stalePaths []protocol.PathID
+	anyAddr := pm.pconnMgr.pconnAny.LocalAddr().String()
+	pm.sess.pathsLock.RLock()
+	for pathID, pth := range pm.sess.paths {
+		if pathID == protocol.InitialPathID || !pth.open.Get() {
+			continue
+		}
+		locAddr := pth.conn.LocalAddr().String()
+		if _, ok := pm.pconnMgr.pconns[locAddr]; !ok && locAddr != anyAddr {
+			stalePaths = append(stalePaths, pathID)
+		}
+	}
+	pm.sess.pathsLock.RUnlock()
+	pm.pconnMgr.mutex.Unlock()
+
+	for _, pathID := range stalePaths {
+		if utils.Debug() {
+			utils.Debugf("Closing path %x, its local address disappeared", pathID)
+		}
+		// The path manager doesn't run on the run loop of the session
+		pm.sess.requestPathChange(pm.sess.closePathChan, pathRequest{pathID: pathID})
+	}
+}
+
+// createPath returns the existing path if there is already one between both addresses
+func (pm *pathManager) createPath(locAddr net.UDPAddr, remAddr net.UDPAddr) (*path, error) {
 	// First check that the path does not exist yet
 	pm.sess.pathsLock.Lock()
 	defer pm.sess.pathsLock.Unlock()
@@ -147,7 +177,7 @@ func (pm *pathManager) createPath(locAddr net.UDPAddr, remAddr net.UDPAddr) erro
 		remAddrPath := pth.conn.RemoteAddr().String()
 		if locAddr.String() == locAddrPath && remAddr.String() == remAddrPath {
 			// Path already exists, so don't create it again
-			return nil
+			return pth, nil
 		}
 	}
 	// No matching path, so create it
@@ -156,16 +186,17 @@ func (pm *pathManager) createPath(locAddr net.UDPAddr, remAddr net.UDPAddr) erro
 		sess:   pm.sess,
 		conn:   &conn{pconn: pm.pconnMgr.pconns[locAddr.String()], currentAddr: &remAddr},
 	}
-	pth.setup(pm.oliaSenders)
+	pth.setup(pm.coupling)
 	pm.sess.paths[pm.nxtPathID] = pth
+	pm.sess.notifyPathEvent(pth, PathOpened, "opened locally")
 	if utils.Debug() {
 		utils.Debugf("Created path %x on %s to %s", pm.nxtPathID, locAddr.String(), remAddr.String())
 	}
 	pm.nxtPathID += 2
-	// Send a PING frame to get latency info about the new path and informing the
-	// peer of its existence
+	// Send a PATH_CHALLENGE frame to validate the new path, informing the peer of
+	// its existence and getting latency info about it
 	// Because we hold pathsLock, it is safe to send packet now
-	return pm.sess.sendPing(pth)
+	return pth, pm.sess.sendPathChallenge(pth)
 }
 
 func (pm *pathManager) createPaths() error {
@@ -185,14 +216,14 @@ func (pm *pathManager) createPaths() error {
 		version := getIPVersion(locAddr.IP)
 		if version == 4 {
 			for _, remAddr := range pm.remoteAddrs4 {
-				err := pm.createPath(locAddr, remAddr)
+				_, err := pm.createPath(locAddr, remAddr)
 				if err != nil {
 					return err
 				}
 			}
 		} else {
 			for _, remAddr := range pm.remoteAddrs6 {
-				err := pm.createPath(locAddr, remAddr)
+				_, err := pm.createPath(locAddr, remAddr)
 				if err != nil {
 					return err
 				}
@@ -203,6 +234,36 @@ func (pm *pathManager) createPaths() error {
 	return nil
 }
 
+// openPath opens a path on request of the application, it must only be called from the run loop
+func (pm *pathManager) openPath(local, remote net.Addr) (protocol.PathID, error) {
+	locAddr, err := net.ResolveUDPAddr("udp", local.String())
+	if err != nil {
+		return 0, err
+	}
+	remAddr, err := net.ResolveUDPAddr("udp", remote.String())
+	if err != nil {
+		return 0, err
+	}
+	if getIPVersion(locAddr.IP) != getIPVersion(remAddr.IP) {
+		return 0, errors.New("local and remote address must have the same IP version")
+	}
+
+	pm.pconnMgr.mutex.Lock()
+	defer pm.pconnMgr.mutex.Unlock()
+	if _, ok := pm.pconnMgr.pconns[locAddr.String()]; !ok {
+		return 0, fmt.Errorf("no connection bound to local address %s", locAddr)
+	}
+	pth, err := pm.createPath(*locAddr, *remAddr)
+	if err != nil {
+		return 0, err
+	}
+	if !pth.open.Get() {
+		return 0, fmt.Errorf("path %x between %s and %s was closed", pth.pathID, locAddr, remAddr)
+	}
+	pm.sess.schedulePathsFrame()
+	return pth.pathID, nil
+}
+
 func (pm *pathManager) createPathFromRemote(p *receivedPacket) (*path, error) {
 	pm.sess.pathsLock.Lock()
 	defer pm.sess.pathsLock.Unlock()
@@ -228,10 +289,13 @@ func (pm *pathManager) createPathFromRemote(p *receivedPacket) (*path, error) {
 		pathID: pathID,
 		sess:   pm.sess,
 		conn:   &conn{pconn: localPconn, currentAddr: remoteAddr},
+		// Anyone can send a packet with a new path ID, so limit what is sent until the path is validated
+		antiAmplification: true,
 	}
 
-	pth.setup(pm.oliaSenders)
+	pth.setup(pm.coupling)
 	pm.sess.paths[pathID] = pth
+	pm.sess.notifyPathEvent(pth, PathOpened, "opened by the peer")
 
 	if utils.Debug() {
 		utils.Debugf("Created remote path %x on %s to %s", pathID, localPconn.LocalAddr().String(), remoteAddr.String())
@@ -255,6 +319,48 @@ func (pm *pathManager) handleAddAddressFrame(f *wire.AddAddressFrame) error {
 	return nil
 }
 
+// handleRemoveAddressFrame closes every path to the removed address, and doesn't create new ones to it
+func (pm *pathManager) handleRemoveAddressFrame(f *wire.RemoveAddressFrame) error {
+	switch f.IPVersion {
+	case 4:
+		pm.remoteAddrs4 = removeUDPAddr(pm.remoteAddrs4, f.Addr)
+	case 6:
+		pm.remoteAddrs6 = removeUDPAddr(pm.remoteAddrs6, f.Addr)
+	default:
+		return wire.ErrUnknownIPVersion
+	}
+
+	var stalePaths []protocol.PathID
+	pm.sess.pathsLock.RLock()
+	for pathID, pth := range pm.sess.paths {
+		// XXX (QDC): the initial path cannot be closed
+		if pathID == protocol.InitialPathID || !pth.open.Get() {
+			continue
+		}
+		if pth.conn.RemoteAddr().String() == f.Addr.String() {
+			stalePaths = append(stalePaths, pathID)
+		}
+	}
+	pm.sess.pathsLock.RUnlock()
+
+	for _, pathID := range stalePaths {
+		if err := pm.sess.closePath(pathID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeUDPAddr(addrs []net.UDPAddr, addr net.UDPAddr) []net.UDPAddr {
+	remaining := addrs[:0]
+	for _, a := range addrs {
+		if !a.IP.Equal(addr.IP) || a.Port != addr.Port {
+			remaining = append(remaining, a)
+		}
+	}
+	return remaining
+}
+
 func (pm *pathManager) closePath(pthID protocol.PathID) error {
 	pm.sess.pathsLock.RLock()
 	defer pm.sess.pathsLock.RUnlock()
@@ -269,6 +375,11 @@ func (pm *pathManager) closePath(pthID protocol.PathID) error {
 		pth.closeChan <- nil
 	}
 
+	// The closed path must not influence the congestion windows of the other paths anymore
+	if coupled, ok := pth.congestion.(congestion.CoupledSendAlgorithm); ok {
+		coupled.Decouple()
+	}
+
 	return nil
 }
 
@@ -0,0 +1,583 @@
+package quic
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/mocks"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path Manager", func() {
+	var (
+		pm   *pathManager
+		sess *session
+	)
+
+	BeforeEach(func() {
+		sess = &session{
+			version:   protocol.VersionMP,
+			paths:     make(map[protocol.PathID]*path),
+			config:    &Config{CongestionControl: "olia"},
+			scheduler: &scheduler{},
+		}
+		sess.scheduler.setup()
+		pm = &pathManager{
+			sess:     sess,
+			coupling: newCongestionCoupling(),
+		}
+	})
+
+	newPath := func(pathID protocol.PathID) *path {
+		pth := &path{pathID: pathID, sess: sess}
+		pth.setup(pm.coupling)
+		return pth
+	}
+
+	// handlePathRequests handles the path requests of the application like the run loop of the session
+	handlePathRequests := func() {
+		sess.ctx, sess.ctxCancel = context.WithCancel(context.Background())
+		sess.openPathChan = make(chan openPathRequest)
+		sess.closePathChan = make(chan pathRequest)
+		sess.pathPriorityChan = make(chan pathRequest)
+		sess.pathStatsChan = make(chan chan []PathStats)
+		go func(s *session, openPathChan chan openPathRequest, closePathChan, pathPriorityChan chan pathRequest, pathStatsChan chan chan []PathStats, done <-chan struct{}) {
+			defer GinkgoRecover()
+			for {
+				select {
+				case req := <-openPathChan:
+					pathID, err := s.pathManager.openPath(req.local, req.remote)
+					req.result <- openPathResult{pathID: pathID, err: err}
+				case req := <-closePathChan:
+					req.result <- s.closePath(req.pathID, true)
+				case req := <-pathPriorityChan:
+					req.result <- s.setPathPriority(req.pathID, req.priority)
+				case c := <-pathStatsChan:
+					c <- s.pathStats()
+				case <-done:
+					return
+				}
+			}
+		}(sess, sess.openPathChan, sess.closePathChan, sess.pathPriorityChan, sess.pathStatsChan, sess.ctx.Done())
+	}
+
+	AfterEach(func() {
+		if sess.ctxCancel != nil {
+			sess.ctxCancel()
+		}
+		for _, pth := range sess.paths {
+			pth.closeChan <- nil
+		}
+	})
+
+	Context("setting up paths", func() {
+		It("uses the congestion control of the session", func() {
+			sess.paths[1] = newPath(1)
+			Expect(sess.paths[1].congestion).To(BeAssignableToTypeOf(&congestion.OliaSender{}))
+			Expect(pm.coupling.oliaSenders).To(HaveKey(protocol.PathID(1)))
+		})
+
+		It("doesn't use OLIA on the initial path", func() {
+			sess.paths[protocol.InitialPathID] = newPath(protocol.InitialPathID)
+			Expect(sess.paths[protocol.InitialPathID].congestion).ToNot(BeAssignableToTypeOf(&congestion.OliaSender{}))
+			Expect(pm.coupling.oliaSenders).To(BeEmpty())
+		})
+
+		It("uses the congestion control configured for a path", func() {
+			sess.config.PathCongestionControl = map[PathID]string{3: "vegas"}
+			sess.paths[1] = newPath(1)
+			sess.paths[3] = newPath(3)
+			Expect(sess.paths[3].congestion).To(BeAssignableToTypeOf(&congestion.VegasSender{}))
+			Expect(pm.coupling.oliaSenders).To(HaveLen(1))
+			Expect(pm.coupling.oliaSenders).To(HaveKey(protocol.PathID(1)))
+		})
+
+		It("couples LIA and BALIA senders like OLIA senders", func() {
+			sess.config.PathCongestionControl = map[PathID]string{1: "lia", 3: "balia"}
+			sess.paths[protocol.InitialPathID] = newPath(protocol.InitialPathID)
+			sess.paths[1] = newPath(1)
+			sess.paths[3] = newPath(3)
+			Expect(sess.paths[1].congestion).To(BeAssignableToTypeOf(&congestion.LiaSender{}))
+			Expect(sess.paths[3].congestion).To(BeAssignableToTypeOf(&congestion.BaliaSender{}))
+			Expect(pm.coupling.liaSenders).To(HaveLen(1))
+			Expect(pm.coupling.baliaSenders).To(HaveLen(1))
+			Expect(pm.coupling.oliaSenders).To(BeEmpty())
+			Expect(pm.closePath(3)).To(Succeed())
+			Expect(pm.coupling.baliaSenders).To(BeEmpty())
+			delete(sess.paths, 3)
+		})
+
+		It("couples wVegas senders", func() {
+			sess.config.CongestionControl = "wvegas"
+			sess.paths[protocol.InitialPathID] = newPath(protocol.InitialPathID)
+			sess.paths[1] = newPath(1)
+			sess.paths[3] = newPath(3)
+			Expect(sess.paths[protocol.InitialPathID].congestion).ToNot(BeAssignableToTypeOf(&congestion.WVegasSender{}))
+			Expect(sess.paths[1].congestion).To(BeAssignableToTypeOf(&congestion.WVegasSender{}))
+			Expect(pm.coupling.wvegasSenders).To(HaveLen(2))
+		})
+
+		It("uses BBR on every path, including the initial path", func() {
+			sess.config.CongestionControl = "bbr"
+			sess.paths[protocol.InitialPathID] = newPath(protocol.InitialPathID)
+			sess.paths[1] = newPath(1)
+			Expect(sess.paths[protocol.InitialPathID].congestion).To(BeAssignableToTypeOf(&congestion.BBRSender{}))
+			Expect(sess.paths[1].congestion).To(BeAssignableToTypeOf(&congestion.BBRSender{}))
+			Expect(pm.coupling.oliaSenders).To(BeEmpty())
+		})
+
+		It("uses Cubic if no congestion control is set", func() {
+			sess.config.CongestionControl = ""
+			sess.paths[1] = newPath(1)
+			Expect(sess.paths[1].congestion).To(BeNil())
+			Expect(sess.paths[1].sentPacketHandler).ToNot(BeNil())
+		})
+	})
+
+	Context("path statistics", func() {
+		BeforeEach(func() {
+			handlePathRequests()
+		})
+
+		It("returns a snapshot of every path, ordered by path ID", func() {
+			localAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+			remoteAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}
+			for _, pathID := range []protocol.PathID{3, 1} {
+				pth := newPath(pathID)
+				pth.conn = &conn{pconn: &mockPacketConn{addr: localAddr}, currentAddr: remoteAddr}
+				sess.paths[pathID] = pth
+			}
+			sess.paths[3].rttStats.UpdateRTT(50*time.Millisecond, 0, time.Now())
+			sess.paths[3].potentiallyFailed.Set(true)
+			stats := sess.Paths()
+			Expect(stats).To(HaveLen(2))
+			Expect(stats[0].PathID).To(Equal(PathID(1)))
+			Expect(stats[1].PathID).To(Equal(PathID(3)))
+			Expect(stats[1].LocalAddr).To(Equal(localAddr))
+			Expect(stats[1].RemoteAddr).To(Equal(remoteAddr))
+			Expect(stats[1].Open).To(BeTrue())
+			Expect(stats[1].PotentiallyFailed).To(BeTrue())
+			Expect(stats[1].SmoothedRTT).To(Equal(50 * time.Millisecond))
+			Expect(stats[1].MinRTT).To(Equal(50 * time.Millisecond))
+			Expect(stats[1].CongestionWindow).To(Equal(sess.paths[3].sentPacketHandler.GetCongestionWindow()))
+			Expect(stats[1].SentPackets).To(BeZero())
+		})
+
+		It("returns nil once the session is closed", func() {
+			sess.paths[1] = newPath(1)
+			sess.ctxCancel()
+			sess.pathStatsChan = make(chan chan []PathStats)
+			Expect(sess.Paths()).To(BeNil())
+		})
+	})
+
+	Context("opening and closing paths on request", func() {
+		BeforeEach(func() {
+			sess.closedPaths = make(map[protocol.PathID]bool)
+			sess.streamFramer = &streamFramer{}
+			sess.sendingScheduled = make(chan struct{}, 1)
+			sess.pathManager = pm
+			pm.pconnMgr = &pconnManager{pconns: make(map[string]net.PacketConn)}
+			handlePathRequests()
+		})
+
+		It("refuses to open a path without multipath support", func() {
+			sess.version = protocol.VersionMP - 1
+			_, err := sess.OpenPath(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)}, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2)})
+			Expect(err).To(MatchError(errMultipathNotSupported))
+		})
+
+		It("refuses to open a path from an unknown local address", func() {
+			_, err := sess.OpenPath(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443})
+			Expect(err).To(MatchError("no connection bound to local address 10.0.0.1:4242"))
+		})
+
+		It("refuses to open a path between different IP versions", func() {
+			_, err := sess.OpenPath(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)}, &net.UDPAddr{IP: net.IPv6loopback})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("refuses to open a path once the session is closed", func() {
+			sess.ctxCancel()
+			sess.openPathChan = make(chan openPathRequest)
+			_, err := sess.OpenPath(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1)}, &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2)})
+			Expect(err).To(MatchError(errSessionClosed))
+		})
+
+		It("closes a path and sends a CLOSE_PATH frame", func() {
+			sess.paths[1] = newPath(1)
+			Expect(sess.paths[1].receivedPacketHandler.ReceivedPacket(1, true, 100)).To(Succeed())
+			Expect(sess.ClosePath(1)).To(Succeed())
+			Expect(sess.closedPaths).To(HaveKey(protocol.PathID(1)))
+			frame := sess.streamFramer.PopClosePathFrame()
+			Expect(frame).ToNot(BeNil())
+			Expect(frame.PathID).To(Equal(protocol.PathID(1)))
+			Expect(frame.LargestAcked).To(Equal(protocol.PacketNumber(1)))
+			delete(sess.paths, 1)
+		})
+
+		It("doesn't send a CLOSE_PATH frame if no packet was received on the path", func() {
+			sess.paths[1] = newPath(1)
+			Expect(sess.ClosePath(1)).To(Succeed())
+			Expect(sess.closedPaths).To(HaveKey(protocol.PathID(1)))
+			Expect(sess.streamFramer.PopClosePathFrame()).To(BeNil())
+			delete(sess.paths, 1)
+		})
+
+		It("doesn't close the initial path", func() {
+			Expect(sess.ClosePath(protocol.InitialPathID)).To(MatchError(errCloseInitialPath))
+		})
+
+		It("errors when closing an unknown path", func() {
+			Expect(sess.ClosePath(7)).To(HaveOccurred())
+		})
+
+		It("errors when closing a path of a closed session", func() {
+			sess.paths[1] = newPath(1)
+			sess.ctxCancel()
+			// The run loop is not running any more
+			sess.closePathChan = make(chan pathRequest)
+			Expect(sess.ClosePath(1)).To(MatchError(errSessionClosed))
+			Expect(sess.closedPaths).ToNot(HaveKey(protocol.PathID(1)))
+		})
+	})
+
+	Context("removing addresses", func() {
+		var (
+			addrA = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+			addrB = &net.UDPAddr{IP: net.IPv4(10, 0, 1, 1), Port: 4242}
+			peer  = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}
+			peerB = &net.UDPAddr{IP: net.IPv4(10, 0, 1, 2), Port: 443}
+		)
+
+		BeforeEach(func() {
+			sess.closedPaths = make(map[protocol.PathID]bool)
+			sess.streamFramer = &streamFramer{}
+			sess.pathManager = pm
+			handlePathRequests()
+		})
+
+		addPath := func(pathID protocol.PathID, local, remote *net.UDPAddr) {
+			pth := newPath(pathID)
+			pth.conn = &conn{pconn: &mockPacketConn{addr: local}, currentAddr: remote}
+			sess.paths[pathID] = pth
+		}
+
+		It("closes the paths to an address removed by the peer", func() {
+			pm.remoteAddrs4 = []net.UDPAddr{*peer, *peerB}
+			addPath(1, addrA, peer)
+			addPath(3, addrB, peerB)
+			err := pm.handleRemoveAddressFrame(&wire.RemoveAddressFrame{IPVersion: 4, Addr: *peerB})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pm.remoteAddrs4).To(Equal([]net.UDPAddr{*peer}))
+			Expect(sess.closedPaths).To(HaveKey(protocol.PathID(3)))
+			Expect(sess.closedPaths).ToNot(HaveKey(protocol.PathID(1)))
+			delete(sess.paths, 3)
+		})
+
+		It("errors on unknown IP versions", func() {
+			err := pm.handleRemoveAddressFrame(&wire.RemoveAddressFrame{IPVersion: 5})
+			Expect(err).To(MatchError(wire.ErrUnknownIPVersion))
+		})
+
+		It("withdraws local addresses that disappeared", func() {
+			pm.pconnMgr = &pconnManager{
+				pconns:   map[string]net.PacketConn{addrB.String(): &mockPacketConn{addr: addrB}},
+				pconnAny: &mockPacketConn{addr: &net.UDPAddr{IP: net.IPv4zero, Port: 4242}},
+			}
+			pm.advertisedLocAddrs = map[string]bool{addrA.String(): true, addrB.String(): true}
+			addPath(1, addrA, peer)
+			addPath(3, addrB, peerB)
+			pm.withdrawAddresses()
+			Expect(pm.advertisedLocAddrs).To(HaveLen(1))
+			frame := sess.streamFramer.PopRemoveAddressFrame()
+			Expect(frame).ToNot(BeNil())
+			Expect(frame.IPVersion).To(Equal(uint8(4)))
+			Expect(frame.Addr.String()).To(Equal(addrA.String()))
+			Expect(sess.streamFramer.PopRemoveAddressFrame()).To(BeNil())
+			Expect(sess.closedPaths).To(HaveKey(protocol.PathID(1)))
+			Expect(sess.closedPaths).ToNot(HaveKey(protocol.PathID(3)))
+			delete(sess.paths, 1)
+		})
+	})
+
+	Context("path priorities", func() {
+		BeforeEach(func() {
+			sess.streamFramer = &streamFramer{}
+			sess.paths[1] = newPath(1)
+			handlePathRequests()
+		})
+
+		It("announces the priority of a path to the peer", func() {
+			Expect(sess.SetPathPriority(1, PathPriorityBackup)).To(Succeed())
+			Expect(sess.paths[1].isBackup()).To(BeTrue())
+			Expect(sess.SetPathPriority(1, PathPriorityActive)).To(Succeed())
+			Expect(sess.paths[1].isBackup()).To(BeFalse())
+			Expect(sess.streamFramer.PopPathStatusFrame()).To(Equal(&wire.PathStatusFrame{PathID: 1, StatusSequence: 1, Backup: true}))
+			Expect(sess.streamFramer.PopPathStatusFrame()).To(Equal(&wire.PathStatusFrame{PathID: 1, StatusSequence: 2, Backup: false}))
+		})
+
+		It("errors for unknown paths and priorities", func() {
+			Expect(sess.SetPathPriority(7, PathPriorityBackup)).To(MatchError(errUnknownPath))
+			Expect(sess.SetPathPriority(1, PathPriority(42))).To(MatchError(errInvalidPathPriority))
+		})
+
+		It("applies the priority announced by the peer, ignoring old announcements", func() {
+			sess.handlePathStatusFrame(&wire.PathStatusFrame{PathID: 1, StatusSequence: 2, Backup: true})
+			Expect(sess.paths[1].isBackup()).To(BeTrue())
+			sess.handlePathStatusFrame(&wire.PathStatusFrame{PathID: 1, StatusSequence: 1, Backup: false})
+			Expect(sess.paths[1].isBackup()).To(BeTrue())
+			sess.handlePathStatusFrame(&wire.PathStatusFrame{PathID: 1, StatusSequence: 3, Backup: false})
+			Expect(sess.paths[1].isBackup()).To(BeFalse())
+			// Status for unknown paths is ignored
+			sess.handlePathStatusFrame(&wire.PathStatusFrame{PathID: 7, StatusSequence: 1, Backup: true})
+		})
+	})
+
+	Context("path events", func() {
+		var (
+			localAddr  = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+			remoteAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}
+		)
+
+		BeforeEach(func() {
+			sess.pathEvents = make(chan PathEvent, 10)
+			sess.closedPaths = make(map[protocol.PathID]bool)
+			sess.streamFramer = &streamFramer{}
+			sess.sendingScheduled = make(chan struct{}, 1)
+			sess.paths[1] = newPath(1)
+			sess.paths[1].conn = &conn{pconn: &mockPacketConn{addr: localAddr}, currentAddr: remoteAddr}
+			handlePathRequests()
+		})
+
+		It("reports a potentially failed path once", func() {
+			sess.paths[1].setPotentiallyFailed("no activity")
+			sess.paths[1].setPotentiallyFailed("no activity")
+			Expect(sess.PathEvents()).To(Receive(Equal(PathEvent{
+				Type:       PathPotentiallyFailed,
+				PathID:     1,
+				LocalAddr:  localAddr,
+				RemoteAddr: remoteAddr,
+				Reason:     "no activity",
+			})))
+			Expect(sess.PathEvents()).ToNot(Receive())
+		})
+
+		It("reports closed paths", func() {
+			Expect(sess.ClosePath(1)).To(Succeed())
+			var event PathEvent
+			Expect(sess.PathEvents()).To(Receive(&event))
+			Expect(event.Type).To(Equal(PathClosed))
+			Expect(event.PathID).To(Equal(PathID(1)))
+			Expect(event.Reason).To(Equal("closed locally"))
+			delete(sess.paths, 1)
+		})
+
+		It("drops events if the application doesn't read them", func() {
+			for i := 0; i < 20; i++ {
+				sess.notifyPathEvent(sess.paths[1], PathValidated, "received a packet")
+			}
+			Expect(sess.PathEvents()).To(HaveLen(10))
+		})
+
+		It("traces events, also if the application doesn't read them", func() {
+			tracer := &mockTracer{}
+			sess.tracer = tracer
+			for i := 0; i < 20; i++ {
+				sess.notifyPathEvent(sess.paths[1], PathValidated, "received a packet")
+			}
+			Expect(tracer.pathEvents).To(HaveLen(20))
+			Expect(tracer.pathEvents[0].LocalAddr).To(Equal(localAddr))
+		})
+
+		It("has a string representation for event types", func() {
+			Expect(PathPotentiallyFailed.String()).To(Equal("potentially failed"))
+			Expect(PathEventType(42).String()).To(Equal("unknown path event"))
+		})
+	})
+
+	Context("path validation", func() {
+		var (
+			remoteAddr   = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}
+			rebindAddr   = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 1337}
+			eventsOfType = func(eventType PathEventType) []PathEvent {
+				var events []PathEvent
+				for len(sess.pathEvents) > 0 {
+					if event := <-sess.pathEvents; event.Type == eventType {
+						events = append(events, event)
+					}
+				}
+				return events
+			}
+		)
+
+		BeforeEach(func() {
+			sess.pathEvents = make(chan PathEvent, 10)
+			sess.closedPaths = make(map[protocol.PathID]bool)
+			sess.streamFramer = &streamFramer{}
+			sess.sendingScheduled = make(chan struct{}, 1)
+			sess.paths[1] = newPath(1)
+			sess.paths[1].conn = &conn{pconn: &mockPacketConn{}, currentAddr: remoteAddr}
+		})
+
+		It("validates a path when the peer answers the last PATH_CHALLENGE", func() {
+			pth := sess.paths[1]
+			pth.challenge = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+			pth.challengesSent = 1
+			sess.handlePathResponseFrame(&wire.PathResponseFrame{Data: [8]byte{8, 7, 6, 5, 4, 3, 2, 1}}, pth)
+			Expect(pth.validated.Get()).To(BeFalse())
+			sess.handlePathResponseFrame(&wire.PathResponseFrame{Data: pth.challenge}, pth)
+			Expect(pth.validated.Get()).To(BeTrue())
+			Expect(pth.stats().Validated).To(BeTrue())
+			var event PathEvent
+			Expect(sess.PathEvents()).To(Receive(&event))
+			Expect(event.Type).To(Equal(PathValidated))
+			Expect(sess.sendingScheduled).To(Receive())
+		})
+
+		It("ignores PATH_RESPONSEs if no PATH_CHALLENGE was sent", func() {
+			sess.handlePathResponseFrame(&wire.PathResponseFrame{}, sess.paths[1])
+			Expect(sess.paths[1].validated.Get()).To(BeFalse())
+		})
+
+		It("closes the path when too many PATH_CHALLENGEs were not answered", func() {
+			sess.paths[1].challengesSent = protocol.MaxPathChallenges
+			Expect(sess.sendPathChallenge(sess.paths[1])).To(Succeed())
+			Expect(sess.closedPaths).To(HaveKey(protocol.PathID(1)))
+		})
+
+		It("limits what is sent on a path opened by the peer until it is validated", func() {
+			pth := sess.paths[1]
+			Expect(pth.amplificationLimited(1000)).To(BeFalse())
+			pth.antiAmplification = true
+			Expect(pth.amplificationLimited(1)).To(BeTrue())
+			pth.bytesReceived = 100
+			Expect(pth.amplificationLimited(protocol.AmplificationFactor * 100)).To(BeFalse())
+			pth.bytesSent = 50
+			Expect(pth.amplificationLimited(protocol.AmplificationFactor * 100)).To(BeTrue())
+			pth.validated.Set(true)
+			Expect(pth.amplificationLimited(protocol.AmplificationFactor * 100)).To(BeFalse())
+		})
+
+		Context("within the anti-amplification budget", func() {
+			var (
+				pth        *path
+				packetConn *mockPacketConn
+			)
+
+			BeforeEach(func() {
+				mockCpm := mocks.NewMockConnectionParametersManager(mockCtrl)
+				mockCpm.EXPECT().TruncateConnectionID().Return(false).AnyTimes()
+				streamsMap := newStreamsMap(nil, protocol.PerspectiveServer, nil)
+				streamsMap.streams[1] = &stream{}
+				streamsMap.openStreams = []protocol.StreamID{1}
+				sess.streamFramer = newStreamFramer(streamsMap, nil)
+				sess.packer = &packetPacker{
+					cryptoSetup:          &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure},
+					connectionParameters: mockCpm,
+					connectionID:         0x1337,
+					streamFramer:         sess.streamFramer,
+					perspective:          protocol.PerspectiveServer,
+					stopWaiting:          make(map[protocol.PathID]*wire.StopWaitingFrame),
+					ackFrame:             make(map[protocol.PathID]*wire.AckFrame),
+					version:              protocol.VersionMP,
+				}
+				packetConn = &mockPacketConn{}
+				pth = sess.paths[1]
+				pth.conn = &conn{pconn: packetConn, currentAddr: remoteAddr}
+				pth.antiAmplification = true
+			})
+
+			It("withholds a PATH_CHALLENGE until the peer sent enough on the path", func() {
+				pth.bytesReceived = 10
+				nextPacketNumber := pth.packetNumberGenerator.Peek()
+				Expect(sess.sendPathChallenge(pth)).To(Succeed())
+				Expect(pth.challengeWithheld).To(BeTrue())
+				Expect(pth.challengesSent).To(BeZero())
+				Expect(packetConn.dataWritten.Len()).To(BeZero())
+				// The withheld packet is neither numbered nor in flight, so it can't be declared lost
+				Expect(pth.packetNumberGenerator.Peek()).To(Equal(nextPacketNumber))
+				Expect(pth.sentPacketHandler.GetBytesInFlight()).To(BeZero())
+
+				pth.bytesReceived = 100
+				Expect(sess.sendPathChallenge(pth)).To(Succeed())
+				Expect(pth.challengeWithheld).To(BeFalse())
+				Expect(pth.challengesSent).To(Equal(1))
+				Expect(packetConn.dataWritten.Len()).ToNot(BeZero())
+				Expect(pth.bytesSent).To(Equal(protocol.ByteCount(packetConn.dataWritten.Len())))
+			})
+
+			It("computes the length of the packet before packing it", func() {
+				frame := &wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+				length, err := sess.packer.PacketLength([]wire.Frame{frame}, pth)
+				Expect(err).ToNot(HaveOccurred())
+				packet, err := sess.packer.PackPathFrame(frame, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(length).To(Equal(protocol.ByteCount(len(packet.raw))))
+			})
+		})
+
+		Context("when the peer migrates", func() {
+			var pth *path
+
+			BeforeEach(func() {
+				pth = sess.paths[1]
+				pth.validated.Set(true)
+				pth.rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+			})
+
+			It("validates the new address of the peer", func() {
+				Expect(pth.migrate(rebindAddr)).To(BeTrue())
+				Expect(pth.conn.RemoteAddr()).To(Equal(rebindAddr))
+				Expect(pth.validated.Get()).To(BeFalse())
+				Expect(pth.amplificationLimited(1)).To(BeTrue())
+				Expect(pth.SendingAllowed()).To(BeFalse())
+				Expect(pth.rttStats.SmoothedRTT()).To(BeZero())
+				events := eventsOfType(PathMigrated)
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].RemoteAddr).To(Equal(rebindAddr))
+				Expect(events[0].Reason).To(Equal("peer moved from 10.0.0.2:443 to 10.0.0.3:1337"))
+
+				pth.challenge = [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+				pth.challengesSent = 1
+				sess.handlePathResponseFrame(&wire.PathResponseFrame{Data: pth.challenge}, pth)
+				Expect(pth.validated.Get()).To(BeTrue())
+				Expect(pth.validatedRemoteAddr).To(BeNil())
+			})
+
+			It("doesn't validate the last validated address again", func() {
+				Expect(pth.migrate(rebindAddr)).To(BeTrue())
+				Expect(pth.migrate(remoteAddr)).To(BeFalse())
+				Expect(pth.conn.RemoteAddr()).To(Equal(remoteAddr))
+				Expect(pth.validated.Get()).To(BeTrue())
+			})
+
+			It("goes back to the last validated address when the PATH_CHALLENGEs are not answered", func() {
+				Expect(pth.migrate(rebindAddr)).To(BeTrue())
+				pth.challengesSent = protocol.MaxPathChallenges
+				Expect(sess.sendPathChallenge(pth)).To(Succeed())
+				Expect(pth.conn.RemoteAddr()).To(Equal(remoteAddr))
+				Expect(pth.validated.Get()).To(BeTrue())
+				Expect(sess.closedPaths).ToNot(HaveKey(protocol.PathID(1)))
+				Expect(eventsOfType(PathMigrated)).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("closing paths", func() {
+		It("decouples the sender of a closed path", func() {
+			sess.paths[1] = newPath(1)
+			sess.paths[3] = newPath(3)
+			Expect(pm.closePath(3)).To(Succeed())
+			Expect(pm.coupling.oliaSenders).To(HaveLen(1))
+			Expect(pm.coupling.oliaSenders).To(HaveKey(protocol.PathID(1)))
+			delete(sess.paths, 3)
+		})
+	})
+})
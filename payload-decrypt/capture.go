@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A datagram is the payload of a captured UDP packet
+type datagram struct {
+	time     time.Time
+	src, dst *net.UDPAddr
+	// sentBy is only set for packet logs, the sender of captured packets is guessed from the addresses
+	sentBy  protocol.Perspective
+	payload []byte
+}
+
+// The link types of pcap files, see http://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+var errNotUDP = errors.New("not a UDP packet")
+
+// readCapture reads the UDP datagrams of a pcap or a pcapng file
+func readCapture(r io.Reader) ([]datagram, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(magic) == 0x0a0d0d0a {
+		return readPcapng(br)
+	}
+	return readPcap(br)
+}
+
+// readPcap reads the UDP datagrams of a pcap file. Packets that are not UDP are skipped.
+func readPcap(r io.Reader) ([]datagram, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	var nanoseconds bool
+	switch {
+	case binary.LittleEndian.Uint32(header) == 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == 0xa1b2c3d4:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == 0xa1b23c4d:
+		order, nanoseconds = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header) == 0xa1b23c4d:
+		order, nanoseconds = binary.BigEndian, true
+	default:
+		return nil, errors.New("not a pcap file")
+	}
+	snapLen := order.Uint32(header[16:20])
+	linkType := order.Uint32(header[20:24]) & 0xffff
+
+	var datagrams []datagram
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if err == io.EOF {
+				return datagrams, nil
+			}
+			return nil, err
+		}
+		sec, frac := int64(order.Uint32(record[0:4])), int64(order.Uint32(record[4:8]))
+		if !nanoseconds {
+			frac *= 1000
+		}
+		// don't trust the captured length of a record beyond the snapshot length of the file
+		capturedLength := order.Uint32(record[8:12])
+		if capturedLength > snapLen {
+			return nil, fmt.Errorf("pcap record length %d exceeds the snapshot length %d", capturedLength, snapLen)
+		}
+		data := make([]byte, capturedLength)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		d, err := parseLinkLayer(linkType, data)
+		if err == errNotUDP {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.time = time.Unix(sec, frac)
+		datagrams = append(datagrams, *d)
+	}
+}
+
+// maxPcapngBlockLength limits the length of pcapng blocks, such that a corrupt file doesn't allocate gigabytes.
+// It is the limit used by Wireshark.
+const maxPcapngBlockLength = 16 * 1024 * 1024
+
+// A pcapngInterface is an interface described in a pcapng file
+type pcapngInterface struct {
+	linkType uint32
+	// ticksPerSecond is the timestamp resolution
+	ticksPerSecond uint64
+}
+
+// readPcapng reads the UDP datagrams of a pcapng file. Only Enhanced Packet Blocks are read,
+// packets that are not UDP are skipped.
+func readPcapng(r io.Reader) ([]datagram, error) {
+	var (
+		order      binary.ByteOrder = binary.LittleEndian
+		interfaces []pcapngInterface
+		datagrams  []datagram
+	)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return datagrams, nil
+			}
+			return nil, err
+		}
+		blockType := binary.LittleEndian.Uint32(header[0:4])
+		var magic []byte
+		if blockType == 0x0a0d0d0a {
+			// the byte order of a section is determined by the byte-order magic of its header
+			magic = make([]byte, 4)
+			if _, err := io.ReadFull(r, magic); err != nil {
+				return nil, err
+			}
+			if binary.BigEndian.Uint32(magic) == 0x1a2b3c4d {
+				order = binary.BigEndian
+			} else {
+				order = binary.LittleEndian
+			}
+			interfaces = interfaces[:0]
+		} else {
+			blockType = order.Uint32(header[0:4])
+		}
+		length := int(order.Uint32(header[4:8]))
+		if length < 12+len(magic) || length > maxPcapngBlockLength || length%4 != 0 {
+			return nil, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		// the body is followed by a copy of the block length
+		body := make([]byte, length-8-len(magic))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		body = body[:len(body)-4]
+
+		switch blockType {
+		case 0x00000001: // Interface Description Block
+			if len(body) < 8 {
+				return nil, errors.New("invalid pcapng interface description")
+			}
+			intf := pcapngInterface{linkType: uint32(order.Uint16(body[0:2])), ticksPerSecond: 1e6}
+			options := body[8:]
+			for len(options) >= 4 {
+				code, optLength := order.Uint16(options[0:2]), int(order.Uint16(options[2:4]))
+				if code == 0 || len(options) < 4+optLength {
+					break
+				}
+				if code == 9 && optLength == 1 { // if_tsresol
+					intf.ticksPerSecond = tsresolTicks(options[4])
+				}
+				options = options[4+(optLength+3)/4*4:]
+			}
+			interfaces = append(interfaces, intf)
+		case 0x00000006: // Enhanced Packet Block
+			if len(body) < 20 {
+				return nil, errors.New("invalid pcapng packet")
+			}
+			interfaceID := int(order.Uint32(body[0:4]))
+			if interfaceID >= len(interfaces) {
+				return nil, fmt.Errorf("pcapng packet on unknown interface %d", interfaceID)
+			}
+			intf := interfaces[interfaceID]
+			ticks := uint64(order.Uint32(body[4:8]))<<32 | uint64(order.Uint32(body[8:12]))
+			capturedLength := int(order.Uint32(body[12:16]))
+			if len(body) < 20+capturedLength {
+				return nil, errors.New("invalid pcapng packet")
+			}
+			d, err := parseLinkLayer(intf.linkType, body[20:20+capturedLength])
+			if err == errNotUDP {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			d.time = time.Unix(int64(ticks/intf.ticksPerSecond), int64(ticks%intf.ticksPerSecond*1e9/intf.ticksPerSecond))
+			datagrams = append(datagrams, *d)
+		}
+	}
+}
+
+// tsresolTicks returns the ticks per second of the pcapng option if_tsresol
+func tsresolTicks(tsresol byte) uint64 {
+	base := uint64(10)
+	if tsresol&0x80 != 0 {
+		base = 2
+	}
+	ticks := uint64(1)
+	for i := byte(0); i < tsresol&0x7f; i++ {
+		ticks *= base
+	}
+	return ticks
+}
+
+func parseLinkLayer(linkType uint32, data []byte) (*datagram, error) {
+	switch linkType {
+	case linkTypeNull:
+		// skip the address family, parseIP reads the IP version from the packet
+		if len(data) < 4 {
+			return nil, errNotUDP
+		}
+		return parseIP(data[4:])
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, errNotUDP
+		}
+		etherType, data := binary.BigEndian.Uint16(data[12:14]), data[14:]
+		// skip 802.1Q VLAN tags
+		for etherType == 0x8100 && len(data) >= 4 {
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil, errNotUDP
+		}
+		return parseIP(data)
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return parseIP(data)
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, errNotUDP
+		}
+		return parseIP(data[16:])
+	default:
+		return nil, fmt.Errorf("unsupported link type %d", linkType)
+	}
+}
+
+// parseIP parses an IPv4 or IPv6 packet carrying a UDP datagram
+func parseIP(data []byte) (*datagram, error) {
+	if len(data) == 0 {
+		return nil, errNotUDP
+	}
+	var src, dst net.IP
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return nil, errNotUDP
+		}
+		headerLen := int(data[0]&0xf) * 4
+		// fragments can't be decrypted on their own
+		fragmented := binary.BigEndian.Uint16(data[6:8])&0x3fff != 0
+		if data[9] != 17 || fragmented || len(data) < headerLen {
+			return nil, errNotUDP
+		}
+		src, dst = net.IP(data[12:16]), net.IP(data[16:20])
+		data = data[headerLen:]
+	case 6:
+		// extension headers are not supported
+		if len(data) < 40 || data[6] != 17 {
+			return nil, errNotUDP
+		}
+		src, dst = net.IP(data[8:24]), net.IP(data[24:40])
+		data = data[40:]
+	default:
+		return nil, errNotUDP
+	}
+	if len(data) < 8 {
+		return nil, errNotUDP
+	}
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	if length < 8 || length > len(data) {
+		return nil, errNotUDP
+	}
+	return &datagram{
+		src:     &net.UDPAddr{IP: src, Port: int(binary.BigEndian.Uint16(data[0:2]))},
+		dst:     &net.UDPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(data[2:4]))},
+		payload: data[8:length],
+	}, nil
+}
+
+// readPacketLog reads a packet log, with a line "<client|server> <hex encoded packet>" per packet.
+// The first field is the sender of the packet. Empty lines and comments starting with # are skipped.
+func readPacketLog(r io.Reader) ([]datagram, error) {
+	var datagrams []datagram
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 4*int(protocol.MaxReceivePacketSize))
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("packet log line %d: expected 2 fields, got %d", lineNumber, len(fields))
+		}
+		var sentBy protocol.Perspective
+		switch fields[0] {
+		case "client":
+			sentBy = protocol.PerspectiveClient
+		case "server":
+			sentBy = protocol.PerspectiveServer
+		default:
+			return nil, fmt.Errorf("packet log line %d: unknown sender %s", lineNumber, fields[0])
+		}
+		payload, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("packet log line %d: %s", lineNumber, err.Error())
+		}
+		datagrams = append(datagrams, datagram{sentBy: sentBy, payload: payload})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return datagrams, nil
+}
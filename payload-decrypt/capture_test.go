@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	clientAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 4242}
+	serverAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2).To4(), Port: 443}
+)
+
+// udpPacket builds an IPv4 or IPv6 packet carrying a UDP datagram
+func udpPacket(src, dst *net.UDPAddr, payload []byte) []byte {
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+	if src.IP.To4() != nil {
+		ip := make([]byte, 20, 20+len(udp))
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+		ip[9] = 17
+		copy(ip[12:16], src.IP.To4())
+		copy(ip[16:20], dst.IP.To4())
+		return append(ip, udp...)
+	}
+	ip := make([]byte, 40, 40+len(udp))
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+	ip[6] = 17
+	copy(ip[8:24], src.IP.To16())
+	copy(ip[24:40], dst.IP.To16())
+	return append(ip, udp...)
+}
+
+// ethernetFrame wraps a packet into an Ethernet frame
+func ethernetFrame(etherType uint16, packet []byte) []byte {
+	frame := make([]byte, 14, 14+len(packet))
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	return append(frame, packet...)
+}
+
+// pcapFile builds a pcap file. The n-th packet is captured n seconds and 500 ticks after the epoch.
+func pcapFile(order binary.ByteOrder, magic uint32, linkType uint32, packets ...[]byte) []byte {
+	b := &bytes.Buffer{}
+	header := make([]byte, 24)
+	order.PutUint32(header[0:4], magic)
+	order.PutUint16(header[4:6], 2)
+	order.PutUint16(header[6:8], 4)
+	order.PutUint32(header[16:20], 65535)
+	order.PutUint32(header[20:24], linkType)
+	b.Write(header)
+	for i, packet := range packets {
+		record := make([]byte, 16)
+		order.PutUint32(record[0:4], uint32(i+1))
+		order.PutUint32(record[4:8], 500)
+		order.PutUint32(record[8:12], uint32(len(packet)))
+		order.PutUint32(record[12:16], uint32(len(packet)))
+		b.Write(record)
+		b.Write(packet)
+	}
+	return b.Bytes()
+}
+
+// pcapngBlock builds a little-endian pcapng block
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	block := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(block[0:4], blockType)
+	binary.LittleEndian.PutUint32(block[4:8], uint32(12+len(body)))
+	block = append(block, body...)
+	return append(block, block[4:8]...)
+}
+
+// pcapngFile builds a little-endian pcapng file with nanosecond timestamps.
+// The n-th packet is captured n seconds and 500 nanoseconds after the epoch.
+func pcapngFile(linkType uint16, packets ...[]byte) []byte {
+	b := &bytes.Buffer{}
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], 0x1a2b3c4d)
+	binary.LittleEndian.PutUint16(shb[4:6], 1)
+	binary.LittleEndian.PutUint64(shb[8:16], ^uint64(0))
+	b.Write(pcapngBlock(0x0a0d0d0a, shb))
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], linkType)
+	// if_tsresol 9: nanoseconds, followed by opt_endofopt
+	idb = append(idb, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+	b.Write(pcapngBlock(0x00000001, idb))
+	for i, packet := range packets {
+		epb := make([]byte, 20, 20+len(packet))
+		ticks := uint64(i+1)*1e9 + 500
+		binary.LittleEndian.PutUint32(epb[4:8], uint32(ticks>>32))
+		binary.LittleEndian.PutUint32(epb[8:12], uint32(ticks))
+		binary.LittleEndian.PutUint32(epb[12:16], uint32(len(packet)))
+		binary.LittleEndian.PutUint32(epb[16:20], uint32(len(packet)))
+		b.Write(pcapngBlock(0x00000006, append(epb, packet...)))
+	}
+	return b.Bytes()
+}
+
+var _ = Describe("Reading captures", func() {
+	payload := []byte("foobar")
+	ipv6Client := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4242}
+	ipv6Server := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+	ipv4 := udpPacket(clientAddr, serverAddr, payload)
+	ipv6 := udpPacket(ipv6Client, ipv6Server, payload)
+	microseconds := time.Unix(1, 500*1000)
+	nanoseconds := time.Unix(1, 500)
+
+	for _, tc := range []struct {
+		name     string
+		file     []byte
+		src, dst *net.UDPAddr
+		time     time.Time
+	}{
+		{"a little-endian pcap with Ethernet frames", pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeEthernet, ethernetFrame(0x0800, ipv4)), clientAddr, serverAddr, microseconds},
+		{"a big-endian pcap with raw IP packets", pcapFile(binary.BigEndian, 0xa1b2c3d4, linkTypeRaw, ipv4), clientAddr, serverAddr, microseconds},
+		{"a pcap with nanosecond timestamps", pcapFile(binary.LittleEndian, 0xa1b23c4d, linkTypeIPv4, ipv4), clientAddr, serverAddr, nanoseconds},
+		{"a pcap with loopback packets", pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeNull, append([]byte{2, 0, 0, 0}, ipv4...)), clientAddr, serverAddr, microseconds},
+		{"a pcap with Linux cooked IPv6 packets", pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeLinuxSLL, append(make([]byte, 16), ipv6...)), ipv6Client, ipv6Server, microseconds},
+		{"a pcap with VLAN tagged frames", pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeEthernet, ethernetFrame(0x8100, append([]byte{0, 1, 0x86, 0xdd}, ipv6...))), ipv6Client, ipv6Server, microseconds},
+		{"a pcapng", pcapngFile(linkTypeEthernet, ethernetFrame(0x0800, ipv4)), clientAddr, serverAddr, nanoseconds},
+	} {
+		tc := tc
+
+		It("reads "+tc.name, func() {
+			datagrams, err := readCapture(bytes.NewReader(tc.file))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(datagrams).To(HaveLen(1))
+			Expect(datagrams[0].src).To(Equal(tc.src))
+			Expect(datagrams[0].dst).To(Equal(tc.dst))
+			Expect(datagrams[0].payload).To(Equal(payload))
+			Expect(datagrams[0].time).To(Equal(tc.time))
+		})
+	}
+
+	It("skips packets that are not UDP", func() {
+		tcp := udpPacket(clientAddr, serverAddr, payload)
+		tcp[9] = 6
+		fragment := udpPacket(clientAddr, serverAddr, payload)
+		fragment[6] = 0x20 // more fragments
+		datagrams, err := readCapture(bytes.NewReader(pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeEthernet,
+			ethernetFrame(0x0806, make([]byte, 28)), // ARP
+			ethernetFrame(0x0800, tcp),
+			ethernetFrame(0x0800, fragment),
+			ethernetFrame(0x0800, ipv4),
+		)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(datagrams).To(HaveLen(1))
+		Expect(datagrams[0].time).To(Equal(time.Unix(4, 500*1000)))
+	})
+
+	It("rejects records longer than the snapshot length", func() {
+		file := pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeRaw, ipv4)
+		binary.LittleEndian.PutUint32(file[24+8:24+12], 0xffffffff)
+		_, err := readCapture(bytes.NewReader(file))
+		Expect(err).To(MatchError("pcap record length 4294967295 exceeds the snapshot length 65535"))
+	})
+
+	It("rejects pcapng blocks longer than the maximum block length", func() {
+		file := pcapngFile(linkTypeEthernet, ethernetFrame(0x0800, ipv4))
+		// the Enhanced Packet Block follows the 28 bytes Section Header Block and the 32 bytes Interface Description Block
+		binary.LittleEndian.PutUint32(file[60+4:60+8], 0xfffffffc)
+		_, err := readCapture(bytes.NewReader(file))
+		Expect(err).To(MatchError("invalid pcapng block length 4294967292"))
+	})
+
+	It("rejects files that are not captures", func() {
+		_, err := readCapture(bytes.NewReader(make([]byte, 24)))
+		Expect(err).To(MatchError("not a pcap file"))
+	})
+
+	It("rejects unsupported link types", func() {
+		_, err := readCapture(bytes.NewReader(pcapFile(binary.LittleEndian, 0xa1b2c3d4, 147, ipv4)))
+		Expect(err).To(MatchError("unsupported link type 147"))
+	})
+
+	Context("packet logs", func() {
+		It("reads the sender and the packet of every line", func() {
+			datagrams, err := readPacketLog(strings.NewReader("# a comment\nclient 0102\n\nserver 0304\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(datagrams).To(Equal([]datagram{
+				{sentBy: protocol.PerspectiveClient, payload: []byte{1, 2}},
+				{sentBy: protocol.PerspectiveServer, payload: []byte{3, 4}},
+			}))
+		})
+
+		for _, tc := range []struct {
+			line string
+			err  string
+		}{
+			{"client", "packet log line 1: expected 2 fields, got 1"},
+			{"proxy 0102", "packet log line 1: unknown sender proxy"},
+			{"client xyz", "packet log line 1: encoding/hex: invalid byte: U+0078 'x'"},
+		} {
+			tc := tc
+
+			It("rejects the line "+tc.line, func() {
+				_, err := readPacketLog(strings.NewReader(tc.line))
+				Expect(err).To(MatchError(tc.err))
+			})
+		}
+	})
+})
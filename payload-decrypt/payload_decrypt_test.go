@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const connID protocol.ConnectionID = 0xdecafbad
+
+func newKeyLogEntry(forwardSecure bool, b byte) crypto.KeyLogEntry {
+	return crypto.KeyLogEntry{
+		ConnectionID:  connID,
+		ForwardSecure: forwardSecure,
+		ClientKey:     bytes.Repeat([]byte{b}, 16),
+		ClientIV:      bytes.Repeat([]byte{b + 1}, 4),
+		ServerKey:     bytes.Repeat([]byte{b + 2}, 16),
+		ServerIV:      bytes.Repeat([]byte{b + 3}, 4),
+	}
+}
+
+// sealPacket writes the header and the frames of a packet, and seals it as the sender would
+func sealPacket(sentBy protocol.Perspective, hdr *wire.PublicHeader, aead crypto.AEAD, frames ...wire.Frame) []byte {
+	b := &bytes.Buffer{}
+	ExpectWithOffset(1, hdr.Write(b, protocol.VersionMP, sentBy)).To(Succeed())
+	raw := append([]byte{}, b.Bytes()...)
+	payload := &bytes.Buffer{}
+	for _, f := range frames {
+		ExpectWithOffset(1, f.Write(payload, protocol.VersionMP)).To(Succeed())
+	}
+	return append(raw, aead.Seal(nil, payload.Bytes(), hdr.PacketNumber, raw)...)
+}
+
+func clientHeader(pn protocol.PacketNumber, pnLen protocol.PacketNumberLen) *wire.PublicHeader {
+	return &wire.PublicHeader{
+		ConnectionID:    connID,
+		MultipathFlag:   true,
+		PathID:          1,
+		PacketNumber:    pn,
+		PacketNumberLen: pnLen,
+	}
+}
+
+var _ = Describe("Decrypting packets", func() {
+	var (
+		initial       = newKeyLogEntry(false, 0x10)
+		forwardSecure = newKeyLogEntry(true, 0x20)
+		unknown       = newKeyLogEntry(true, 0x30)
+		d             *decrypter
+	)
+
+	BeforeEach(func() {
+		d = newDecrypter([]crypto.KeyLogEntry{initial, forwardSecure}, protocol.VersionMP)
+	})
+
+	aeadOf := func(e crypto.KeyLogEntry, pers protocol.Perspective) crypto.AEAD {
+		aead, err := e.AEAD(pers)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		return aead
+	}
+
+	for _, tc := range []struct {
+		name   string
+		sealer func() crypto.AEAD
+		level  string
+	}{
+		{"forward-secure", func() crypto.AEAD { return aeadOf(forwardSecure, protocol.PerspectiveClient) }, "forward-secure"},
+		{"initial", func() crypto.AEAD { return aeadOf(initial, protocol.PerspectiveClient) }, "initial"},
+		{"unencrypted", func() crypto.AEAD { return crypto.NewNullAEAD(protocol.PerspectiveClient, protocol.VersionMP) }, "unencrypted"},
+	} {
+		tc := tc
+
+		It("decrypts "+tc.name+" packets", func() {
+			data := sealPacket(protocol.PerspectiveClient, clientHeader(1, protocol.PacketNumberLen2), tc.sealer(),
+				&wire.PingFrame{},
+				&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")},
+			)
+			d.decrypt(&datagram{sentBy: protocol.PerspectiveClient, payload: data})
+			Expect(d.paths).To(Equal([]pathKey{{connID: connID, pathID: 1}}))
+			rec := d.records[d.paths[0]][0]
+			Expect(rec.err).ToNot(HaveOccurred())
+			Expect(rec.level).To(Equal(tc.level))
+			Expect(rec.header.PacketNumber).To(Equal(protocol.PacketNumber(1)))
+			Expect(rec.frames).To(HaveLen(2))
+			Expect(rec.frames[0]).To(BeAssignableToTypeOf(&wire.PingFrame{}))
+			Expect(rec.frames[1].(*wire.StreamFrame).StreamID).To(Equal(protocol.StreamID(5)))
+			Expect(rec.frames[1].(*wire.StreamFrame).Data).To(Equal([]byte("foobar")))
+		})
+	}
+
+	It("reports packets sealed with unknown keys", func() {
+		data := sealPacket(protocol.PerspectiveClient, clientHeader(1, protocol.PacketNumberLen2), aeadOf(unknown, protocol.PerspectiveClient), &wire.PingFrame{})
+		d.decrypt(&datagram{sentBy: protocol.PerspectiveClient, payload: data})
+		rec := d.records[pathKey{connID: connID, pathID: 1}][0]
+		Expect(rec.err).To(MatchError("failed to decrypt"))
+		Expect(rec.frames).To(BeEmpty())
+	})
+
+	It("infers the full packet numbers of truncated packet numbers", func() {
+		sealer := aeadOf(forwardSecure, protocol.PerspectiveClient)
+		for _, pn := range []protocol.PacketNumber{0xff, 0x100, 0x101} {
+			data := sealPacket(protocol.PerspectiveClient, clientHeader(pn, protocol.PacketNumberLen1), sealer, &wire.PingFrame{})
+			d.decrypt(&datagram{sentBy: protocol.PerspectiveClient, payload: data})
+		}
+		var pns []protocol.PacketNumber
+		for _, rec := range d.records[pathKey{connID: connID, pathID: 1}] {
+			Expect(rec.err).ToNot(HaveOccurred())
+			pns = append(pns, rec.header.PacketNumber)
+		}
+		Expect(pns).To(Equal([]protocol.PacketNumber{0xff, 0x100, 0x101}))
+	})
+
+	It("decrypts a capture with the keys of a key log", func() {
+		clientPacket := sealPacket(protocol.PerspectiveClient, clientHeader(1, protocol.PacketNumberLen2), aeadOf(forwardSecure, protocol.PerspectiveClient),
+			&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")},
+		)
+		// the server omits the connection ID, the connection is found by the addresses
+		serverHeader := &wire.PublicHeader{
+			TruncateConnectionID: true,
+			MultipathFlag:        true,
+			PathID:               1,
+			PacketNumber:         1,
+			PacketNumberLen:      protocol.PacketNumberLen2,
+		}
+		serverPacket := sealPacket(protocol.PerspectiveServer, serverHeader, aeadOf(forwardSecure, protocol.PerspectiveServer), &wire.PingFrame{})
+		file := pcapFile(binary.LittleEndian, 0xa1b2c3d4, linkTypeRaw,
+			udpPacket(serverAddr, clientAddr, serverPacket), // can't be assigned before the client sent a packet
+			udpPacket(clientAddr, serverAddr, clientPacket),
+			udpPacket(serverAddr, clientAddr, serverPacket),
+		)
+		keyLog := fmt.Sprintf("QUIC_CRYPTO_FORWARD_SECURE %016x %x %x %x %x\n", uint64(connID),
+			forwardSecure.ClientKey, forwardSecure.ClientIV, forwardSecure.ServerKey, forwardSecure.ServerIV)
+		entries, err := crypto.ParseKeyLog(strings.NewReader(keyLog))
+		Expect(err).ToNot(HaveOccurred())
+		datagrams, err := readCapture(bytes.NewReader(file))
+		Expect(err).ToNot(HaveOccurred())
+
+		d = newDecrypter(entries, protocol.VersionMP)
+		for i := range datagrams {
+			d.decrypt(&datagrams[i])
+		}
+		Expect(d.skipped).To(Equal(1))
+		out := &bytes.Buffer{}
+		d.print(out)
+		Expect(out.String()).To(Equal(`connection 00000000decafbad, path 1
+  0.000000 client -> server (10.0.0.1:4242 -> 10.0.0.2:443), packet 1 (forward-secure)
+    &wire.StreamFrame{StreamID: 5, FinBit: false, Offset: 0x0, Data length: 0x6, Offset + Data length: 0x6}
+  1.000000 server -> client (10.0.0.2:443 -> 10.0.0.1:4242), packet 1 (forward-secure)
+    &wire.PingFrame{}
+`))
+	})
+})
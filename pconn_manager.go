@@ -36,6 +36,12 @@ type pconnManager struct {
 	closed      chan struct{}
 	errorConn   chan error
 	timer       *time.Timer
+
+	capture packetCapture
+
+	// clock is the source of time of the pconnManager and its sessions.
+	// Simulations set a virtual clock, the system clock is used if nil.
+	clock utils.Clock
 }
 
 // Setup the pconn_manager and the pconnAny connection
@@ -48,6 +54,9 @@ func (pcm *pconnManager) setup(pconnArg net.PacketConn, listenAddr net.Addr) err
 	pcm.closed = make(chan struct{}, 1)
 	pcm.errorConn = make(chan error, 1) // Made non-blocking for tests
 	pcm.timer = time.NewTimer(0)
+	if pcm.clock == nil {
+		pcm.clock = utils.SystemClock{}
+	}
 
 	if pconnArg == nil {
 		// XXX (QDC): waiting for native support of SO_REUSEADDR in go...
@@ -94,6 +103,10 @@ listenLoop:
 		// If it does, we only read a truncate packet, which will then end up undecryptable
 		n, addr, err = pconn.ReadFrom(data)
 		if err != nil {
+			if pcm.isRemoved(pconn) {
+				// The local address disappeared, this is not an error of the connection
+				break listenLoop
+			}
 			// XXX (QDC): as soon as a path failed, kill the connection.
 			// TODO (QDC): be more resilient in the future without breaking expectations
 			select {
@@ -112,12 +125,14 @@ listenLoop:
 			// break
 		}
 		data = data[:n]
+		rcvTime := pcm.clock.Now()
+		pcm.capture.receivedPacket(rcvTime, pconn.LocalAddr(), addr, data)
 
 		rcvRawPacket := &receivedRawPacket{
 			rcvPconn:   pconn,
 			remoteAddr: addr,
 			data:       data,
-			rcvTime:    time.Now(),
+			rcvTime:    rcvTime,
 		}
 
 		pcm.rcvRawPackets <- rcvRawPacket
@@ -207,11 +222,64 @@ func isLinkLocal(ip net.IP) bool {
 	return false
 }
 
+// isRemoved returns true if the pconn was closed because its local address disappeared
+func (pcm *pconnManager) isRemoved(pconn net.PacketConn) bool {
+	if pconn == pcm.pconnAny {
+		return false
+	}
+	pcm.mutex.Lock()
+	defer pcm.mutex.Unlock()
+	_, ok := pcm.pconns[pconn.LocalAddr().String()]
+	return !ok
+}
+
+// removePconns closes the pconns of the local addresses whose IP is not present anymore
+func (pcm *pconnManager) removePconns(presentIPs []net.IP) {
+	pcm.mutex.Lock()
+	var removed []net.PacketConn
+	remaining := make([]net.UDPAddr, 0, len(pcm.localAddrs))
+	for _, locAddr := range pcm.localAddrs {
+		present := false
+		for _, ip := range presentIPs {
+			if ip.Equal(locAddr.IP) {
+				present = true
+				break
+			}
+		}
+		if present {
+			remaining = append(remaining, locAddr)
+			continue
+		}
+		if pconn, ok := pcm.pconns[locAddr.String()]; ok {
+			removed = append(removed, pconn)
+			delete(pcm.pconns, locAddr.String())
+		}
+		if utils.Debug() {
+			utils.Debugf("Local address %s disappeared", locAddr.String())
+		}
+	}
+	pcm.localAddrs = remaining
+	pcm.mutex.Unlock()
+
+	if len(removed) == 0 {
+		return
+	}
+	for _, pconn := range removed {
+		pconn.Close()
+	}
+	// Don't block
+	select {
+	case pcm.changePaths <- struct{}{}:
+	default:
+	}
+}
+
 func (pcm *pconnManager) createPconns() error {
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return err
 	}
+	var presentIPs []net.IP
 	for _, i := range ifaces {
 		// TODO (QDC): do this in a generic way
 		if !strings.Contains(i.Name, "eth") && !strings.Contains(i.Name, "rmnet") &&
@@ -235,6 +303,7 @@ func (pcm *pconnManager) createPconns() error {
 			if !isLinkLocal(ip) {
 				continue
 			}
+			presentIPs = append(presentIPs, ip)
 			// TODO (QDC): Clearly not optimal
 			found := false
 		lookingLoop:
@@ -249,10 +318,13 @@ func (pcm *pconnManager) createPconns() error {
 				if err != nil {
 					return err
 				}
+				pcm.mutex.Lock()
 				pcm.localAddrs = append(pcm.localAddrs, *locAddr)
+				pcm.mutex.Unlock()
 			}
 		}
 	}
+	pcm.removePconns(presentIPs)
 	return nil
 }
 
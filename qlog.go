@@ -0,0 +1,248 @@
+package quic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// qlogRecordSeparator starts every record of a JSON-SEQ file
+const qlogRecordSeparator = 0x1e
+
+type qlogHeader struct {
+	QlogVersion string    `json:"qlog_version"`
+	QlogFormat  string    `json:"qlog_format"`
+	Title       string    `json:"title"`
+	Trace       qlogTrace `json:"trace"`
+}
+
+type qlogTrace struct {
+	VantagePoint qlogVantagePoint `json:"vantage_point"`
+	CommonFields qlogCommonFields `json:"common_fields"`
+}
+
+type qlogVantagePoint struct {
+	Type string `json:"type"`
+}
+
+type qlogCommonFields struct {
+	GroupID       string  `json:"group_id"`
+	ReferenceTime float64 `json:"reference_time"`
+	TimeFormat    string  `json:"time_format"`
+}
+
+type qlogEvent struct {
+	Time float64     `json:"time"`
+	Name string      `json:"name"`
+	Data interface{} `json:"data"`
+}
+
+// A qlogTracer writes the events of a session as qlog, serialized as JSON-SEQ
+type qlogTracer struct {
+	mutex sync.Mutex
+
+	w      io.WriteCloser
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	start  time.Time
+	closed bool
+}
+
+var _ Tracer = &qlogTracer{}
+
+// NewQlogTracer creates a Tracer writing the events of a session to w, in the JSON-SEQ serialization of qlog.
+// All events concerning a path carry its ID as "path_id", scheduler decisions and path state changes
+// are written as "multipath:path_selected" and "multipath:path_updated" events. w is closed with the Tracer.
+func NewQlogTracer(w io.WriteCloser, isClient bool, connectionID ConnectionID) Tracer {
+	t := &qlogTracer{
+		w:     w,
+		buf:   bufio.NewWriter(w),
+		start: time.Now(),
+	}
+	t.enc = json.NewEncoder(t.buf)
+	vantagePoint := "server"
+	if isClient {
+		vantagePoint = "client"
+	}
+	t.writeRecord(qlogHeader{
+		QlogVersion: "0.3",
+		QlogFormat:  "JSON-SEQ",
+		Title:       "quic-go multipath",
+		Trace: qlogTrace{
+			VantagePoint: qlogVantagePoint{Type: vantagePoint},
+			CommonFields: qlogCommonFields{
+				GroupID:       fmt.Sprintf("%016x", uint64(connectionID)),
+				ReferenceTime: qlogMilliseconds(time.Duration(t.start.UnixNano())),
+				TimeFormat:    "relative",
+			},
+		},
+	})
+	return t
+}
+
+// QlogDir returns a function for Config.Tracer, that writes the events of every session to a qlog file in dir.
+// The files are named after the connection ID and the perspective, e.g. "0123456789abcdef_client.sqlog".
+// Sessions are not traced if the file can't be created.
+func QlogDir(dir string) func(isClient bool, connectionID ConnectionID) Tracer {
+	return func(isClient bool, connectionID ConnectionID) Tracer {
+		perspective := "server"
+		if isClient {
+			perspective = "client"
+		}
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%016x_%s.sqlog", uint64(connectionID), perspective)))
+		if err != nil {
+			utils.Errorf("Not tracing connection %x: %s", connectionID, err.Error())
+			return nil
+		}
+		return NewQlogTracer(f, isClient, connectionID)
+	}
+}
+
+func (t *qlogTracer) SentPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, frames []TracedFrame) {
+	t.writeEvent("transport:packet_sent", qlogPacket(pathID, packetNumber, size, frames))
+}
+
+func (t *qlogTracer) ReceivedPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, frames []TracedFrame) {
+	t.writeEvent("transport:packet_received", qlogPacket(pathID, packetNumber, size, frames))
+}
+
+func (t *qlogTracer) LostPacket(pathID PathID, packetNumber PacketNumber) {
+	t.writeEvent("recovery:packet_lost", map[string]interface{}{
+		"path_id": pathID,
+		"header":  map[string]interface{}{"packet_number": packetNumber},
+	})
+}
+
+func (t *qlogTracer) UpdatedCongestionState(pathID PathID, state CongestionState) {
+	t.writeEvent("recovery:metrics_updated", map[string]interface{}{
+		"path_id":           pathID,
+		"congestion_window": state.CongestionWindow,
+		"bytes_in_flight":   state.BytesInFlight,
+		"smoothed_rtt":      qlogMilliseconds(state.SmoothedRTT),
+		"min_rtt":           qlogMilliseconds(state.MinRTT),
+		"latest_rtt":        qlogMilliseconds(state.LatestRTT),
+	})
+}
+
+func (t *qlogTracer) SelectedPath(pathID PathID, redundantPaths []PathID) {
+	data := map[string]interface{}{"path_id": pathID}
+	if len(redundantPaths) > 0 {
+		// PathIDs are bytes, which encoding/json would write as a base64 string
+		ids := make([]uint64, 0, len(redundantPaths))
+		for _, id := range redundantPaths {
+			ids = append(ids, uint64(id))
+		}
+		data["redundant_path_ids"] = ids
+	}
+	t.writeEvent("multipath:path_selected", data)
+}
+
+func (t *qlogTracer) UpdatedPath(event PathEvent) {
+	data := map[string]interface{}{
+		"path_id": event.PathID,
+		"state":   event.Type.String(),
+		"reason":  event.Reason,
+	}
+	if event.LocalAddr != nil {
+		data["local_address"] = event.LocalAddr.String()
+	}
+	if event.RemoteAddr != nil {
+		data["remote_address"] = event.RemoteAddr.String()
+	}
+	t.writeEvent("multipath:path_updated", data)
+}
+
+func (t *qlogTracer) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	if err := t.buf.Flush(); err != nil {
+		utils.Errorf("Failed to write qlog: %s", err.Error())
+	}
+	if err := t.w.Close(); err != nil {
+		utils.Errorf("Failed to close qlog: %s", err.Error())
+	}
+}
+
+func (t *qlogTracer) writeEvent(name string, data interface{}) {
+	t.writeRecord(qlogEvent{
+		Time: qlogMilliseconds(time.Since(t.start)),
+		Name: name,
+		Data: data,
+	})
+}
+
+func (t *qlogTracer) writeRecord(record interface{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.buf.WriteByte(qlogRecordSeparator)
+	if err := t.enc.Encode(record); err != nil {
+		utils.Errorf("Failed to encode qlog record: %s", err.Error())
+	}
+}
+
+func qlogPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, frames []TracedFrame) map[string]interface{} {
+	qlogFrames := make([]map[string]interface{}, 0, len(frames))
+	for _, f := range frames {
+		qlogFrames = append(qlogFrames, qlogFrame(f))
+	}
+	return map[string]interface{}{
+		"path_id": pathID,
+		"header":  map[string]interface{}{"packet_number": packetNumber},
+		"raw":     map[string]interface{}{"length": size},
+		"frames":  qlogFrames,
+	}
+}
+
+func qlogFrame(f TracedFrame) map[string]interface{} {
+	frame := map[string]interface{}{"frame_type": f.Type}
+	switch f.Type {
+	case "stream":
+		frame["stream_id"] = f.StreamID
+		frame["offset"] = f.Offset
+		frame["length"] = f.Length
+		frame["fin"] = f.Fin
+	case "ack", "close_path":
+		ranges := make([][2]PacketNumber, 0, len(f.AckRanges))
+		// qlog lists the acknowledged ranges in ascending order
+		for i := len(f.AckRanges) - 1; i >= 0; i-- {
+			ranges = append(ranges, f.AckRanges[i])
+		}
+		frame["path_id"] = f.PathID
+		frame["acked_ranges"] = ranges
+	case "reset_stream":
+		frame["stream_id"] = f.StreamID
+		frame["error_code"] = f.ErrorCode
+		frame["final_size"] = f.Offset
+	case "max_data":
+		frame["maximum"] = f.Offset
+	case "max_stream_data":
+		frame["stream_id"] = f.StreamID
+		frame["maximum"] = f.Offset
+	case "stream_data_blocked":
+		frame["stream_id"] = f.StreamID
+	case "connection_close", "goaway":
+		frame["error_code"] = f.ErrorCode
+		frame["reason"] = f.Reason
+	case "path_status":
+		frame["path_id"] = f.PathID
+	}
+	return frame
+}
+
+func qlogMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,147 @@
+package quic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type qlogBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *qlogBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+var _ = Describe("qlog", func() {
+	var (
+		buf    *qlogBuffer
+		tracer Tracer
+	)
+
+	records := func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, raw := range bytes.Split(buf.Bytes(), []byte{qlogRecordSeparator}) {
+			if len(raw) == 0 {
+				continue
+			}
+			var record map[string]interface{}
+			ExpectWithOffset(1, json.Unmarshal(raw, &record)).To(Succeed())
+			records = append(records, record)
+		}
+		return records
+	}
+
+	lastEvent := func() (string, map[string]interface{}) {
+		rs := records()
+		event := rs[len(rs)-1]
+		return event["name"].(string), event["data"].(map[string]interface{})
+	}
+
+	BeforeEach(func() {
+		buf = &qlogBuffer{}
+		tracer = NewQlogTracer(buf, true, 0xdeadbeef)
+	})
+
+	It("writes a header", func() {
+		tracer.Close()
+		Expect(buf.closed).To(BeTrue())
+		rs := records()
+		Expect(rs).To(HaveLen(1))
+		Expect(rs[0]).To(HaveKeyWithValue("qlog_format", "JSON-SEQ"))
+		trace := rs[0]["trace"].(map[string]interface{})
+		Expect(trace["vantage_point"]).To(HaveKeyWithValue("type", "client"))
+		Expect(trace["common_fields"]).To(HaveKeyWithValue("group_id", "00000000deadbeef"))
+	})
+
+	It("writes sent packets", func() {
+		tracer.SentPacket(3, 42, 1200, []TracedFrame{
+			{Type: "stream", StreamID: 5, Offset: 0, Length: 1000},
+			{Type: "ack", PathID: 1, AckRanges: [][2]PacketNumber{{8, 10}, {1, 5}}},
+		})
+		tracer.Close()
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:packet_sent"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(3)))
+		Expect(data["header"]).To(HaveKeyWithValue("packet_number", BeEquivalentTo(42)))
+		Expect(data["raw"]).To(HaveKeyWithValue("length", BeEquivalentTo(1200)))
+		frames := data["frames"].([]interface{})
+		Expect(frames).To(HaveLen(2))
+		Expect(frames[0]).To(HaveKeyWithValue("frame_type", "stream"))
+		Expect(frames[0]).To(HaveKeyWithValue("offset", BeEquivalentTo(0)))
+		Expect(frames[1]).To(HaveKeyWithValue("frame_type", "ack"))
+		Expect(frames[1].(map[string]interface{})["acked_ranges"]).To(Equal([]interface{}{
+			[]interface{}{1.0, 5.0},
+			[]interface{}{8.0, 10.0},
+		}))
+	})
+
+	It("writes received and lost packets", func() {
+		tracer.ReceivedPacket(1, 7, 100, nil)
+		tracer.LostPacket(1, 8)
+		tracer.Close()
+		rs := records()
+		Expect(rs).To(HaveLen(3))
+		Expect(rs[1]).To(HaveKeyWithValue("name", "transport:packet_received"))
+		Expect(rs[1]["data"]).To(HaveKeyWithValue("path_id", BeEquivalentTo(1)))
+		Expect(rs[2]).To(HaveKeyWithValue("name", "recovery:packet_lost"))
+		Expect(rs[2]["data"].(map[string]interface{})["header"]).To(HaveKeyWithValue("packet_number", BeEquivalentTo(8)))
+	})
+
+	It("writes the congestion state", func() {
+		tracer.UpdatedCongestionState(2, CongestionState{CongestionWindow: 14000, BytesInFlight: 1200, SmoothedRTT: 25 * time.Millisecond})
+		tracer.Close()
+		name, data := lastEvent()
+		Expect(name).To(Equal("recovery:metrics_updated"))
+		Expect(data).To(HaveKeyWithValue("congestion_window", BeEquivalentTo(14000)))
+		Expect(data).To(HaveKeyWithValue("smoothed_rtt", BeEquivalentTo(25)))
+	})
+
+	It("writes scheduler decisions and path updates", func() {
+		tracer.SelectedPath(1, []PathID{3})
+		tracer.UpdatedPath(PathEvent{Type: PathPotentiallyFailed, PathID: 1, RemoteAddr: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}, Reason: "no activity"})
+		tracer.Close()
+		rs := records()
+		Expect(rs).To(HaveLen(3))
+		Expect(rs[1]).To(HaveKeyWithValue("name", "multipath:path_selected"))
+		Expect(rs[1]["data"]).To(HaveKeyWithValue("redundant_path_ids", []interface{}{3.0}))
+		name, data := lastEvent()
+		Expect(name).To(Equal("multipath:path_updated"))
+		Expect(data).To(HaveKeyWithValue("state", "potentially failed"))
+		Expect(data).To(HaveKeyWithValue("remote_address", "10.0.0.2:443"))
+		Expect(data).ToNot(HaveKey("local_address"))
+	})
+
+	It("ignores events after it was closed", func() {
+		tracer.Close()
+		tracer.LostPacket(1, 1)
+		tracer.Close()
+		Expect(records()).To(HaveLen(1))
+	})
+
+	It("writes a file per session", func() {
+		dir, err := ioutil.TempDir("", "qlog")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		tracer := QlogDir(dir)(false, 0x1337)
+		Expect(tracer).ToNot(BeNil())
+		tracer.Close()
+		data, err := ioutil.ReadFile(filepath.Join(dir, "0000000000001337_server.sqlog"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data[0]).To(Equal(byte(qlogRecordSeparator)))
+	})
+
+	It("doesn't trace sessions if the file can't be created", func() {
+		Expect(QlogDir("/does/not/exist")(true, 1)).To(BeNil())
+	})
+})
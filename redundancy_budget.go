@@ -0,0 +1,65 @@
+package quic
+
+import (
+	"math"
+	"time"
+)
+
+// A redundancyBudget limits the stream bytes duplicated on redundant paths,
+// both as a ratio of the stream bytes sent and as a rate.
+// Once the budget is spent, duplication stops until it replenishes.
+type redundancyBudget struct {
+	maxRatio       float64
+	bytesPerSecond uint64
+
+	sentBytes       uint64
+	duplicatedBytes uint64
+
+	// tokens are the bytes that may be duplicated at lastUpdate, if the rate is limited
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// newRedundancyBudget creates a redundancy budget. A limit of zero disables the limit.
+func newRedundancyBudget(maxRatio float64, bytesPerSecond uint64) *redundancyBudget {
+	return &redundancyBudget{
+		maxRatio:       maxRatio,
+		bytesPerSecond: bytesPerSecond,
+		tokens:         float64(bytesPerSecond),
+	}
+}
+
+// OnSent registers the stream bytes of a packet sent by the scheduler, including retransmitted stream bytes
+func (b *redundancyBudget) OnSent(bytes uint64) {
+	b.sentBytes += bytes
+}
+
+// OnDuplicated consumes the budget for duplicated stream bytes
+func (b *redundancyBudget) OnDuplicated(now time.Time, bytes uint64) {
+	b.replenish(now)
+	b.duplicatedBytes += bytes
+	if b.bytesPerSecond > 0 {
+		b.tokens -= float64(bytes)
+	}
+}
+
+// Allows returns if the stream bytes may be duplicated
+func (b *redundancyBudget) Allows(now time.Time, bytes uint64) bool {
+	if b.maxRatio > 0 && float64(b.duplicatedBytes+bytes) > b.maxRatio*float64(b.sentBytes) {
+		return false
+	}
+	if b.bytesPerSecond > 0 {
+		b.replenish(now)
+		if float64(bytes) > b.tokens {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *redundancyBudget) replenish(now time.Time) {
+	if !b.lastUpdate.IsZero() && now.After(b.lastUpdate) {
+		b.tokens = math.Min(b.tokens+now.Sub(b.lastUpdate).Seconds()*float64(b.bytesPerSecond), float64(b.bytesPerSecond))
+	}
+	b.lastUpdate = now
+}
@@ -0,0 +1,54 @@
+package quic
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redundancy budget", func() {
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("doesn't limit anything by default", func() {
+		b := newRedundancyBudget(0, 0)
+		Expect(b.Allows(now, 1e9)).To(BeTrue())
+		b.OnDuplicated(now, 1e9)
+		Expect(b.Allows(now, 1e9)).To(BeTrue())
+	})
+
+	It("limits the ratio of duplicated bytes", func() {
+		b := newRedundancyBudget(0.1, 0)
+		Expect(b.Allows(now, 100)).To(BeFalse())
+		b.OnSent(1000)
+		Expect(b.Allows(now, 100)).To(BeTrue())
+		b.OnDuplicated(now, 100)
+		Expect(b.Allows(now, 1)).To(BeFalse())
+		// The budget replenishes with the bytes sent
+		b.OnSent(10)
+		Expect(b.Allows(now, 1)).To(BeTrue())
+	})
+
+	It("limits the rate of duplicated bytes", func() {
+		b := newRedundancyBudget(0, 1000)
+		Expect(b.Allows(now, 1001)).To(BeFalse())
+		Expect(b.Allows(now, 1000)).To(BeTrue())
+		b.OnDuplicated(now, 1000)
+		Expect(b.Allows(now, 1)).To(BeFalse())
+		now = now.Add(100 * time.Millisecond)
+		Expect(b.Allows(now, 100)).To(BeTrue())
+		Expect(b.Allows(now, 101)).To(BeFalse())
+	})
+
+	It("doesn't accumulate more than one second of the rate", func() {
+		b := newRedundancyBudget(0, 1000)
+		b.OnDuplicated(now, 500)
+		now = now.Add(time.Hour)
+		Expect(b.Allows(now, 1000)).To(BeTrue())
+		Expect(b.Allows(now, 1001)).To(BeFalse())
+	})
+})
@@ -1,11 +1,7 @@
 package quic
 
 import (
-	"fmt"
-	"os"
-	"sort"
-	"strconv"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
@@ -15,17 +11,28 @@ import (
 )
 
 var (
-	// SchedulerAlgorithm is the algorithm for packet -> path scheduling
+	// SchedulerAlgorithm is the algorithm for packet -> path scheduling, used if the Config doesn't set a Scheduler
+	//
+	// Deprecated: set Config.Scheduler instead.
 	SchedulerAlgorithm string
-	// RedundantSending is activated for certain schedulers
+	// RedundantSending stops the built-in schedulers from duplicating packets sent on unprobed paths.
+	// Copies are sent on the redundant paths selected by the scheduler anyway.
 	RedundantSending bool
 	// CongestionControl can be set to 'olia' or 'cubic', default is uncoupled Cubic , experiment vegas
+	// It is used if the Config doesn't set a CongestionControl
+	//
+	// Deprecated: set Config.CongestionControl instead.
 	CongestionControl string
-	// LogPayload indicates if send goodput Bytes should be logged to file
-	LogPayload = true
+	// LogPayload makes sessions not setting Config.MetricsSink write their measurements to the working directory,
+	// using MetricsDir
+	//
+	// Deprecated: set Config.MetricsSink instead.
+	LogPayload bool
 )
 
-// SetSchedulerAlgorithm is used to adapt the scheduler
+// SetSchedulerAlgorithm is used to adapt the scheduler of sessions not setting Config.Scheduler
+//
+// Deprecated: set Config.Scheduler instead.
 func SetSchedulerAlgorithm(scheduler string) {
 	s := make([]byte, len(scheduler))
 	copy(s, scheduler)
@@ -33,7 +40,9 @@ func SetSchedulerAlgorithm(scheduler string) {
 	//RedundantSending = SchedulerAlgorithm == "lowRTT" //"utilRepair" //"RR" //oppRedundant" //"lowRTT" //|| SchedulerAlgorithm == "oppRedundant"
 }
 
-// SetCongestionControl is used to set the CC algorithm
+// SetCongestionControl is used to set the CC algorithm of sessions not setting Config.CongestionControl
+//
+// Deprecated: set Config.CongestionControl instead.
 func SetCongestionControl(cc string) {
 	s := make([]byte, len(cc))
 	copy(s, cc)
@@ -47,12 +56,15 @@ type dupID struct {
 }
 
 type scheduler struct {
+	// algorithm selects the paths, the scheduler only performs the sending
+	algorithm Scheduler
+
 	// XXX Currently round-robin based, inspired from MPTCP scheduler
 	quotas map[protocol.PathID]uint
 
 	pathsRef *map[protocol.PathID]*path
-	// Map duplicated Packets for selective drop
-	dupPackets               map[dupID]dupID
+	// Map duplicated Packets to their copies for selective drop
+	dupPackets               map[dupID][]dupID
 	duplicatedPackets        uint64
 	droppedDuplicatedPackets uint64
 
@@ -64,36 +76,38 @@ type scheduler struct {
 	// Track which path was used for last schedule
 	lastPath *path
 
-	// Count the number of lower RTT path selection for debugging purposes in utilRepair
-	lowerRTTSchedules uint64
 	// Count the number of path switches by scheduler decision
 	pathSwitches uint64
-	// Count the number of CW blockings on the best path
-	cwBlocks uint64
-	// Count the number of each path selected as best path
-	bestPathSelection map[protocol.PathID]uint64
-	pathLogMapSync    sync.RWMutex
+
+	// reinjectedPackets counts the packets of failing paths copied onto other paths
+	reinjectedPackets uint64
+
+	// redundancy limits the stream bytes duplicated on redundant paths
+	redundancy *redundancyBudget
+	// redundancyBudgetSkips counts the packets not duplicated because the budget was spent
+	redundancyBudgetSkips uint64
 
 	// Paths for redundant resending
 	redundantPaths []*path
 
 	// logStartTS is used to create relative stamps to avoid unsynched clock blur over all paths
 	logStartTS    int64
-	logFiles      map[protocol.PathID]*os.File
 	lastSentBytes map[protocol.PathID]uint64
 	lastLogTS     float64
 }
 
 func (sch *scheduler) setup() {
 	sch.quotas = make(map[protocol.PathID]uint)
-	sch.dupPackets = make(map[dupID]dupID)
-	sch.bestPathSelection = make(map[protocol.PathID]uint64)
+	sch.dupPackets = make(map[dupID][]dupID)
+	if sch.redundancy == nil {
+		sch.redundancy = newRedundancyBudget(0, 0)
+	}
 }
 
 func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, retransmitPacket *ackhandler.Packet, pth *path) {
 	// check for retransmissions first
 	for {
-		// TODO add ability to reinject on another path
+		// Failing paths reinject their packets on other paths, see reinjectPackets
 		// XXX We need to check on ALL paths if any packet should be first retransmitted
 		s.pathsLock.RLock()
 	retransmitLoop:
@@ -119,11 +133,15 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 			return
 		}
 		utils.Debugf("\tDequeueing retransmission of packet 0x%x from path %d", retransmitPacket.PacketNumber, pth.pathID)
+		// The stream frames don't need to be retransmitted while a reinjected copy is in flight
+		copyInFlight := sch.forgetCopy(pth.pathID, retransmitPacket.PacketNumber)
 		// resend the frames that were in the packet
 		for _, frame := range retransmitPacket.GetFramesForRetransmission() {
 			switch f := frame.(type) {
 			case *wire.StreamFrame:
-				s.streamFramer.AddFrameForRetransmission(f)
+				if !copyInFlight {
+					s.streamFramer.AddFrameForRetransmission(f)
+				}
 			case *wire.WindowUpdateFrame:
 				// only retransmit WindowUpdates if the stream is not yet closed and the we haven't sent another WindowUpdate with a higher ByteOffset for the stream
 				// XXX Should it be adapted to multiple paths?
@@ -134,6 +152,15 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 			case *wire.PathsFrame:
 				// Schedule a new PATHS frame to send
 				s.schedulePathsFrame()
+			case *wire.PathChallengeFrame:
+				// Challenge the peer again with new data, as long as the path is not validated
+				if !pth.validated.Get() {
+					if err := s.sendPathChallenge(pth); err != nil {
+						utils.Errorf("Failed to send PATH_CHALLENGE on path %x: %s", pth.pathID, err)
+					}
+				}
+			case *wire.PathResponseFrame:
+				// Don't retransmit responses, the peer sends a new PATH_CHALLENGE
 			default:
 				s.packer.QueueControlFrame(frame, pth)
 			}
@@ -142,337 +169,146 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 	return
 }
 
-func (sch *scheduler) selectPathRoundRobin(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-	if sch.quotas == nil {
-		sch.setup()
-	}
-
-	// TODO cope with decreasing number of paths (needed?)
-	var selectedPath *path
-	var lowerQuota, currentQuota uint
-	var ok bool
-
-	// Max possible value for lowerQuota at the beginning
-	lowerQuota = ^uint(0)
-
-pathLoop:
-	for pathID, pth := range s.paths {
-		// Don't block path usage if we retransmit, even on another path
-		if !hasRetransmission && !pth.SendingAllowed() {
-			continue pathLoop
-		}
-
-		// If this path is potentially failed, do no consider it for sending
-		if pth.potentiallyFailed.Get() {
-			continue pathLoop
-		}
-
-		// XXX Prevent using initial pathID if multiple paths
-		if pathID == protocol.InitialPathID {
-			continue pathLoop
-		}
+// Exclude initial path from selection and discovery of new paths.
+func (sch *scheduler) selectInitialPath(s *session, ctx *ScheduleContext, fromPth *path) *path {
 
-		currentQuota, ok = sch.quotas[pathID]
-		if !ok {
-			sch.quotas[pathID] = 0
-			currentQuota = 0
+	// XXX Avoid using PathID 0 if there is more than 1 path
+	if len(s.paths) <= 1 {
+		initialPth := s.paths[protocol.InitialPathID]
+		// Retransmissions may exceed the congestion window, but not the anti-amplification budget
+		if initialPth.amplificationLimited(protocol.MaxPacketSize) {
+			return nil
 		}
-
-		if currentQuota < lowerQuota {
-			selectedPath = pth
-			lowerQuota = currentQuota
+		if !ctx.HasRetransmission && !initialPth.SendingAllowed() {
+			return nil
 		}
+		return s.paths[protocol.InitialPathID]
 	}
 
-	return selectedPath
-
-}
-
-func (sch *scheduler) selectPathLowLatency(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-
-	var selectedPath *path
-	var lowerRTT time.Duration
-	var currentRTT time.Duration
-	selectedPathID := protocol.PathID(255)
-	hasRetransmission = false
-	hasStreamRetransmission = false
-	// PathID0 := "path0"
-	// PathID1 := "path1"
-
-pathLoop:
-	for pathID, pth := range s.paths {
-		// Don't block path usage if we retransmit, even on another path
-		// DERA: Only consider paths, that have space in their cwnd for 'new' packets.
-		// Or consider all valid paths for outstanding retransmissions.
-		// experiment check how many paths does it used
-		// if pth == s.paths[1] {
-		// 	pth.pathID = 1
-		// 	//pathSwitches shows how many switch between PATHs , len(s.paths) shows how many PATHS = 2 one session had, 0 ///and 1
-		// 	fmt.Println(sch.pathSwitches, s.paths[1].sentPacketHandler.GetCongestionWindow(), s.paths[1].sentPacketHandler.GetBytesInFlight(), time.Now().UnixNano(), len(s.paths), PathID1) //, RemotA)
-
-		// } else if pth == s.paths[0] {
-		// 	pth.pathID = 0
-		// 	//pathSwitches shows how many switch between PATHs , len(s.paths) shows how many PATHS = 2 one session had, 0 ///and 1
-		// 	fmt.Println(sch.pathSwitches, s.paths[0].sentPacketHandler.GetCongestionWindow(), s.paths[0].sentPacketHandler.GetBytesInFlight(), time.Now().UnixNano(), len(s.paths), PathID0) //, RemotA)
-		// } else {
-		// 	fmt.Println("Other paths")
-		// }
-		// end
-
-		if !hasRetransmission && !pth.SendingAllowed() {
-			continue pathLoop
-		}
-
-		// If this path is potentially failed, do not consider it for sending
-		if pth.potentiallyFailed.Get() {
-			continue pathLoop
-		}
-
-		// Prevent using initial pathID if multiple paths
-		if pathID == protocol.InitialPathID {
-			continue pathLoop
-		}
-		currentRTT = pth.rttStats.SmoothedRTT()
-
-		// Prefer staying single-path if not blocked by current path
-		// Don't consider this sample if the smoothed RTT is 0
-		if lowerRTT != 0 && currentRTT == 0 {
-			continue pathLoop
-		}
-
-		//Case if we have multiple paths unprobed
-		if currentRTT == 0 {
-			currentQuota, ok := sch.quotas[pathID]
-			if !ok {
-				sch.quotas[pathID] = 0
-				currentQuota = 0
+	// FIXME Only works at the beginning... Cope with new paths during the connection
+	if ctx.HasRetransmission && ctx.HasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
+		// Is there any other path with a lower number of packet sent?
+		currentQuota := sch.quotas[fromPth.pathID]
+		for _, candidate := range ctx.Paths {
+			pathID := candidate.PathID()
+			if pathID == fromPth.pathID {
+				continue
 			}
-			lowerQuota, _ := sch.quotas[selectedPathID]
-			if selectedPath != nil && currentQuota > lowerQuota {
-				continue pathLoop
+			// The congestion window was checked when duplicating the packet
+			if sch.quotas[pathID] < currentQuota {
+				return s.paths[pathID]
 			}
 		}
-
-		if currentRTT != 0 && lowerRTT != 0 && selectedPath != nil && currentRTT >= lowerRTT {
-			continue pathLoop
-		}
-		// Update
-		lowerRTT = currentRTT
-		selectedPath = pth //s.paths[1] //pth
-		selectedPathID = pth.pathID
-		//	In Recovery 3, SlowStart 1, Congestion Avoidance 2
-		//if congestion.Evaluate1 == true {
-		fmt.Println(pathID, pth.sentPacketHandler.GetCongestionWindow(), pth.sentPacketHandler.GetBytesInFlight(), pth.rttStats.SmoothedRTT(), time.Now().UnixNano())
-		//} else if congestion.Evaluate2 == true {
-		//	fmt.Println(pathID, pth.sentPacketHandler.GetCongestionWindow(), pth.sentPacketHandler.GetBytesInFlight(), pth.rttStats.SmoothedRTT(), time.Now().UnixNano(), 1)
-		//} else if congestion.Evaluate1 != true && congestion.Evaluate2 != true {
-		//	fmt.Println(pathID, pth.sentPacketHandler.GetCongestionWindow(), pth.sentPacketHandler.GetBytesInFlight(), pth.rttStats.SmoothedRTT(), time.Now().UnixNano(), 2)
-		//	}
 	}
 
-	return selectedPath
+	return nil
 }
 
-// utilRepair scheduler V0.4
-func (sch *scheduler) selectPathUtilRepair(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-
-	var maxPath *path
-	var higherTP float64
-	var currentTP float64
-	var maxRTT float64
-
-	type pStat struct {
-		path       *path
-		CW         uint64
-		RTT        float64
-		Throughput float64
-	}
-	var pathStats []pStat
-
-pathLoop:
+// Lock of s.paths must be held
+func (sch *scheduler) newScheduleContext(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *ScheduleContext {
+	ctx := &ScheduleContext{
+		Paths:                   make([]SchedulerPath, 0, len(s.paths)),
+		HasRetransmission:       hasRetransmission,
+		HasStreamRetransmission: hasStreamRetransmission,
+	}
+	// Avoid storing a typed nil in the interface
+	if fromPth != nil {
+		ctx.RetransmissionPath = fromPth
+	}
+	// XXX (QDC): need a additional check because of tests
+	if s.flowControlManager != nil {
+		ctx.ConnectionSendWindow = uint64(s.flowControlManager.RemainingConnectionWindowSize())
+	}
+	if s.streamFramer != nil {
+		ctx.QueuedBytes = uint64(s.streamFramer.QueuedBytes())
+	}
+	ctx.RedundancyBudgetSpent = !sch.redundancy.Allows(time.Now(), uint64(protocol.MaxPacketSize))
+	// Backup paths are only handed to the scheduler if no active path works
+	var backupPaths []SchedulerPath
+	activePathWorks := false
 	for pathID, pth := range s.paths {
-		// If this path is potentially failed, do not consider it for sending
-		if pth == nil || pth.potentiallyFailed.Get() {
-			continue pathLoop
-		}
-
 		// XXX Prevent using initial pathID if multiple paths
 		if pathID == protocol.InitialPathID {
-			continue pathLoop
-		}
-
-		// Only use when the first smoothed RTT measurement is available
-		currentRTT := pth.rttStats.SmoothedRTT().Seconds()
-		currentCW := pth.sentPacketHandler.GetCongestionWindow()
-		if currentRTT > 0 {
-			currentTP = float64(currentCW)
+			continue
 		}
-
-		pathStats = append(pathStats, pStat{pth, currentCW, currentRTT, currentTP})
-		if currentTP != 0 && higherTP != 0 && maxPath != nil && currentTP < higherTP {
-			continue pathLoop
+		// Paths are only used once the peer proved that it is reachable on them
+		if !pth.validated.Get() {
+			continue
 		}
-
-		// Update
-		higherTP = currentTP
-		maxPath = pth
-		maxRTT = currentRTT
-	}
-
-	//To reduce large delays, retransmit redundantly on all free paths
-	if hasRetransmission && hasStreamRetransmission {
-		for _, pStat := range pathStats {
-			if pStat.path.SendingAllowed() {
-				sch.redundantPaths = append(sch.redundantPaths, pStat.path)
-			}
+		if pth.isBackup() {
+			backupPaths = append(backupPaths, pth)
+			continue
 		}
-		// Return any free to send path
-		if sch.redundantPaths != nil && len(sch.redundantPaths) > 0 {
-			return sch.redundantPaths[0]
+		if pth.open.Get() && !pth.potentiallyFailed.Get() {
+			activePathWorks = true
 		}
-		return nil
+		ctx.Paths = append(ctx.Paths, pth)
 	}
-
-	// Sanity check
-	if maxPath == nil {
-		return nil
-	}
-
-	sch.pathLogMapSync.RLock()
-	sch.bestPathSelection[maxPath.pathID]++
-	sch.pathLogMapSync.RUnlock()
-
-	// Utilize capacity of best path
-	if maxPath.sentPacketHandler.CongestionFree() && maxPath.sentPacketHandler.OvershootFree(len(pathStats)) {
-		return maxPath
-	}
-
-	// Best path fully utilized, maybe transmit on another path
-	sch.cwBlocks++
-	if len(pathStats) > 1 {
-		// Sort paths descending based on throughput
-		sort.SliceStable(pathStats, func(i, j int) bool {
-			return pathStats[i].Throughput > pathStats[j].Throughput
-		})
-		// Exclude maxPath
-		pathStats = pathStats[1:]
-
-		// Send on path with next highest throughput
-		var lowerRTTpath *path
-		for _, pStat := range pathStats {
-			if pStat.path.sentPacketHandler.CongestionFree() {
-				sch.redundantPaths = append(sch.redundantPaths, pStat.path)
-				if pStat.RTT < maxRTT {
-					// Sending packet on lower RTT path with lower throughput than maxPath
-					if lowerRTTpath == nil {
-						lowerRTTpath = pStat.path
-						sch.lowerRTTSchedules++
-					}
-				} else {
-					// Replicate next packet on path, which otherwise idles.
-					// Happens on performance domination (maxPath has lower RTT & higher throughput)
-					sch.redundantPaths = append(sch.redundantPaths, pStat.path)
-				}
-			}
-		}
-
-		return lowerRTTpath
+	if !activePathWorks {
+		ctx.Paths = append(ctx.Paths, backupPaths...)
 	}
-
-	return nil
+	sch.honorPathPreferences(s, ctx)
+	return ctx
 }
 
-// Select all paths for retransmission, or paths with space for new transmissions.
-func (sch *scheduler) selectRedundantPaths(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-
-	var selectedPath *path
-pathLoop:
-	for pathID, pth := range s.paths {
-		// Don't block path usage if we retransmit, even on another path
-		// DERA: Only consider paths, that have space in their cwnd for 'new' packets.
-		//		 Or consider all valid paths for outstanding retransmissions.
-		if !hasRetransmission && !pth.SendingAllowed() {
-			continue pathLoop
-		}
-
-		// If this path is potentially failed, do no consider it for sending
-		if pth.potentiallyFailed.Get() {
-			continue pathLoop
-		}
-
-		// XXX Prevent using initial pathID if multiple paths
-		if pathID == protocol.InitialPathID {
-			continue pathLoop
-		}
-
-		if selectedPath == nil {
-			selectedPath = pth
-		} else {
-			sch.redundantPaths = append(sch.redundantPaths, pth)
-		}
-	}
-
-	return selectedPath
-}
-
-// Exclude initial path from selection and discovery of new paths.
-func (sch *scheduler) selectInitialPath(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-
-	// XXX Avoid using PathID 0 if there is more than 1 path
+// honorPathPreferences tells the streams which paths can carry their data, and only hands the
+// paths on which stream data may be sent to the algorithm. Lock of s.paths must be held
+func (sch *scheduler) honorPathPreferences(s *session, ctx *ScheduleContext) {
 	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		return s.paths[protocol.InitialPathID]
-	}
-
-	// FIXME Only works at the beginning... Cope with new paths during the connection
-	if hasRetransmission && hasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
-		// Is there any other path with a lower number of packet sent?
-		currentQuota := sch.quotas[fromPth.pathID]
-		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
-				continue
-			}
-			// The congestion window was checked when duplicating the packet
-			if sch.quotas[pathID] < currentQuota {
-				return pth
+		s.streamFramer.SetUsablePaths([]protocol.PathID{protocol.InitialPathID})
+		return
+	}
+	pathIDs := make([]protocol.PathID, 0, len(ctx.Paths))
+	usablePaths := make([]protocol.PathID, 0, len(ctx.Paths))
+	for _, pth := range ctx.Paths {
+		pathIDs = append(pathIDs, pth.PathID())
+		if p := s.paths[pth.PathID()]; p.open.Get() && !p.potentiallyFailed.Get() {
+			usablePaths = append(usablePaths, p.pathID)
+		}
+	}
+	s.streamFramer.SetUsablePaths(usablePaths)
+
+	withData := s.streamFramer.PathsWithData(pathIDs)
+	// Without any stream data, every path may carry the control frames
+	if len(withData) == 0 {
+		return
+	}
+	pathsWithData := make([]SchedulerPath, 0, len(withData))
+	for _, pth := range ctx.Paths {
+		for _, pathID := range withData {
+			if pth.PathID() == pathID {
+				pathsWithData = append(pathsWithData, pth)
+				break
 			}
 		}
 	}
-
-	return nil
+	ctx.Paths = pathsWithData
 }
 
 // Lock of s.paths must be held
-func (sch *scheduler) selectPath(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
+func (sch *scheduler) selectPath(s *session, ctx *ScheduleContext, fromPth *path) *path {
 
 	// DERA: Reset redundant path selection.
 	sch.redundantPaths = nil
 	// DERA: Initial selection excludes the initial Path 0 and deploys new paths.
-	pth := sch.selectInitialPath(s, hasRetransmission, hasStreamRetransmission, fromPth)
+	pth := sch.selectInitialPath(s, ctx, fromPth)
 	if pth != nil {
 		return pth
 	}
 
-	// Select the scheduling algorithm based on preset
-	switch SchedulerAlgorithm {
-	case "lowRTT":
-		// DERA: lowRTT will also select lowest RTT path for retransmissions, even if that path has no space in its cwnd!
-		return sch.selectPathLowLatency(s, hasRetransmission, hasStreamRetransmission, fromPth)
-	case "RR":
-		return sch.selectPathRoundRobin(s, hasRetransmission, hasStreamRetransmission, fromPth)
-	case "oppRedundant":
-		// Select any free-to-send path for sending and all others as redundant paths.
-		return sch.selectRedundantPaths(s, hasRetransmission, hasStreamRetransmission, fromPth)
-	case "utilRepair":
-		// Utilize path with the highest throughput.
-		return sch.selectPathUtilRepair(s, hasRetransmission, hasStreamRetransmission, fromPth)
-	default:
-		// Error invalid scheduling algorithm
-		utils.Debugf("Invalid scheduler algorithm specified!")
+	selectedPath, redundantPaths := sch.algorithm.SelectPath(ctx)
+	if ctx.RedundancyBudgetSpent {
+		redundantPaths = nil
+	}
+	for _, redPth := range redundantPaths {
+		if p, ok := s.paths[redPth.PathID()]; ok {
+			sch.redundantPaths = append(sch.redundantPaths, p)
+		}
+	}
+	if selectedPath == nil {
 		return nil
 	}
+	return s.paths[selectedPath.PathID()]
 }
 
 // Lock of s.paths must be free (in case of log print)
@@ -505,6 +341,7 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 		Length:          protocol.ByteCount(len(packet.raw)),
 		EncryptionLevel: packet.encryptionLevel,
 	}
+	sch.redundancy.OnSent(pkt.GetStreamFrameLength())
 
 	return pkt, true, nil
 }
@@ -532,6 +369,21 @@ func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*w
 			if pthTmp.pathID == protocol.InitialPathID && ackTmp == nil {
 				continue
 			}
+			// Don't send WindowUpdates, or even data, on a path that is not validated yet
+			if !pthTmp.validated.Get() && ackTmp == nil {
+				continue
+			}
+			if ackTmp != nil {
+				// Without validation, only the ACK may be sent and only within the anti-amplification budget,
+				// leaving room for a STOP_WAITING frame
+				limited, err := s.amplificationLimited([]wire.Frame{ackTmp, &wire.StopWaitingFrame{}}, pthTmp)
+				if err != nil {
+					return err
+				}
+				if limited {
+					continue
+				}
+			}
 			swf := pthTmp.GetStopWaitingFrame(false)
 			if swf != nil {
 				s.packer.QueueControlFrame(swf, pthTmp)
@@ -563,12 +415,24 @@ func (sch *scheduler) sendPacket(s *session) error {
 	var pth *path
 
 	// Update leastUnacked value of paths
+	var failedPaths []*path
 	s.pathsLock.RLock()
 	for _, pthTmp := range s.paths {
 		pthTmp.SetLeastUnacked(pthTmp.sentPacketHandler.GetLeastUnacked())
+		if pthTmp.reinject {
+			pthTmp.reinject = false
+			failedPaths = append(failedPaths, pthTmp)
+		}
 	}
 	s.pathsLock.RUnlock()
 
+	// Don't wait for the retransmissions of failing paths
+	for _, failedPth := range failedPaths {
+		if err := sch.reinjectPackets(s, failedPth); err != nil {
+			return err
+		}
+	}
+
 	// get WindowUpdate frames
 	// this call triggers the flow controller to increase the flow control windows, if necessary
 	windowUpdateFrames := s.getWindowUpdateFrames(false)
@@ -586,7 +450,8 @@ func (sch *scheduler) sendPacket(s *session) error {
 
 		// Select the path here
 		s.pathsLock.RLock()
-		pth = sch.selectPath(s, hasRetransmission, hasStreamRetransmission, fromPth)
+		ctx := sch.newScheduleContext(s, hasRetransmission, hasStreamRetransmission, fromPth)
+		pth = sch.selectPath(s, ctx, fromPth)
 		s.pathsLock.RUnlock()
 
 		// Update latest scheduler decision
@@ -600,6 +465,7 @@ func (sch *scheduler) sendPacket(s *session) error {
 			windowUpdateFrames := s.getWindowUpdateFrames(false)
 			return sch.ackRemainingPaths(s, windowUpdateFrames)
 		}
+		sch.traceSelectedPath(s, pth)
 
 		// If we have an handshake packet retransmission, do it directly
 		if hasRetransmission && retransmitHandshakePacket != nil {
@@ -617,7 +483,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 		// XXX Some automatic ACK generation should be done someway
 		var ack *wire.AckFrame
 
-		ack = pth.GetAckFrame()
+		if sch.algorithm.PiggybackAck(pth) {
+			ack = pth.GetAckFrame()
+		}
 		if ack != nil {
 			s.packer.QueueControlFrame(ack, pth)
 		}
@@ -638,6 +506,16 @@ func (sch *scheduler) sendPacket(s *session) error {
 			s.packer.QueueControlFrame(aaf, pth)
 		}
 
+		// Also add REMOVE_ADDRESS frames, if any
+		for raf := s.streamFramer.PopRemoveAddressFrame(); raf != nil; raf = s.streamFramer.PopRemoveAddressFrame() {
+			s.packer.QueueControlFrame(raf, pth)
+		}
+
+		// Also add PATH_STATUS frames, if any
+		for psf := s.streamFramer.PopPathStatusFrame(); psf != nil; psf = s.streamFramer.PopPathStatusFrame() {
+			s.packer.QueueControlFrame(psf, pth)
+		}
+
 		// Also add PATHS frames, if any
 		for pf := s.streamFramer.PopPathsFrame(); pf != nil; pf = s.streamFramer.PopPathsFrame() {
 			s.packer.QueueControlFrame(pf, pth)
@@ -653,31 +531,15 @@ func (sch *scheduler) sendPacket(s *session) error {
 			return sch.ackRemainingPaths(s, windowUpdateFrames)
 		}
 
-		// Duplicate traffic when it was sent on an unknown performing path
-		// FIXME adapt for new paths coming during the connection
-		// DERA: redundant schedulers will duplicate packet anyways.
-		if pth.rttStats.SmoothedRTT() == 0 && !RedundantSending {
-			currentQuota := sch.quotas[pth.pathID]
-			// Was the packet duplicated on all potential paths?
-		duplicateLoop:
-			for pathID, tmpPth := range s.paths {
-				if pathID == protocol.InitialPathID || pathID == pth.pathID {
-					continue
-				}
-				if sch.quotas[pathID] < currentQuota && tmpPth.sentPacketHandler.SendingAllowed() {
-					// Duplicate it
-					pth.sentPacketHandler.DuplicatePacket(pkt)
-					break duplicateLoop
-				}
+		// Redundant retransmissions on the paths selected by the algorithm,
+		// otherwise let the algorithm decide if another path should carry a copy of the packet
+		if len(sch.redundantPaths) > 0 {
+			if err := sch.redSendPacket(s, pth, pkt, windowUpdateFrames); err != nil {
+				return err
 			}
+		} else if sch.algorithm.ShouldDuplicate(ctx, pth) {
+			pth.sentPacketHandler.DuplicatePacket(pkt)
 		}
-		// Redundant retranmissions
-		// if RedundantSending {
-		// 	err := sch.redSendPacket(s, pth, pkt, windowUpdateFrames)
-		// 	if err != nil {
-		// 		return err
-		// 	}
-		// }
 
 		// And try pinging on potentially failed paths
 		if fromPth != nil && fromPth.potentiallyFailed.Get() {
@@ -709,9 +571,11 @@ func (sch *scheduler) redSendPacket(s *session, pth *path, pkt *ackhandler.Packe
 		encLevel, sealer := s.packer.cryptoSetup.GetSealer()
 		publicHeader := s.packer.getPublicHeader(encLevel, redPth)
 
-		// Was the packet already duplicated on this path?
-		if _, exists := sch.dupPackets[dupID{pth.pathID, pkt.PacketNumber}]; exists {
-			continue
+		// Stop duplicating once the redundancy budget is spent
+		streamBytes := pkt.GetStreamFrameLength()
+		if !sch.redundancy.Allows(time.Now(), streamBytes) {
+			sch.redundancyBudgetSkips++
+			break
 		}
 
 		raw, err := s.packer.writeAndSealPacket(publicHeader, redundantFrames, sealer, redPth)
@@ -732,35 +596,150 @@ func (sch *scheduler) redSendPacket(s *session, pth *path, pkt *ackhandler.Packe
 			continue
 		}
 
-		// Add mapping for duplicated packet
-		sch.dupPackets[dupID{pth.pathID, pkt.PacketNumber}] = dupID{redPth.pathID, dupPkt.number}
-		// Extend mapping to bidirection, if original packet is droppable
-		if pkt.IsDupDroppable() {
-			sch.dupPackets[dupID{redPth.pathID, dupPkt.number}] = dupID{pth.pathID, pkt.PacketNumber}
-		}
+		// Add mapping for duplicated packet, bidirectional if the original packet is droppable
+		sch.addCopy(dupID{pth.pathID, pkt.PacketNumber}, dupID{redPth.pathID, dupPkt.number}, pkt.IsDupDroppable())
 		sch.duplicatedPackets++
-		sch.duplicatedStreamBytes += pkt.GetStreamFrameLength()
+		sch.duplicatedStreamBytes += streamBytes
+		atomic.AddUint64(&redPth.duplicatedStreamBytes, uint64(streamBytes))
+		sch.redundancy.OnDuplicated(time.Now(), streamBytes)
 	}
 
 	return nil
 }
 
+// reinjectPackets copies the stream frames in flight on a failing path onto the healthy path with the
+// lowest RTT, as long as its congestion window allows it. Once either copy is acknowledged,
+// crossAckHandling cancels the other one. The packets a retransmission timeout already queued for
+// retransmission are reinjected as well, their stream frames are then not retransmitted again.
+func (sch *scheduler) reinjectPackets(s *session, failedPth *path) error {
+	s.pathsLock.RLock()
+	var target *path
+	ctx := sch.newScheduleContext(s, false, false, nil)
+	for _, candidate := range ctx.Paths {
+		pth := s.paths[candidate.PathID()]
+		if pth == failedPth || !pth.SendingAllowed() || pth.potentiallyFailed.Get() {
+			continue
+		}
+		if target == nil || pth.rttStats.SmoothedRTT() < target.rttStats.SmoothedRTT() {
+			target = pth
+		}
+	}
+	s.pathsLock.RUnlock()
+	if target == nil {
+		return nil
+	}
+
+	// The retransmission timeout reporting the path as potentially failed queues all its packets in flight
+	packets := append(failedPth.sentPacketHandler.GetQueuedRetransmissions(), failedPth.sentPacketHandler.GetOutstandingPackets()...)
+	for _, pkt := range packets {
+		if !target.SendingAllowed() {
+			break
+		}
+		// Was the packet already reinjected?
+		if _, exists := sch.dupPackets[dupID{failedPth.pathID, pkt.PacketNumber}]; exists {
+			continue
+		}
+		var frames []wire.Frame
+		onlyStreamFrames := true
+		for _, f := range pkt.GetFramesForRetransmission() {
+			if sf, ok := f.(*wire.StreamFrame); ok {
+				frames = append(frames, sf)
+			} else {
+				onlyStreamFrames = false
+			}
+		}
+		if len(frames) == 0 {
+			continue
+		}
+
+		encLevel, sealer := s.packer.cryptoSetup.GetSealer()
+		publicHeader := s.packer.getPublicHeader(encLevel, target)
+		raw, err := s.packer.writeAndSealPacket(publicHeader, frames, sealer, target)
+		if err != nil {
+			// The copy may not fit on the path, leave the packet to the retransmissions of the failing path
+			continue
+		}
+		reinjected := &packedPacket{
+			number:          publicHeader.PacketNumber,
+			raw:             raw,
+			frames:          frames,
+			encryptionLevel: encLevel,
+		}
+		utils.Debugf("Reinjecting packet 0x%x of path %x as packet 0x%x on path %x", pkt.PacketNumber, failedPth.pathID, reinjected.number, target.pathID)
+		if err = s.sendPackedPacket(reinjected, target); err != nil {
+			return err
+		}
+
+		// The original packet can only be dropped if the copy carries all its retransmittable frames
+		sch.addCopy(dupID{failedPth.pathID, pkt.PacketNumber}, dupID{target.pathID, reinjected.number}, onlyStreamFrames)
+		sch.reinjectedPackets++
+	}
+	return nil
+}
+
+// addCopy maps the original packet to its copy. If the original packet may be dropped once
+// the copy is acknowledged, the copy is mapped back to the original packet as well.
+func (sch *scheduler) addCopy(original, dup dupID, droppable bool) {
+	sch.dupPackets[original] = append(sch.dupPackets[original], dup)
+	if droppable {
+		sch.dupPackets[dup] = append(sch.dupPackets[dup], original)
+	}
+}
+
+// unmapCopy removes the mapping from the packet to its copy
+func (sch *scheduler) unmapCopy(packet, dup dupID) {
+	copies := sch.dupPackets[packet]
+	for i, c := range copies {
+		if c == dup {
+			copies = append(copies[:i], copies[i+1:]...)
+			break
+		}
+	}
+	if len(copies) == 0 {
+		delete(sch.dupPackets, packet)
+	} else {
+		sch.dupPackets[packet] = copies
+	}
+}
+
+// forgetCopy returns true if a copy of the packet is still in flight on another path.
+// The mappings are removed, such that the copies are retransmitted if they are lost as well.
+func (sch *scheduler) forgetCopy(pathID protocol.PathID, packetNumber protocol.PacketNumber) bool {
+	dupKey := dupID{pathID, packetNumber}
+	dupEntries, exists := sch.dupPackets[dupKey]
+	if !exists {
+		return false
+	}
+	delete(sch.dupPackets, dupKey)
+	for _, dupEntry := range dupEntries {
+		sch.unmapCopy(dupEntry, dupKey)
+	}
+	return true
+}
+
 // Stop already acknowledged packet duplications from beeing resend.
+// If a copy is acknowledged, the original packet and all its other copies are dropped.
 func (sch *scheduler) crossAckHandling(pathID protocol.PathID, packetNumber protocol.PacketNumber) {
-
 	dupKey := dupID{pathID, packetNumber}
-	if dupEntry, exists := sch.dupPackets[dupKey]; exists {
+	dropped := map[dupID]bool{dupKey: true}
+	pending := sch.dupPackets[dupKey]
+	delete(sch.dupPackets, dupKey)
+	for len(pending) > 0 {
+		dupEntry := pending[0]
+		pending = pending[1:]
+		if dropped[dupEntry] {
+			continue
+		}
+		dropped[dupEntry] = true
 		// Try to remove packet from other paths history
-		removed := (*sch.pathsRef)[dupEntry.PathID].sentPacketHandler.RemovePacketByNumber(dupEntry.PacketNumber)
-		if removed {
-			utils.Debugf("Dropped duplicate packet %d on path %d", packetNumber, pathID)
+		if pth, ok := (*sch.pathsRef)[dupEntry.PathID]; ok && pth.sentPacketHandler.RemovePacketByNumber(dupEntry.PacketNumber) {
+			utils.Debugf("Dropped duplicate packet %d on path %d", dupEntry.PacketNumber, dupEntry.PathID)
 			sch.droppedDuplicatedPackets++
 		}
-		// Remove bidirectional back mapping (delete does NOP if map does not contain key)
+		// Follow the mappings of the dropped packet to the other copies
+		pending = append(pending, sch.dupPackets[dupEntry]...)
 		delete(sch.dupPackets, dupEntry)
 	}
-	// Remove mapping (delete does NOP if map does not contain key)
-	delete(sch.dupPackets, dupKey)
 }
 
 // Periodic sending log routine
@@ -770,22 +749,12 @@ func (sch *scheduler) LogSendings(s *session, ticker *time.Ticker, stopLog chan
 		select {
 		case <-ticker.C:
 			// Received logging tick, perform logging routine
-			if len(sch.logFiles) < len(s.paths) {
-				if sch.logFiles == nil {
-					// Create data structures for logging
-					sch.logFiles = make(map[protocol.PathID]*os.File)
-					sch.lastSentBytes = make(map[protocol.PathID]uint64)
-					// Setup the initial logStart time for reference
-					sch.logStartTS = time.Now().UnixNano()
-					sch.lastLogTS = float64(sch.logStartTS) / 1e6
-				}
-				// Open log files
-				for pathID := range s.paths {
-					if _, exists := sch.logFiles[pathID]; !exists {
-						filename := "P" + strconv.Itoa(int(pathID)) + "_send.log"
-						sch.logFiles[pathID], _ = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-					}
-				}
+			if sch.lastSentBytes == nil {
+				// Create data structures for logging
+				sch.lastSentBytes = make(map[protocol.PathID]uint64)
+				// Setup the initial logStart time for reference
+				sch.logStartTS = time.Now().UnixNano()
+				sch.lastLogTS = float64(sch.logStartTS) / 1e6
 			}
 
 			s.pathsLock.RLock()
@@ -793,8 +762,6 @@ func (sch *scheduler) LogSendings(s *session, ticker *time.Ticker, stopLog chan
 			now := time.Now().UnixNano()
 			elapsed := float64(now)/1e6 - sch.lastLogTS
 			sch.lastLogTS = float64(now) / 1e6
-			// Transform absolute to relative [ms] timestamp string
-			timestring := strconv.FormatFloat(float64(now-sch.logStartTS)/1e6, 'f', -1, 64)
 			sch.allSntBytes = 0
 			for pathID, pth := range s.paths {
 				if pathID == protocol.InitialPathID && len(s.paths) > 1 {
@@ -815,14 +782,15 @@ func (sch *scheduler) LogSendings(s *session, ticker *time.Ticker, stopLog chan
 					pathID, pth.conn.LocalAddr(), pth.conn.RemoteAddr(), sntPkts, sntBytes, sntRetrans, sntLost, rcvPkts, rcvBytes, pth.rttStats.SmoothedRTT())
 				utils.Debugf("Elapsed %f ms, Sent Bytes %d, Send rate %f KBit/s", elapsed, sentDelta, sendRate)
 
-				if LogPayload {
-					logLine := timestring + ";" + strconv.FormatFloat(sendRate, 'g', -1, 64) + ";" +
-						strconv.FormatUint(pth.sentPacketHandler.GetBytesInFlight(), 10) + "\n"
-					sch.logFiles[pathID].WriteString(logLine)
+				if s.metrics != nil {
+					// The timestamps are relative to the start of the logging
+					s.metrics.SendRate(pathID, time.Duration(now-sch.logStartTS), sendRate, protocol.ByteCount(pth.sentPacketHandler.GetBytesInFlight()))
 				}
 			}
 			s.pathsLock.RUnlock()
-			s.scheduler.logRedundantStats(s)
+			if s.metrics != nil {
+				s.metrics.UpdatedSchedulerStats(s.scheduler.redundantStats(s))
+			}
 		case <-stopLog:
 			// Stop logging
 			ticker.Stop()
@@ -831,13 +799,8 @@ func (sch *scheduler) LogSendings(s *session, ticker *time.Ticker, stopLog chan
 	}
 }
 
-// Log statistics on duplicated Packets to file, when a redundant scheduler is used
-func (sch *scheduler) logRedundantStats(s *session) {
-
-	filename := "Server_scheduler_stats.json"
-	os.Remove(filename)
-	logStatsFile, _ := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
-
+// redundantStats collects the statistics on duplicated Packets, when a redundant scheduler is used
+func (sch *scheduler) redundantStats(s *session) SchedulerStats {
 	dupQuota := 0.0
 	if sch.allSntBytes != 0 {
 		dupQuota = float64(sch.duplicatedStreamBytes) / float64(sch.allSntBytes) * 100.0
@@ -850,36 +813,45 @@ func (sch *scheduler) logRedundantStats(s *session) {
 	}
 	utils.Debugf("Total redundant droppings %d/%d (%f %%)", sch.droppedDuplicatedPackets, sch.duplicatedPackets, dropQuota)
 
-	sch.pathLogMapSync.RLock()
-	pathStats := "["
+	// The utilRepair scheduler counts its decisions
+	var cwBlocks, lowerRTTSchedules uint64
+	utilRepair, isUtilRepair := sch.algorithm.(*utilRepairScheduler)
+	if isUtilRepair {
+		cwBlocks = utilRepair.cwBlocks
+		lowerRTTSchedules = utilRepair.lowerRTTSchedules
+	}
+
+	stats := SchedulerStats{
+		TotalSentPackets:         s.allSntPackets,
+		DuplicatedPackets:        sch.duplicatedPackets,
+		DuplicatedDroppedPackets: sch.droppedDuplicatedPackets,
+		DuplicatedPacketDropRate: dropQuota,
+		TotalStreamBytes:         sch.allSntBytes,
+		DuplicatedStreamBytes:    sch.duplicatedStreamBytes,
+		DuplicateStreamRate:      dupQuota,
+		BlockedCWHighestTPPath:   cwBlocks,
+		LowerRTTSchedules:        lowerRTTSchedules,
+		PathSwitches:             sch.pathSwitches,
+		ReinjectedPackets:        sch.reinjectedPackets,
+		RedundancyBudgetSkips:    sch.redundancyBudgetSkips,
+	}
+	s.pathsLock.RLock()
 	for pathID, pth := range s.paths {
 		packets, retransmissions, losses, sentStreamFrameBytes := pth.sentPacketHandler.GetStatistics()
-		pathStats += " { \"pathID\": " + strconv.FormatUint(uint64(pathID), 10) +
-			", \"pathIP\" : \"" + pth.conn.LocalAddr().String() + "\"" +
-			", \"sendPackets\" : " + strconv.FormatUint(packets, 10) +
-			", \"retransmissions\" : " + strconv.FormatUint(retransmissions, 10) +
-			", \"losses\" : " + strconv.FormatUint(losses, 10) +
-			", \"sentStreamFrameBytes\" : " + strconv.FormatUint(sentStreamFrameBytes, 10) +
-			", \"selectedAsBestPath\" : " + strconv.FormatUint(sch.bestPathSelection[pathID], 10) +
-			"},"
-	}
-	pathStats = pathStats[0 : len(pathStats)-1]
-	pathStats += "]"
-	sch.pathLogMapSync.RUnlock()
-
-	logStatsFile.WriteString(
-		"{ \"totalSentPackets\" : " + strconv.FormatUint(s.allSntPackets, 10) +
-			", \"duplicatedPackets\" : " + strconv.FormatUint(sch.duplicatedPackets, 10) +
-			", \"duplicatedDroppedPackets\" : " + strconv.FormatUint(sch.droppedDuplicatedPackets, 10) +
-			", \"duplicatedPacketDropRate\" : " + strconv.FormatFloat(dropQuota, 'g', -1, 64) +
-			", \"totalStreamBytes\" : " + strconv.FormatUint(sch.allSntBytes, 10) +
-			", \"duplicatedStreamBytes\" : " + strconv.FormatUint(sch.duplicatedStreamBytes, 10) +
-			", \"duplicateStreamRate\" : " + strconv.FormatFloat(dupQuota, 'g', -1, 64) +
-			", \"blockedCWhighestTPPath\" : " + strconv.FormatUint(sch.cwBlocks, 10) +
-			", \"lowerRTTSchedules\" : " + strconv.FormatUint(sch.lowerRTTSchedules, 10) +
-			", \"pathSwitches\" : " + strconv.FormatUint(sch.pathSwitches, 10) +
-			", \"pathStats\" : " + pathStats +
-			"}")
-
-	logStatsFile.Close()
+		var bestPathSelections uint64
+		if isUtilRepair {
+			bestPathSelections = utilRepair.bestPathSelections(pathID)
+		}
+		stats.PathStats = append(stats.PathStats, SchedulerPathStats{
+			PathID:               pathID,
+			PathIP:               pth.conn.LocalAddr().String(),
+			SentPackets:          packets,
+			Retransmissions:      retransmissions,
+			Losses:               losses,
+			SentStreamFrameBytes: sentStreamFrameBytes,
+			SelectedAsBestPath:   bestPathSelections,
+		})
+	}
+	s.pathsLock.RUnlock()
+	return stats
 }
@@ -0,0 +1,769 @@
+package quic
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/mocks/mocks_fc"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockSchedulerPath struct {
+	pathID            protocol.PathID
+	sendingAllowed    bool
+	congestionFree    bool
+	overshootFree     bool
+	potentiallyFailed bool
+	smoothedRTT       time.Duration
+	congestionWindow  uint64
+	quota             uint
+}
+
+var _ SchedulerPath = &mockSchedulerPath{}
+
+func (p *mockSchedulerPath) PathID() protocol.PathID    { return p.pathID }
+func (p *mockSchedulerPath) SendingAllowed() bool       { return p.sendingAllowed }
+func (p *mockSchedulerPath) CongestionFree() bool       { return p.congestionFree }
+func (p *mockSchedulerPath) OvershootFree(int) bool     { return p.overshootFree }
+func (p *mockSchedulerPath) PotentiallyFailed() bool    { return p.potentiallyFailed }
+func (p *mockSchedulerPath) SmoothedRTT() time.Duration { return p.smoothedRTT }
+func (p *mockSchedulerPath) MinRTT() time.Duration      { return p.smoothedRTT }
+func (p *mockSchedulerPath) CongestionWindow() uint64   { return p.congestionWindow }
+func (p *mockSchedulerPath) BytesInFlight() uint64      { return 0 }
+func (p *mockSchedulerPath) Quota() uint                { return p.quota }
+
+type mockScheduler struct {
+	unprobedPathDuplicator
+}
+
+func (*mockScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	return nil, nil
+}
+
+var _ = Describe("Scheduler", func() {
+	var path1, path2, path3 *mockSchedulerPath
+
+	BeforeEach(func() {
+		path1 = &mockSchedulerPath{pathID: 1, sendingAllowed: true, congestionFree: true, overshootFree: true}
+		path2 = &mockSchedulerPath{pathID: 2, sendingAllowed: true, congestionFree: true, overshootFree: true}
+		path3 = &mockSchedulerPath{pathID: 3, sendingAllowed: true, congestionFree: true, overshootFree: true}
+	})
+
+	newContext := func(paths ...*mockSchedulerPath) *ScheduleContext {
+		ctx := &ScheduleContext{}
+		for _, pth := range paths {
+			ctx.Paths = append(ctx.Paths, pth)
+		}
+		return ctx
+	}
+
+	Context("registering schedulers", func() {
+		It("creates the built-in schedulers", func() {
+			for _, name := range []string{"lowRTT", "RR", "oppRedundant", "utilRepair", "blest", "ecf"} {
+				sch, err := newScheduler(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sch).ToNot(BeNil())
+			}
+		})
+
+		It("uses lowRTT by default", func() {
+			sch, err := newScheduler("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sch).To(BeAssignableToTypeOf(&lowRTTScheduler{}))
+		})
+
+		It("falls back to the scheduler set with SetSchedulerAlgorithm", func() {
+			SetSchedulerAlgorithm("RR")
+			defer SetSchedulerAlgorithm("")
+			sch, err := newScheduler("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sch).To(BeAssignableToTypeOf(&roundRobinScheduler{}))
+		})
+
+		It("errors for unknown schedulers", func() {
+			_, err := newScheduler("foobar")
+			Expect(err).To(MatchError("unknown scheduler: foobar"))
+			Expect(checkScheduler("foobar")).To(MatchError("unknown scheduler: foobar"))
+		})
+
+		It("registers a new scheduler", func() {
+			RegisterScheduler("mock", func() Scheduler { return &mockScheduler{} })
+			defer func() {
+				schedulersMutex.Lock()
+				delete(schedulers, "mock")
+				schedulersMutex.Unlock()
+			}()
+			Expect(checkScheduler("mock")).To(Succeed())
+			sch, err := newScheduler("mock")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sch).To(BeAssignableToTypeOf(&mockScheduler{}))
+		})
+
+		It("panics if a scheduler is registered twice", func() {
+			Expect(func() {
+				RegisterScheduler("lowRTT", func() Scheduler { return &mockScheduler{} })
+			}).To(Panic())
+		})
+	})
+
+	Context("lowRTT", func() {
+		var sch *lowRTTScheduler
+
+		BeforeEach(func() {
+			sch = &lowRTTScheduler{}
+		})
+
+		It("selects the path with the lowest RTT", func() {
+			path1.smoothedRTT = 30 * time.Millisecond
+			path2.smoothedRTT = 10 * time.Millisecond
+			path3.smoothedRTT = 20 * time.Millisecond
+			pth, redundantPaths := sch.SelectPath(newContext(path1, path2, path3))
+			Expect(pth).To(Equal(path2))
+			Expect(redundantPaths).To(BeEmpty())
+		})
+
+		It("doesn't select paths that are blocked or potentially failed", func() {
+			path1.smoothedRTT = 30 * time.Millisecond
+			path2.smoothedRTT = 10 * time.Millisecond
+			path2.sendingAllowed = false
+			path3.smoothedRTT = 20 * time.Millisecond
+			path3.potentiallyFailed = true
+			pth, _ := sch.SelectPath(newContext(path1, path2, path3))
+			Expect(pth).To(Equal(path1))
+		})
+
+		It("prefers the unprobed path with the lowest quota", func() {
+			path1.quota = 5
+			path2.quota = 2
+			pth, _ := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("returns nil if no path may send", func() {
+			path1.sendingAllowed = false
+			pth, _ := sch.SelectPath(newContext(path1))
+			Expect(pth).To(BeNil())
+		})
+	})
+
+	Context("RR", func() {
+		var sch *roundRobinScheduler
+
+		BeforeEach(func() {
+			sch = &roundRobinScheduler{}
+		})
+
+		It("selects the path with the lowest quota", func() {
+			path1.quota = 3
+			path2.quota = 1
+			path3.quota = 2
+			pth, _ := sch.SelectPath(newContext(path1, path2, path3))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("selects blocked paths for retransmissions", func() {
+			path1.quota = 3
+			path2.quota = 1
+			path2.sendingAllowed = false
+			ctx := newContext(path1, path2)
+			pth, _ := sch.SelectPath(ctx)
+			Expect(pth).To(Equal(path1))
+			ctx.HasRetransmission = true
+			pth, _ = sch.SelectPath(ctx)
+			Expect(pth).To(Equal(path2))
+		})
+	})
+
+	Context("oppRedundant", func() {
+		It("selects all other paths as redundant paths", func() {
+			path2.potentiallyFailed = true
+			sch := &oppRedundantScheduler{}
+			pth, redundantPaths := sch.SelectPath(newContext(path1, path2, path3))
+			Expect(pth).To(Equal(path1))
+			Expect(redundantPaths).To(Equal([]SchedulerPath{path3}))
+		})
+	})
+
+	Context("utilRepair", func() {
+		var sch *utilRepairScheduler
+
+		BeforeEach(func() {
+			sch = newUtilRepairScheduler()
+			path1.smoothedRTT = 10 * time.Millisecond
+			path1.congestionWindow = 10000
+			path2.smoothedRTT = 50 * time.Millisecond
+			path2.congestionWindow = 20000
+		})
+
+		It("selects the path with the highest throughput", func() {
+			pth, _ := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(Equal(path2))
+			Expect(sch.bestPathSelections(2)).To(Equal(uint64(1)))
+		})
+
+		It("sends on a lower RTT path if the best path is blocked", func() {
+			path2.congestionFree = false
+			pth, redundantPaths := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(Equal(path1))
+			Expect(redundantPaths).To(Equal([]SchedulerPath{path1}))
+			Expect(sch.cwBlocks).To(Equal(uint64(1)))
+			Expect(sch.lowerRTTSchedules).To(Equal(uint64(1)))
+		})
+
+		It("retransmits on all free paths", func() {
+			ctx := newContext(path1, path2)
+			ctx.HasRetransmission = true
+			ctx.HasStreamRetransmission = true
+			pth, redundantPaths := sch.SelectPath(ctx)
+			Expect(pth).ToNot(BeNil())
+			Expect(redundantPaths).To(ConsistOf(path1, path2))
+		})
+	})
+
+	Context("BLEST", func() {
+		var sch *blestScheduler
+
+		BeforeEach(func() {
+			sch = &blestScheduler{}
+			path1.smoothedRTT = 10 * time.Millisecond
+			path1.congestionWindow = 10 * uint64(protocol.DefaultTCPMSS)
+			path2.smoothedRTT = 40 * time.Millisecond
+			path2.congestionWindow = 10 * uint64(protocol.DefaultTCPMSS)
+		})
+
+		It("sends on the fast path while it may send", func() {
+			pth, redundantPaths := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(Equal(path1))
+			Expect(redundantPaths).To(BeEmpty())
+		})
+
+		It("uses the slow path if the flow control window is large enough", func() {
+			path1.sendingAllowed = false
+			ctx := newContext(path1, path2)
+			// The fast path sends 4 * (10 + 1.5) packets during one RTT of the slow path
+			ctx.ConnectionSendWindow = 47 * uint64(protocol.DefaultTCPMSS)
+			pth, _ := sch.SelectPath(ctx)
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("waits for the fast path if the slow path would block the flow control window", func() {
+			path1.sendingAllowed = false
+			ctx := newContext(path1, path2)
+			ctx.ConnectionSendWindow = 45 * uint64(protocol.DefaultTCPMSS)
+			pth, _ := sch.SelectPath(ctx)
+			Expect(pth).To(BeNil())
+			Expect(sch.slowPathSkips).To(Equal(uint64(1)))
+		})
+
+		It("probes paths without RTT samples", func() {
+			path1.sendingAllowed = false
+			path2.smoothedRTT = 0
+			pth, _ := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("doesn't select potentially failed paths", func() {
+			path1.potentiallyFailed = true
+			path2.sendingAllowed = false
+			pth, _ := sch.SelectPath(newContext(path1, path2))
+			Expect(pth).To(BeNil())
+		})
+	})
+
+	Context("ECF", func() {
+		var sch *ecfScheduler
+
+		newECFContext := func(queuedPackets int) *ScheduleContext {
+			ctx := newContext(path1, path2)
+			ctx.QueuedBytes = uint64(queuedPackets) * uint64(protocol.DefaultTCPMSS)
+			return ctx
+		}
+
+		BeforeEach(func() {
+			sch = &ecfScheduler{}
+			path1.smoothedRTT = 10 * time.Millisecond
+			path1.congestionWindow = 10 * uint64(protocol.DefaultTCPMSS)
+			path2.smoothedRTT = 100 * time.Millisecond
+			path2.congestionWindow = 10 * uint64(protocol.DefaultTCPMSS)
+		})
+
+		It("sends on the fast path while it may send", func() {
+			pth, redundantPaths := sch.SelectPath(newECFContext(1000))
+			Expect(pth).To(Equal(path1))
+			Expect(redundantPaths).To(BeEmpty())
+		})
+
+		It("waits for the fast path if it completes the queued data earlier", func() {
+			path1.sendingAllowed = false
+			// The fast path needs 3 RTTs (30ms), the slow path one RTT (100ms)
+			pth, _ := sch.SelectPath(newECFContext(20))
+			Expect(pth).To(BeNil())
+			Expect(sch.waiting).To(BeTrue())
+			Expect(sch.fastPathWaits).To(Equal(uint64(1)))
+		})
+
+		It("uses the slow path if the fast path would complete the queued data later", func() {
+			path1.sendingAllowed = false
+			// The fast path needs 21 RTTs (210ms)
+			pth, _ := sch.SelectPath(newECFContext(200))
+			Expect(pth).To(Equal(path2))
+			Expect(sch.waiting).To(BeFalse())
+		})
+
+		It("uses the slow path if it sends the queued data within two RTTs of the fast path", func() {
+			path1.sendingAllowed = false
+			// The slow path needs a tenth of its RTT (10ms) for a single packet
+			pth, _ := sch.SelectPath(newECFContext(1))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("keeps waiting for the fast path with some hysteresis", func() {
+			path1.sendingAllowed = false
+			pth, _ := sch.SelectPath(newECFContext(20))
+			Expect(pth).To(BeNil())
+			// The fast path needs 11 RTTs (110ms), but the scheduler is already waiting for it
+			pth, _ = sch.SelectPath(newECFContext(100))
+			Expect(pth).To(BeNil())
+			Expect(sch.fastPathWaits).To(Equal(uint64(2)))
+			// Without waiting, the slow path is used
+			pth, _ = (&ecfScheduler{}).SelectPath(newECFContext(100))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("probes paths without RTT samples", func() {
+			path1.sendingAllowed = false
+			path2.smoothedRTT = 0
+			pth, _ := sch.SelectPath(newECFContext(20))
+			Expect(pth).To(Equal(path2))
+		})
+
+		It("doesn't select potentially failed paths", func() {
+			path1.potentiallyFailed = true
+			path2.sendingAllowed = false
+			pth, _ := sch.SelectPath(newECFContext(20))
+			Expect(pth).To(BeNil())
+		})
+	})
+
+	Context("duplicating packets", func() {
+		var sch *lowRTTScheduler
+
+		BeforeEach(func() {
+			sch = &lowRTTScheduler{}
+		})
+
+		It("duplicates packets sent on unprobed paths", func() {
+			path1.quota = 1
+			Expect(sch.ShouldDuplicate(newContext(path1, path2), path1)).To(BeTrue())
+		})
+
+		It("doesn't duplicate packets sent on probed paths", func() {
+			path1.quota = 1
+			path1.smoothedRTT = 10 * time.Millisecond
+			Expect(sch.ShouldDuplicate(newContext(path1, path2), path1)).To(BeFalse())
+		})
+
+		It("doesn't duplicate packets if no other path may send", func() {
+			path1.quota = 1
+			path2.sendingAllowed = false
+			Expect(sch.ShouldDuplicate(newContext(path1, path2), path1)).To(BeFalse())
+		})
+
+		It("piggybacks ACKs", func() {
+			Expect(sch.PiggybackAck(path1)).To(BeTrue())
+		})
+	})
+
+	Context("backup paths", func() {
+		var (
+			sess    *session
+			sch     *scheduler
+			active  *path
+			backup  *path
+			pathIDs = func(ctx *ScheduleContext) []protocol.PathID {
+				var ids []protocol.PathID
+				for _, pth := range ctx.Paths {
+					ids = append(ids, pth.PathID())
+				}
+				return ids
+			}
+		)
+
+		BeforeEach(func() {
+			sess = &session{
+				paths:        make(map[protocol.PathID]*path),
+				streamFramer: newStreamFramer(newStreamsMap(nil, protocol.PerspectiveClient, nil), nil),
+			}
+			sch = &scheduler{}
+			sch.setup()
+			sess.paths[protocol.InitialPathID] = &path{pathID: protocol.InitialPathID}
+			active = &path{pathID: 1}
+			active.open.Set(true)
+			active.validated.Set(true)
+			backup = &path{pathID: 3}
+			backup.open.Set(true)
+			backup.validated.Set(true)
+			backup.localBackup.Set(true)
+			sess.paths[1] = active
+			sess.paths[3] = backup
+		})
+
+		It("doesn't use backup paths while an active path works", func() {
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1)))
+		})
+
+		It("uses backup paths when every active path is potentially failed", func() {
+			active.potentiallyFailed.Set(true)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1), protocol.PathID(3)))
+		})
+
+		It("uses backup paths when every active path is closed", func() {
+			active.open.Set(false)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1), protocol.PathID(3)))
+		})
+
+		It("honors the backup priority announced by the peer", func() {
+			backup.localBackup.Set(false)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1), protocol.PathID(3)))
+			backup.remoteBackup.Set(true)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1)))
+		})
+
+		It("doesn't use paths that are not validated", func() {
+			active.validated.Set(false)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(3)))
+			backup.validated.Set(false)
+			Expect(sch.newScheduleContext(sess, false, false, nil).Paths).To(BeEmpty())
+		})
+		It("doesn't retransmit on the initial path beyond the anti-amplification budget", func() {
+			initialPth := sess.paths[protocol.InitialPathID]
+			delete(sess.paths, 1)
+			delete(sess.paths, 3)
+			ctx := &ScheduleContext{HasRetransmission: true}
+			Expect(sch.selectInitialPath(sess, ctx, nil)).To(Equal(initialPth))
+			initialPth.antiAmplification = true
+			Expect(sch.selectInitialPath(sess, ctx, nil)).To(BeNil())
+			initialPth.bytesReceived = protocol.MaxPacketSize
+			Expect(sch.selectInitialPath(sess, ctx, nil)).To(Equal(initialPth))
+		})
+	})
+
+	Context("path preferences", func() {
+		var (
+			sess    *session
+			sch     *scheduler
+			str     *stream
+			pathIDs = func(ctx *ScheduleContext) []protocol.PathID {
+				var ids []protocol.PathID
+				for _, pth := range ctx.Paths {
+					ids = append(ids, pth.PathID())
+				}
+				return ids
+			}
+		)
+
+		BeforeEach(func() {
+			sess = &session{paths: make(map[protocol.PathID]*path)}
+			sch = &scheduler{}
+			sch.setup()
+			sess.paths[protocol.InitialPathID] = &path{pathID: protocol.InitialPathID}
+			for _, pathID := range []protocol.PathID{1, 3} {
+				pth := &path{pathID: pathID}
+				pth.open.Set(true)
+				pth.validated.Set(true)
+				sess.paths[pathID] = pth
+			}
+			streamsMap := newStreamsMap(nil, protocol.PerspectiveClient, nil)
+			str = &stream{streamID: 5, onData: func() {}}
+			streamsMap.putStream(str)
+			sess.streamFramer = newStreamFramer(streamsMap, nil)
+		})
+
+		It("only hands the paths that may carry stream data to the algorithm", func() {
+			str.dataForWriting = []byte("foobar")
+			str.SetPathPreference([]PathID{3}, false)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(3)))
+		})
+
+		It("falls back to the other paths if the preferred path failed", func() {
+			str.dataForWriting = []byte("foobar")
+			str.SetPathPreference([]PathID{3}, false)
+			sess.paths[3].potentiallyFailed.Set(true)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1), protocol.PathID(3)))
+		})
+
+		It("hands every path to the algorithm without stream data", func() {
+			str.SetPathPreference([]PathID{3}, false)
+			Expect(pathIDs(sch.newScheduleContext(sess, false, false, nil))).To(ConsistOf(protocol.PathID(1), protocol.PathID(3)))
+		})
+	})
+
+	Context("reinjection", func() {
+		var (
+			sess    *session
+			sch     *scheduler
+			failed  *path
+			healthy *path
+			newPath = func(pathID protocol.PathID) *path {
+				pth := &path{
+					pathID: pathID,
+					sess:   sess,
+					conn:   &conn{pconn: &mockPacketConn{}, currentAddr: &net.UDPAddr{}},
+				}
+				pth.setup(nil)
+				pth.validated.Set(true)
+				sess.paths[pathID] = pth
+				return pth
+			}
+		)
+
+		BeforeEach(func() {
+			sch = &scheduler{}
+			sch.setup()
+			sess = &session{
+				version:      protocol.VersionWhatever,
+				perspective:  protocol.PerspectiveClient,
+				paths:        make(map[protocol.PathID]*path),
+				config:       &Config{},
+				scheduler:    sch,
+				streamFramer: newStreamFramer(newStreamsMap(nil, protocol.PerspectiveClient, nil), nil),
+			}
+			sch.pathsRef = &sess.paths
+			sess.packer = &packetPacker{
+				cryptoSetup:          &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure},
+				connectionParameters: handshake.NewConnectionParamatersManager(protocol.PerspectiveClient, protocol.VersionWhatever, 0, 0, 0),
+				connectionID:         0x1337,
+				perspective:          protocol.PerspectiveClient,
+				version:              protocol.VersionWhatever,
+			}
+			failed = newPath(1)
+			healthy = newPath(3)
+			err := failed.sentPacketHandler.SentPacket(&ackhandler.Packet{
+				PacketNumber:    1,
+				Frames:          []wire.Frame{&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}},
+				Length:          100,
+				EncryptionLevel: protocol.EncryptionForwardSecure,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			for _, pth := range sess.paths {
+				pth.closeChan <- nil
+			}
+		})
+
+		It("copies the stream frames in flight onto a healthy path", func() {
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			copies := healthy.sentPacketHandler.GetOutstandingPackets()
+			Expect(copies).To(HaveLen(1))
+			Expect(copies[0].Frames).To(Equal([]wire.Frame{&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}}))
+			Expect(sch.dupPackets).To(HaveKeyWithValue(dupID{1, 1}, []dupID{{3, copies[0].PacketNumber}}))
+			Expect(sch.reinjectedPackets).To(Equal(uint64(1)))
+			// Packets are reinjected only once
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			Expect(sch.reinjectedPackets).To(Equal(uint64(1)))
+		})
+
+		It("reinjects the packets queued by the retransmission timeout of a failing path", func() {
+			for pn := protocol.PacketNumber(2); pn <= 3; pn++ {
+				Expect(failed.sentPacketHandler.SentPacket(&ackhandler.Packet{
+					PacketNumber:    pn,
+					Frames:          []wire.Frame{&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}},
+					Length:          100,
+					EncryptionLevel: protocol.EncryptionForwardSecure,
+				})).To(Succeed())
+			}
+			// Two tail loss probes, followed by the RTO finding no activity on the path
+			for i := 0; i < 3; i++ {
+				failed.sentPacketHandler.OnAlarm()
+			}
+			Expect(failed.potentiallyFailed.Get()).To(BeTrue())
+			Expect(failed.reinject).To(BeTrue())
+			Expect(failed.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			Expect(healthy.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(3))
+			Expect(sch.reinjectedPackets).To(Equal(uint64(3)))
+			// The stream frames are not retransmitted again while the copies are in flight
+			hasRetransmission, _, _ := sch.getRetransmission(sess)
+			Expect(hasRetransmission).To(BeTrue())
+			Expect(failed.sentPacketHandler.GetQueuedRetransmissions()).To(BeEmpty())
+			Expect(sess.streamFramer.HasFramesForRetransmission()).To(BeFalse())
+		})
+
+		It("doesn't reinject onto failing paths", func() {
+			healthy.potentiallyFailed.Set(true)
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			Expect(healthy.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+		})
+
+		It("cancels the original packet once the copy is acknowledged", func() {
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			copyNumber := healthy.sentPacketHandler.GetOutstandingPackets()[0].PacketNumber
+			sch.crossAckHandling(3, copyNumber)
+			Expect(failed.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(sch.dupPackets).To(BeEmpty())
+		})
+
+		It("retransmits the stream frames only if both copies are lost", func() {
+			Expect(sch.reinjectPackets(sess, failed)).To(Succeed())
+			copyNumber := healthy.sentPacketHandler.GetOutstandingPackets()[0].PacketNumber
+			Expect(sch.forgetCopy(1, 1)).To(BeTrue())
+			Expect(sch.forgetCopy(3, copyNumber)).To(BeFalse())
+		})
+	})
+
+	Context("redundancy budget", func() {
+		var (
+			sess       *session
+			sch        *scheduler
+			pth1, pth3 *path
+			newPath    = func(pathID protocol.PathID) *path {
+				pth := &path{
+					pathID: pathID,
+					sess:   sess,
+					conn:   &conn{pconn: &mockPacketConn{}, currentAddr: &net.UDPAddr{}},
+				}
+				pth.setup(nil)
+				pth.validated.Set(true)
+				sess.paths[pathID] = pth
+				return pth
+			}
+			newPacket = func(pn protocol.PacketNumber) *ackhandler.Packet {
+				return &ackhandler.Packet{
+					PacketNumber: pn,
+					Frames:       []wire.Frame{&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}},
+					Length:       100,
+				}
+			}
+		)
+
+		BeforeEach(func() {
+			sch = &scheduler{algorithm: &oppRedundantScheduler{}}
+			sch.setup()
+			sess = &session{
+				version:      protocol.VersionWhatever,
+				perspective:  protocol.PerspectiveClient,
+				paths:        make(map[protocol.PathID]*path),
+				config:       &Config{},
+				scheduler:    sch,
+				streamFramer: newStreamFramer(newStreamsMap(nil, protocol.PerspectiveClient, nil), nil),
+			}
+			sch.pathsRef = &sess.paths
+			sess.packer = &packetPacker{
+				cryptoSetup:          &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure},
+				connectionParameters: handshake.NewConnectionParamatersManager(protocol.PerspectiveClient, protocol.VersionWhatever, 0, 0, 0),
+				connectionID:         0x1337,
+				perspective:          protocol.PerspectiveClient,
+				version:              protocol.VersionWhatever,
+			}
+			pth1 = newPath(1)
+			pth3 = newPath(3)
+		})
+
+		AfterEach(func() {
+			for _, pth := range sess.paths {
+				pth.closeChan <- nil
+			}
+		})
+
+		It("selects redundant paths without a budget", func() {
+			ctx := sch.newScheduleContext(sess, false, false, nil)
+			Expect(ctx.RedundancyBudgetSpent).To(BeFalse())
+			Expect(sch.selectPath(sess, ctx, nil)).ToNot(BeNil())
+			Expect(sch.redundantPaths).To(HaveLen(1))
+		})
+
+		It("ignores the redundant paths while the budget is spent", func() {
+			sch.redundancy = newRedundancyBudget(0.1, 0)
+			ctx := sch.newScheduleContext(sess, false, false, nil)
+			Expect(ctx.RedundancyBudgetSpent).To(BeTrue())
+			Expect(sch.selectPath(sess, ctx, nil)).ToNot(BeNil())
+			Expect(sch.redundantPaths).To(BeEmpty())
+		})
+
+		It("duplicates a packet on every redundant path", func() {
+			pth2 := newPath(2)
+			sch.redundantPaths = []*path{pth2, pth3}
+			Expect(sch.redSendPacket(sess, pth1, newPacket(1), nil)).To(Succeed())
+			Expect(pth2.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(sch.duplicatedPackets).To(Equal(uint64(2)))
+			Expect(sch.duplicatedStreamBytes).To(Equal(uint64(12)))
+		})
+
+		It("sends copies on the redundant paths selected by the algorithm", func() {
+			fcm := mocks_fc.NewMockFlowControlManager(mockCtrl)
+			fcm.EXPECT().GetWindowUpdates(false).AnyTimes()
+			fcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.ByteCount(1000)).AnyTimes()
+			sess.flowControlManager = fcm
+			streamsMap := newStreamsMap(nil, protocol.PerspectiveClient, nil)
+			streamsMap.streams[1] = &stream{} // the crypto stream
+			streamsMap.openStreams = []protocol.StreamID{1}
+			sess.streamFramer = newStreamFramer(streamsMap, nil)
+			sess.packer.streamFramer = sess.streamFramer
+			sess.packer.stopWaiting = make(map[protocol.PathID]*wire.StopWaitingFrame)
+			sess.packer.ackFrame = make(map[protocol.PathID]*wire.AckFrame)
+			sess.streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")})
+			Expect(sch.sendPacket(sess)).To(Succeed())
+			Expect(pth1.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(sch.duplicatedPackets).To(Equal(uint64(1)))
+		})
+
+		It("cancels every copy once the original packet is acknowledged", func() {
+			pth2 := newPath(2)
+			sch.redundantPaths = []*path{pth2, pth3}
+			Expect(pth1.sentPacketHandler.SentPacket(newPacket(1))).To(Succeed())
+			Expect(sch.redSendPacket(sess, pth1, newPacket(1), nil)).To(Succeed())
+			Expect(sch.dupPackets[dupID{1, 1}]).To(HaveLen(2))
+			sch.crossAckHandling(1, 1)
+			Expect(pth2.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(sch.droppedDuplicatedPackets).To(Equal(uint64(2)))
+			Expect(sch.dupPackets).To(BeEmpty())
+		})
+
+		It("cancels the original packet and the other copies once a copy is acknowledged", func() {
+			pth2 := newPath(2)
+			sch.redundantPaths = []*path{pth2, pth3}
+			Expect(pth1.sentPacketHandler.SentPacket(newPacket(1))).To(Succeed())
+			Expect(sch.redSendPacket(sess, pth1, newPacket(1), nil)).To(Succeed())
+			copyNumber := pth2.sentPacketHandler.GetOutstandingPackets()[0].PacketNumber
+			sch.crossAckHandling(2, copyNumber)
+			Expect(pth1.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(BeEmpty())
+			Expect(sch.dupPackets).To(BeEmpty())
+		})
+
+		It("keeps the other copies in flight if a copy is lost", func() {
+			pth2 := newPath(2)
+			sch.redundantPaths = []*path{pth2, pth3}
+			Expect(sch.redSendPacket(sess, pth1, newPacket(1), nil)).To(Succeed())
+			copyNumber := pth2.sentPacketHandler.GetOutstandingPackets()[0].PacketNumber
+			Expect(sch.forgetCopy(2, copyNumber)).To(BeTrue())
+			Expect(sch.dupPackets[dupID{1, 1}]).To(HaveLen(1))
+			Expect(sch.forgetCopy(1, 1)).To(BeTrue())
+			Expect(sch.dupPackets).To(BeEmpty())
+		})
+
+		It("stops duplicating once the budget is spent", func() {
+			sch.redundancy = newRedundancyBudget(0, 10)
+			sch.redundantPaths = []*path{pth3}
+			Expect(sch.redSendPacket(sess, pth1, newPacket(1), nil)).To(Succeed())
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(sch.duplicatedStreamBytes).To(Equal(uint64(6)))
+			Expect(pth3.stats().DuplicatedStreamBytes).To(Equal(uint64(6)))
+			Expect(pth1.stats().DuplicatedStreamBytes).To(BeZero())
+			Expect(sch.redSendPacket(sess, pth1, newPacket(2), nil)).To(Succeed())
+			Expect(pth3.sentPacketHandler.GetOutstandingPackets()).To(HaveLen(1))
+			Expect(sch.redundancyBudgetSkips).To(Equal(uint64(1)))
+		})
+	})
+})
@@ -0,0 +1,474 @@
+package quic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+var (
+	schedulersMutex sync.RWMutex
+	schedulers      = map[string]func() Scheduler{
+		"lowRTT":       func() Scheduler { return &lowRTTScheduler{} },
+		"RR":           func() Scheduler { return &roundRobinScheduler{} },
+		"oppRedundant": func() Scheduler { return &oppRedundantScheduler{} },
+		"utilRepair":   func() Scheduler { return newUtilRepairScheduler() },
+		"blest":        func() Scheduler { return &blestScheduler{} },
+		"ecf":          func() Scheduler { return &ecfScheduler{} },
+	}
+)
+
+// RegisterScheduler makes a Scheduler available by the provided name.
+// newScheduler is called once for every session using the scheduler.
+// If RegisterScheduler is called twice with the same name or if newScheduler is nil, it panics.
+func RegisterScheduler(name string, newScheduler func() Scheduler) {
+	schedulersMutex.Lock()
+	defer schedulersMutex.Unlock()
+	if newScheduler == nil {
+		panic("quic: RegisterScheduler with nil constructor")
+	}
+	if _, ok := schedulers[name]; ok {
+		panic("quic: RegisterScheduler called twice for scheduler " + name)
+	}
+	schedulers[name] = newScheduler
+}
+
+// defaultScheduler returns the name of the scheduler used if the Config doesn't set one
+func defaultScheduler() string {
+	if SchedulerAlgorithm != "" {
+		return SchedulerAlgorithm
+	}
+	return "lowRTT"
+}
+
+func getSchedulerConstructor(name string) (func() Scheduler, error) {
+	if name == "" {
+		name = defaultScheduler()
+	}
+	schedulersMutex.RLock()
+	newScheduler, ok := schedulers[name]
+	schedulersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler: %s", name)
+	}
+	return newScheduler, nil
+}
+
+// checkScheduler returns an error if no scheduler is registered by the name
+func checkScheduler(name string) error {
+	_, err := getSchedulerConstructor(name)
+	return err
+}
+
+func newScheduler(name string) (Scheduler, error) {
+	newScheduler, err := getSchedulerConstructor(name)
+	if err != nil {
+		return nil, err
+	}
+	return newScheduler(), nil
+}
+
+// unprobedPathDuplicator implements the retransmission placement and ACK hooks shared by the built-in schedulers
+type unprobedPathDuplicator struct{}
+
+// ShouldDuplicate duplicates traffic when it was sent on an unknown performing path
+// FIXME adapt for new paths coming during the connection
+func (unprobedPathDuplicator) ShouldDuplicate(ctx *ScheduleContext, pth SchedulerPath) bool {
+	// DERA: redundant schedulers will duplicate packet anyways.
+	if pth.SmoothedRTT() != 0 || RedundantSending {
+		return false
+	}
+	currentQuota := pth.Quota()
+	// Was the packet duplicated on all potential paths?
+	for _, tmpPth := range ctx.Paths {
+		if tmpPth.PathID() == pth.PathID() {
+			continue
+		}
+		if tmpPth.Quota() < currentQuota && tmpPth.SendingAllowed() {
+			return true
+		}
+	}
+	return false
+}
+
+func (unprobedPathDuplicator) PiggybackAck(SchedulerPath) bool {
+	return true
+}
+
+// lowRTTScheduler sends on the path with the lowest smoothed RTT
+type lowRTTScheduler struct {
+	unprobedPathDuplicator
+}
+
+var _ Scheduler = &lowRTTScheduler{}
+
+func (*lowRTTScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	var selectedPath SchedulerPath
+	var lowerRTT time.Duration
+
+pathLoop:
+	for _, pth := range ctx.Paths {
+		// DERA: Only consider paths, that have space in their cwnd, even for retransmissions.
+		if !pth.SendingAllowed() {
+			continue pathLoop
+		}
+
+		// If this path is potentially failed, do not consider it for sending
+		if pth.PotentiallyFailed() {
+			continue pathLoop
+		}
+		currentRTT := pth.SmoothedRTT()
+
+		// Prefer staying single-path if not blocked by current path
+		// Don't consider this sample if the smoothed RTT is 0
+		if lowerRTT != 0 && currentRTT == 0 {
+			continue pathLoop
+		}
+
+		// Case if we have multiple paths unprobed
+		if currentRTT == 0 && selectedPath != nil && pth.Quota() > selectedPath.Quota() {
+			continue pathLoop
+		}
+
+		if currentRTT != 0 && lowerRTT != 0 && selectedPath != nil && currentRTT >= lowerRTT {
+			continue pathLoop
+		}
+		// Update
+		lowerRTT = currentRTT
+		selectedPath = pth
+		utils.Debugf("lowRTT: path %d cwnd %d in flight %d srtt %v", pth.PathID(), pth.CongestionWindow(), pth.BytesInFlight(), currentRTT)
+	}
+
+	return selectedPath, nil
+}
+
+// roundRobinScheduler sends on the path with the fewest packets sent so far
+type roundRobinScheduler struct {
+	unprobedPathDuplicator
+}
+
+var _ Scheduler = &roundRobinScheduler{}
+
+func (*roundRobinScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	// TODO cope with decreasing number of paths (needed?)
+	var selectedPath SchedulerPath
+	// Max possible value for lowerQuota at the beginning
+	lowerQuota := ^uint(0)
+
+pathLoop:
+	for _, pth := range ctx.Paths {
+		// Don't block path usage if we retransmit, even on another path
+		if !ctx.HasRetransmission && !pth.SendingAllowed() {
+			continue pathLoop
+		}
+
+		// If this path is potentially failed, do no consider it for sending
+		if pth.PotentiallyFailed() {
+			continue pathLoop
+		}
+
+		if currentQuota := pth.Quota(); currentQuota < lowerQuota {
+			selectedPath = pth
+			lowerQuota = currentQuota
+		}
+	}
+
+	return selectedPath, nil
+}
+
+// oppRedundantScheduler sends on any free-to-send path and selects all others as redundant paths
+type oppRedundantScheduler struct {
+	unprobedPathDuplicator
+}
+
+var _ Scheduler = &oppRedundantScheduler{}
+
+func (*oppRedundantScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	var selectedPath SchedulerPath
+	var redundantPaths []SchedulerPath
+
+pathLoop:
+	for _, pth := range ctx.Paths {
+		// Don't block path usage if we retransmit, even on another path
+		// DERA: Only consider paths, that have space in their cwnd for 'new' packets.
+		//		 Or consider all valid paths for outstanding retransmissions.
+		if !ctx.HasRetransmission && !pth.SendingAllowed() {
+			continue pathLoop
+		}
+
+		// If this path is potentially failed, do no consider it for sending
+		if pth.PotentiallyFailed() {
+			continue pathLoop
+		}
+
+		if selectedPath == nil {
+			selectedPath = pth
+		} else {
+			redundantPaths = append(redundantPaths, pth)
+		}
+	}
+
+	return selectedPath, redundantPaths
+}
+
+// utilRepairScheduler (V0.4) utilizes the path with the highest throughput
+type utilRepairScheduler struct {
+	unprobedPathDuplicator
+
+	// Count the number of lower RTT path selection for debugging purposes
+	lowerRTTSchedules uint64
+	// Count the number of CW blockings on the best path
+	cwBlocks uint64
+	// Count the number of each path selected as best path
+	bestPathSelection map[PathID]uint64
+	mutex             sync.RWMutex
+}
+
+var _ Scheduler = &utilRepairScheduler{}
+
+func newUtilRepairScheduler() *utilRepairScheduler {
+	return &utilRepairScheduler{bestPathSelection: make(map[PathID]uint64)}
+}
+
+func (sch *utilRepairScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	var maxPath SchedulerPath
+	var higherTP float64
+	var currentTP float64
+	var maxRTT float64
+	var redundantPaths []SchedulerPath
+
+	type pStat struct {
+		path       SchedulerPath
+		CW         uint64
+		RTT        float64
+		Throughput float64
+	}
+	var pathStats []pStat
+
+pathLoop:
+	for _, pth := range ctx.Paths {
+		// If this path is potentially failed, do not consider it for sending
+		if pth.PotentiallyFailed() {
+			continue pathLoop
+		}
+
+		// Only use when the first smoothed RTT measurement is available
+		currentRTT := pth.SmoothedRTT().Seconds()
+		currentCW := pth.CongestionWindow()
+		if currentRTT > 0 {
+			currentTP = float64(currentCW)
+		}
+
+		pathStats = append(pathStats, pStat{pth, currentCW, currentRTT, currentTP})
+		if currentTP != 0 && higherTP != 0 && maxPath != nil && currentTP < higherTP {
+			continue pathLoop
+		}
+
+		// Update
+		higherTP = currentTP
+		maxPath = pth
+		maxRTT = currentRTT
+	}
+
+	// To reduce large delays, retransmit redundantly on all free paths
+	if ctx.HasRetransmission && ctx.HasStreamRetransmission {
+		for _, pStat := range pathStats {
+			if pStat.path.SendingAllowed() {
+				redundantPaths = append(redundantPaths, pStat.path)
+			}
+		}
+		// Return any free to send path
+		if len(redundantPaths) > 0 {
+			return redundantPaths[0], redundantPaths
+		}
+		return nil, nil
+	}
+
+	// Sanity check
+	if maxPath == nil {
+		return nil, nil
+	}
+
+	sch.mutex.Lock()
+	sch.bestPathSelection[maxPath.PathID()]++
+	sch.mutex.Unlock()
+
+	// Utilize capacity of best path
+	if maxPath.CongestionFree() && maxPath.OvershootFree(len(pathStats)) {
+		return maxPath, nil
+	}
+
+	// Best path fully utilized, maybe transmit on another path
+	sch.cwBlocks++
+	if len(pathStats) > 1 {
+		// Sort paths descending based on throughput
+		sort.SliceStable(pathStats, func(i, j int) bool {
+			return pathStats[i].Throughput > pathStats[j].Throughput
+		})
+		// Exclude maxPath
+		pathStats = pathStats[1:]
+
+		// Send on path with next highest throughput
+		var lowerRTTpath SchedulerPath
+		for _, pStat := range pathStats {
+			if pStat.path.CongestionFree() {
+				redundantPaths = append(redundantPaths, pStat.path)
+				if pStat.RTT < maxRTT {
+					// Sending packet on lower RTT path with lower throughput than maxPath
+					if lowerRTTpath == nil {
+						lowerRTTpath = pStat.path
+						sch.lowerRTTSchedules++
+					}
+				} else {
+					// Replicate next packet on path, which otherwise idles.
+					// Happens on performance domination (maxPath has lower RTT & higher throughput)
+					redundantPaths = append(redundantPaths, pStat.path)
+				}
+			}
+		}
+
+		return lowerRTTpath, redundantPaths
+	}
+
+	return nil, nil
+}
+
+// bestPathSelections returns how often the path was selected as best path
+func (sch *utilRepairScheduler) bestPathSelections(pathID PathID) uint64 {
+	sch.mutex.RLock()
+	defer sch.mutex.RUnlock()
+	return sch.bestPathSelection[pathID]
+}
+
+// blestScheduler (BLocking ESTimation) sends on the path with the lowest smoothed RTT, like lowRTT.
+// When that path is blocked, a slower path is only used if the data sent on it is not estimated to
+// block the connection-level flow control window, while the fast path keeps sending. Otherwise,
+// sending waits for the fast path, avoiding head-of-line blocking at the receiver.
+type blestScheduler struct {
+	unprobedPathDuplicator
+
+	// Count the number of times the slower path was skipped for debugging purposes
+	slowPathSkips uint64
+}
+
+var _ Scheduler = &blestScheduler{}
+
+func (sch *blestScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	// fastPath is the path with the lowest RTT, slowPath the one with the lowest RTT that may send
+	var fastPath, slowPath SchedulerPath
+
+	for _, pth := range ctx.Paths {
+		// If this path is potentially failed, do not consider it for sending
+		if pth.PotentiallyFailed() {
+			continue
+		}
+		if fastPath == nil || fasterPath(pth, fastPath) {
+			fastPath = pth
+		}
+		if pth.SendingAllowed() && (slowPath == nil || fasterPath(pth, slowPath)) {
+			slowPath = pth
+		}
+	}
+
+	if slowPath == nil {
+		return nil, nil
+	}
+	// Without RTT samples, nothing can be estimated
+	if slowPath == fastPath || fastPath.SmoothedRTT() == 0 || slowPath.SmoothedRTT() == 0 {
+		return slowPath, nil
+	}
+
+	// Estimate the bytes the fast path sends while a packet is in flight on the slow path,
+	// assuming its congestion window grows by one packet every RTT
+	rounds := float64(slowPath.SmoothedRTT()) / float64(fastPath.SmoothedRTT())
+	fastPathBytes := (float64(fastPath.CongestionWindow()) + float64(protocol.DefaultTCPMSS)*(rounds-1)/2) * rounds
+	if fastPathBytes > float64(ctx.ConnectionSendWindow)-float64(protocol.MaxPacketSize) {
+		sch.slowPathSkips++
+		return nil, nil
+	}
+	return slowPath, nil
+}
+
+// ecfBeta is the hysteresis applied by the ECF scheduler while it waits for the fast path
+const ecfBeta = 0.25
+
+// ecfScheduler (Earliest Completion First) sends on the path with the lowest smoothed RTT, like lowRTT.
+// When that path is blocked, it compares the time needed to send the queued stream data by waiting for
+// the fast path with the time needed to send it now on a slower path, and uses the slower path only if
+// it is not expected to complete later.
+type ecfScheduler struct {
+	unprobedPathDuplicator
+
+	// waiting is set while the scheduler waits for the fast path
+	waiting bool
+	// Count the number of times the scheduler waited for the fast path for debugging purposes
+	fastPathWaits uint64
+}
+
+var _ Scheduler = &ecfScheduler{}
+
+func (sch *ecfScheduler) SelectPath(ctx *ScheduleContext) (SchedulerPath, []SchedulerPath) {
+	// fastPath is the path with the lowest RTT, slowPath the one with the lowest RTT that may send
+	var fastPath, slowPath SchedulerPath
+
+	for _, pth := range ctx.Paths {
+		// If this path is potentially failed, do not consider it for sending
+		if pth.PotentiallyFailed() {
+			continue
+		}
+		if fastPath == nil || fasterPath(pth, fastPath) {
+			fastPath = pth
+		}
+		if pth.SendingAllowed() && (slowPath == nil || fasterPath(pth, slowPath)) {
+			slowPath = pth
+		}
+	}
+
+	if slowPath == nil {
+		return nil, nil
+	}
+	// Without RTT samples, nothing can be estimated
+	if slowPath == fastPath || fastPath.SmoothedRTT() == 0 || slowPath.SmoothedRTT() == 0 ||
+		fastPath.CongestionWindow() == 0 || slowPath.CongestionWindow() == 0 {
+		sch.waiting = false
+		return slowPath, nil
+	}
+
+	queued := float64(ctx.QueuedBytes)
+	rttF, rttS := float64(fastPath.SmoothedRTT()), float64(slowPath.SmoothedRTT())
+	cwndF, cwndS := float64(fastPath.CongestionWindow()), float64(slowPath.CongestionWindow())
+
+	// The fast path sends the queued data in n RTTs, after waiting one RTT for its congestion window
+	n := 1 + queued/cwndF
+	hysteresis := 1.0
+	if sch.waiting {
+		hysteresis += ecfBeta
+	}
+	if n*rttF < hysteresis*rttS && queued/cwndS*rttS >= 2*rttF {
+		// Waiting for the fast path completes earlier, and the slow path would take longer than two fast RTTs
+		sch.waiting = true
+		sch.fastPathWaits++
+		return nil, nil
+	}
+	sch.waiting = false
+	return slowPath, nil
+}
+
+// fasterPath returns true if path a has a lower smoothed RTT than path b.
+// Unprobed paths come last, the one with the lowest quota first.
+func fasterPath(a, b SchedulerPath) bool {
+	rttA, rttB := a.SmoothedRTT(), b.SmoothedRTT()
+	switch {
+	case rttA == 0 && rttB == 0:
+		return a.Quota() < b.Quota()
+	case rttA == 0:
+		return false
+	case rttB == 0:
+		return true
+	}
+	return rttA < rttB
+}
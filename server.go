@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -23,6 +24,8 @@ type packetHandler interface {
 	GetVersion() protocol.VersionNumber
 	run() error
 	closeRemote(error)
+	// publicResetSent returns true if the session sent a Public Reset when it was closed
+	publicResetSent() bool
 }
 
 // A Listener of QUIC
@@ -43,6 +46,12 @@ type server struct {
 	sessionQueue chan Session
 	errorChan    chan struct{}
 
+	// counters of the sessions and Public Resets, reported by Stats
+	countersMutex    sync.Mutex
+	sessionsAccepted uint64
+	handshakesFailed uint64
+	publicResetsSent uint64
+
 	newSession func(conn connection, pconnMgr *pconnManager, createPaths bool, v protocol.VersionNumber, connectionID protocol.ConnectionID, sCfg *handshake.ServerConfig, tlsConf *tls.Config, config *Config) (packetHandler, <-chan handshakeEvent, error)
 }
 
@@ -116,6 +125,11 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 		return nil, err
 	}
 
+	serverConfig := populateServerConfig(config)
+	if err = validateConfig(serverConfig); err != nil {
+		return nil, err
+	}
+
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
@@ -127,11 +141,14 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 	} else {
 		pconnMgr = pconnMgrArg
 	}
+	if serverConfig.PacketCapture != nil {
+		pconnMgr.capture.start(serverConfig.PacketCapture)
+	}
 
 	s := &server{
 		pconnMgr:                  pconnMgr,
 		tlsConf:                   tlsConf,
-		config:                    populateServerConfig(config),
+		config:                    serverConfig,
 		certChain:                 certChain,
 		scfg:                      scfg,
 		sessions:                  map[protocol.ConnectionID]packetHandler{},
@@ -196,6 +213,18 @@ func populateServerConfig(config *Config) *Config {
 	if maxReceiveConnectionFlowControlWindow == 0 {
 		maxReceiveConnectionFlowControlWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowServer
 	}
+	scheduler := config.Scheduler
+	if scheduler == "" {
+		scheduler = defaultScheduler()
+	}
+	congestionControl := config.CongestionControl
+	if congestionControl == "" {
+		congestionControl = defaultCongestionControl()
+	}
+	metricsSink := config.MetricsSink
+	if metricsSink == nil {
+		metricsSink = defaultMetricsSink()
+	}
 
 	return &Config{
 		Versions:                              versions,
@@ -206,9 +235,34 @@ func populateServerConfig(config *Config) *Config {
 		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
 		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
 		CreatePaths:                           config.CreatePaths,
+		Scheduler:                             scheduler,
+		CongestionControl:                     congestionControl,
+		PathCongestionControl:                 config.PathCongestionControl,
+		MaxRedundancyRatio:                    config.MaxRedundancyRatio,
+		MaxRedundantBytesPerSecond:            config.MaxRedundantBytesPerSecond,
+		Tracer:                                config.Tracer,
+		MetricsSink:                           metricsSink,
+		KeyLogWriter:                          config.KeyLogWriter,
+		PacketCapture:                         config.PacketCapture,
 	}
 }
 
+// validateConfig checks the algorithms set in a populated Config
+func validateConfig(config *Config) error {
+	if err := checkScheduler(config.Scheduler); err != nil {
+		return err
+	}
+	if err := checkCongestionControl(config.CongestionControl); err != nil {
+		return err
+	}
+	for _, cc := range config.PathCongestionControl {
+		if err := checkCongestionControl(cc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // serve listens on an existing PacketConn
 func (s *server) serve() {
 	for {
@@ -275,6 +329,33 @@ func (s *server) Addr() net.Addr {
 	return s.pconnMgr.pconnAny.LocalAddr()
 }
 
+// Stats returns a snapshot of the counters of the server, and of the paths of its sessions
+func (s *server) Stats() ListenerStats {
+	s.countersMutex.Lock()
+	stats := ListenerStats{
+		SessionsAccepted: s.sessionsAccepted,
+		HandshakesFailed: s.handshakesFailed,
+		PublicResetsSent: s.publicResetsSent,
+	}
+	s.countersMutex.Unlock()
+
+	s.sessionsMutex.RLock()
+	sessions := make(map[protocol.ConnectionID]packetHandler, len(s.sessions))
+	for connID, session := range s.sessions {
+		if session != nil {
+			sessions[connID] = session
+		}
+	}
+	s.sessionsMutex.RUnlock()
+
+	// Don't hold the sessionsMutex while the sessions take their snapshots
+	for connID, session := range sessions {
+		stats.Sessions = append(stats.Sessions, SessionStats{ConnectionID: connID, Paths: session.Paths()})
+	}
+	sort.Slice(stats.Sessions, func(i, j int) bool { return stats.Sessions[i].ConnectionID < stats.Sessions[j].ConnectionID })
+	return stats
+}
+
 func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 	pconn := rcvRawPacket.rcvPconn
 	remoteAddr := rcvRawPacket.remoteAddr
@@ -304,6 +385,11 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 	hdr, err := wire.ParsePublicHeader(r, protocol.PerspectiveClient, version)
 	if err == wire.ErrPacketWithUnknownVersion {
 		_, err = pconn.WriteTo(wire.WritePublicReset(connID, 0, 0), remoteAddr)
+		if err == nil {
+			s.countersMutex.Lock()
+			s.publicResetsSent++
+			s.countersMutex.Unlock()
+		}
 		return err
 	}
 	if err != nil {
@@ -375,6 +461,11 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 		go func() {
 			// session.run() returns as soon as the session is closed
 			_ = session.run()
+			if session.publicResetSent() {
+				s.countersMutex.Lock()
+				s.publicResetsSent++
+				s.countersMutex.Unlock()
+			}
 			s.removeConnection(hdr.ConnectionID)
 		}()
 
@@ -382,12 +473,18 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 			for {
 				ev := <-handshakeChan
 				if ev.err != nil {
+					s.countersMutex.Lock()
+					s.handshakesFailed++
+					s.countersMutex.Unlock()
 					return
 				}
 				if ev.encLevel == protocol.EncryptionForwardSecure {
 					break
 				}
 			}
+			s.countersMutex.Lock()
+			s.sessionsAccepted++
+			s.countersMutex.Unlock()
 			s.sessionQueue <- session
 		}()
 	}
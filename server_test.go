@@ -30,6 +30,8 @@ type mockSession struct {
 	handshakeChan     chan handshakeEvent
 	handshakeComplete chan error // for WaitUntilHandshakeComplete
 	remoteAddr        net.Addr
+	paths             []PathStats
+	sentPublicReset   bool
 }
 
 func (s *mockSession) handlePacket(*receivedPacket) {
@@ -66,7 +68,17 @@ func (s *mockSession) OpenStreamSync() (Stream, error)  { panic("not implemented
 func (s *mockSession) LocalAddr() net.Addr              { panic("not implemented") }
 func (s *mockSession) RemoteAddr() net.Addr             { return s.remoteAddr }
 func (*mockSession) Context() context.Context           { panic("not implemented") }
+func (s *mockSession) Paths() []PathStats               { return s.paths }
+func (*mockSession) ClosePath(PathID) error             { panic("not implemented") }
+func (*mockSession) PathEvents() <-chan PathEvent       { panic("not implemented") }
 func (*mockSession) GetVersion() protocol.VersionNumber { return protocol.VersionWhatever }
+func (s *mockSession) publicResetSent() bool            { return s.sentPublicReset }
+func (*mockSession) OpenPath(net.Addr, net.Addr) (PathID, error) {
+	panic("not implemented")
+}
+func (*mockSession) SetPathPriority(PathID, PathPriority) error {
+	panic("not implemented")
+}
 
 var _ Session = &mockSession{}
 var _ NonFWSession = &mockSession{}
@@ -179,6 +191,45 @@ var _ = Describe("Server", func() {
 			close(done)
 		})
 
+		Context("stats", func() {
+			It("counts accepted sessions and failed handshakes", func() {
+				err := serv.handlePacket(&receivedRawPacket{data: firstPacket, rcvTime: time.Now()})
+				Expect(err).ToNot(HaveOccurred())
+				secondPacket := append([]byte{}, firstPacket...)
+				secondPacket[1] = 0x42 // change the connection ID
+				err = serv.handlePacket(&receivedRawPacket{data: secondPacket, rcvTime: time.Now()})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(serv.sessions).To(HaveLen(2))
+				serv.sessions[connID].(*mockSession).handshakeChan <- handshakeEvent{encLevel: protocol.EncryptionForwardSecure}
+				serv.sessions[0x4cfa9f9b66861942].(*mockSession).handshakeChan <- handshakeEvent{err: errors.New("handshake failed")}
+				Eventually(func() uint64 { return serv.Stats().SessionsAccepted }).Should(BeEquivalentTo(1))
+				Eventually(func() uint64 { return serv.Stats().HandshakesFailed }).Should(BeEquivalentTo(1))
+			})
+
+			It("reports the paths of the open sessions", func() {
+				sess1, _, _ := newMockSession(nil, pconnMgr, false, 0, 2, nil, nil, nil)
+				sess1.(*mockSession).paths = []PathStats{{PathID: 0}, {PathID: 1}}
+				sess2, _, _ := newMockSession(nil, pconnMgr, false, 0, 1, nil, nil, nil)
+				serv.sessions[2] = sess1
+				serv.sessions[1] = sess2
+				serv.sessions[3] = nil // closed
+				stats := serv.Stats()
+				Expect(stats.Sessions).To(Equal([]SessionStats{
+					{ConnectionID: 1},
+					{ConnectionID: 2, Paths: []PathStats{{PathID: 0}, {PathID: 1}}},
+				}))
+			})
+
+			It("counts the Public Resets sent by closed sessions", func() {
+				err := serv.handlePacket(&receivedRawPacket{data: firstPacket, rcvTime: time.Now()})
+				Expect(err).ToNot(HaveOccurred())
+				sess := serv.sessions[connID].(*mockSession)
+				sess.sentPublicReset = true
+				sess.stopRunLoop <- struct{}{}
+				Eventually(func() uint64 { return serv.Stats().PublicResetsSent }).Should(BeEquivalentTo(1))
+			})
+		})
+
 		It("assigns packets to existing sessions", func() {
 			err := serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: firstPacket, rcvTime: time.Now()})
 			Expect(err).ToNot(HaveOccurred())
@@ -350,12 +401,27 @@ var _ = Describe("Server", func() {
 	It("setups with the right values", func() {
 		supportedVersions := []protocol.VersionNumber{1, 3, 5}
 		acceptCookie := func(_ net.Addr, _ *Cookie) bool { return true }
+		tracer := func(bool, ConnectionID) Tracer { return nil }
+		metricsSink := func(bool, ConnectionID) MetricsSink { return nil }
+		keyLog := &bytes.Buffer{}
+		capture := &bytes.Buffer{}
 		config := Config{
-			Versions:         supportedVersions,
-			AcceptCookie:     acceptCookie,
-			HandshakeTimeout: 1337 * time.Hour,
-			IdleTimeout:      42 * time.Minute,
-			KeepAlive:        true,
+			Versions:          supportedVersions,
+			AcceptCookie:      acceptCookie,
+			HandshakeTimeout:  1337 * time.Hour,
+			IdleTimeout:       42 * time.Minute,
+			KeepAlive:         true,
+			Scheduler:         "RR",
+			CongestionControl: "olia",
+			PathCongestionControl: map[PathID]string{
+				1: "vegas",
+			},
+			MaxRedundancyRatio:         0.1,
+			MaxRedundantBytesPerSecond: 1000,
+			Tracer:                     tracer,
+			MetricsSink:                metricsSink,
+			KeyLogWriter:               keyLog,
+			PacketCapture:              capture,
 		}
 		ln, err := Listen(conn, &tls.Config{}, &config)
 		Expect(err).ToNot(HaveOccurred())
@@ -368,6 +434,16 @@ var _ = Describe("Server", func() {
 		Expect(server.config.IdleTimeout).To(Equal(42 * time.Minute))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(acceptCookie)))
 		Expect(server.config.KeepAlive).To(BeTrue())
+		Expect(server.config.Scheduler).To(Equal("RR"))
+		Expect(server.config.CongestionControl).To(Equal("olia"))
+		Expect(server.config.PathCongestionControl).To(HaveKeyWithValue(PathID(1), "vegas"))
+		Expect(server.config.MaxRedundancyRatio).To(Equal(0.1))
+		Expect(server.config.MaxRedundantBytesPerSecond).To(Equal(uint64(1000)))
+		Expect(reflect.ValueOf(server.config.Tracer)).To(Equal(reflect.ValueOf(tracer)))
+		Expect(reflect.ValueOf(server.config.MetricsSink)).To(Equal(reflect.ValueOf(metricsSink)))
+		Expect(server.config.KeyLogWriter).To(BeIdenticalTo(keyLog))
+		Expect(server.config.PacketCapture).To(BeIdenticalTo(capture))
+		Expect(capture.Len()).ToNot(BeZero())
 	})
 
 	It("fills in default values if options are not set in the Config", func() {
@@ -379,6 +455,28 @@ var _ = Describe("Server", func() {
 		Expect(server.config.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(defaultAcceptCookie)))
 		Expect(server.config.KeepAlive).To(BeFalse())
+		Expect(server.config.Scheduler).To(Equal("lowRTT"))
+		Expect(server.config.CongestionControl).To(Equal("cubic"))
+		Expect(server.config.MetricsSink).To(BeNil())
+	})
+
+	It("errors if the scheduler is unknown", func() {
+		_, err := Listen(conn, &tls.Config{}, &Config{Scheduler: "foobar"})
+		Expect(err).To(MatchError("unknown scheduler: foobar"))
+	})
+
+	It("errors if the congestion control is unknown", func() {
+		_, err := Listen(conn, &tls.Config{}, &Config{CongestionControl: "foobar"})
+		Expect(err).To(MatchError("unknown congestion control: foobar"))
+	})
+
+	It("errors if the congestion control of a path is unknown", func() {
+		config := &Config{
+			CongestionControl:     "olia",
+			PathCongestionControl: map[PathID]string{3: "foobar"},
+		}
+		_, err := Listen(conn, &tls.Config{}, config)
+		Expect(err).To(MatchError("unknown congestion control: foobar"))
 	})
 
 	It("listens on a given address", func() {
@@ -450,6 +548,7 @@ var _ = Describe("Server", func() {
 		Expect(conn.dataWrittenTo).To(Equal(udpAddr))
 		Expect(conn.dataWritten.Bytes()[0] & 0x02).ToNot(BeZero()) // check that the ResetFlag is set
 		Expect(ln.(*server).sessions).To(BeEmpty())
+		Eventually(func() uint64 { return ln.Stats().PublicResetsSent }).Should(BeEquivalentTo(1))
 	})
 })
 
@@ -2,12 +2,12 @@ package quic
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
-	"os"
-	"strconv"
+	"sort"
 	"sync"
 	"time"
 
@@ -36,6 +36,11 @@ type receivedPacket struct {
 var (
 	errRstStreamOnInvalidStream   = errors.New("RST_STREAM received for unknown stream")
 	errWindowUpdateOnClosedStream = errors.New("WINDOW_UPDATE received for an already closed stream")
+	errMultipathNotSupported      = errors.New("the session doesn't support multiple paths")
+	errCloseInitialPath           = errors.New("the initial path cannot be closed")
+	errUnknownPath                = errors.New("unknown path ID")
+	errInvalidPathPriority        = errors.New("invalid path priority")
+	errSessionClosed              = errors.New("the session is closed")
 )
 
 var (
@@ -43,6 +48,27 @@ var (
 	newCryptoSetupClient = handshake.NewCryptoSetupClient
 )
 
+// A pathRequest asks the run loop to change a path on behalf of another goroutine
+type pathRequest struct {
+	pathID protocol.PathID
+	// priority is the priority to set on the path
+	priority PathPriority
+	// result receives the error once the run loop handled the request
+	result chan error
+}
+
+// An openPathRequest asks the run loop to open a path on behalf of the application
+type openPathRequest struct {
+	local, remote net.Addr
+	// result receives the ID of the opened path or the error once the run loop handled the request
+	result chan openPathResult
+}
+
+type openPathResult struct {
+	pathID protocol.PathID
+	err    error
+}
+
 type handshakeEvent struct {
 	encLevel protocol.EncryptionLevel
 	err      error
@@ -69,6 +95,8 @@ type session struct {
 	streamsMap *streamsMap
 
 	rttStats *congestion.RTTStats
+	// clock is the source of time of the session and its paths
+	clock utils.Clock
 
 	remoteRTTs         map[protocol.PathID]time.Duration
 	lastPathsFrameSent time.Time
@@ -88,7 +116,15 @@ type session struct {
 	sendingScheduled chan struct{}
 	// closeChan is used to notify the run loop that it should terminate.
 	closeChan chan closeError
-	closeOnce sync.Once
+	// openPathChan is used to hand the paths to open to the run loop
+	openPathChan chan openPathRequest
+	// closePathChan is used to hand the paths to close to the run loop
+	closePathChan chan pathRequest
+	// pathPriorityChan is used to hand the path priorities to set to the run loop
+	pathPriorityChan chan pathRequest
+	// pathStatsChan is used to take the snapshots of the paths on the run loop
+	pathStatsChan chan chan []PathStats
+	closeOnce     sync.Once
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -121,15 +157,24 @@ type session struct {
 	keepAlivePingSent bool
 
 	pathTimers chan *path
+	pathEvents chan PathEvent
+
+	// tracer receives the events of the session, it is nil if the session is not traced
+	tracer Tracer
+	// metrics receives the measurements of the session, it is nil if the session is not measured
+	metrics MetricsSink
+	// capture writes the sent packets to the packet capture of the pconnManager, it is nil without a pconnManager
+	capture *packetCapture
 
 	pathManager         *pathManager
 	pathManagerLaunched bool
 
 	scheduler *scheduler
 
-	logLatFile *os.File
 	// allSntPackets counts the total sent Packets
 	allSntPackets uint64
+	// sentPublicReset is set if the session sent a Public Reset when it was closed
+	sentPublicReset utils.AtomicBool
 }
 
 var _ Session = &session{}
@@ -198,12 +243,20 @@ func (s *session) setup(
 	s.handshakeCompleteChan = make(chan error, 1)
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
+	s.openPathChan = make(chan openPathRequest)
+	s.closePathChan = make(chan pathRequest)
+	s.pathPriorityChan = make(chan pathRequest)
+	s.pathStatsChan = make(chan chan []PathStats)
 	s.sendingScheduled = make(chan struct{}, 1)
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 
-	s.timer = utils.NewTimer()
-	now := time.Now()
+	s.clock = utils.SystemClock{}
+	if pconnMgr != nil && pconnMgr.clock != nil {
+		s.clock = pconnMgr.clock
+	}
+	s.timer = utils.NewClockTimer(s.clock)
+	now := s.clock.Now()
 	s.lastNetworkActivityTime = now
 	s.sessionCreationTime = now
 
@@ -215,7 +268,23 @@ func (s *session) setup(
 		s.config.IdleTimeout,
 	)
 
-	s.scheduler = &scheduler{pathsRef: &s.paths}
+	s.pathEvents = make(chan PathEvent, protocol.MaxQueuedPathEvents)
+	if s.config.Tracer != nil {
+		s.tracer = s.config.Tracer(s.perspective == protocol.PerspectiveClient, s.connectionID)
+	}
+	if s.config.MetricsSink != nil {
+		s.metrics = s.config.MetricsSink(s.perspective == protocol.PerspectiveClient, s.connectionID)
+	}
+
+	algorithm, err := newScheduler(s.config.Scheduler)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.scheduler = &scheduler{
+		pathsRef:   &s.paths,
+		algorithm:  algorithm,
+		redundancy: newRedundancyBudget(s.config.MaxRedundancyRatio, s.config.MaxRedundantBytesPerSecond),
+	}
 	s.scheduler.setup()
 
 	if pconnMgr == nil && conn != nil {
@@ -227,6 +296,7 @@ func (s *session) setup(
 		}
 		s.paths[protocol.InitialPathID].setup(nil)
 	} else if pconnMgr != nil && conn != nil {
+		s.capture = &pconnMgr.capture
 		s.pathManager = &pathManager{pconnMgr: pconnMgr, sess: s}
 		s.pathManager.setup(conn)
 	} else {
@@ -239,7 +309,6 @@ func (s *session) setup(
 	s.streamFramer = newStreamFramer(s.streamsMap, s.flowControlManager)
 	s.pathTimers = make(chan *path)
 
-	var err error
 	if s.perspective == protocol.PerspectiveServer {
 		cryptoStream, _ := s.GetOrOpenStream(1)
 		_, _ = s.AcceptStream() // don't expose the crypto stream
@@ -266,6 +335,7 @@ func (s *session) setup(
 				s.config.Versions,
 				verifySourceAddr,
 				aeadChanged,
+				s.config.KeyLogWriter,
 			)
 		}
 	} else {
@@ -290,6 +360,7 @@ func (s *session) setup(
 				aeadChanged,
 				&handshake.TransportParameters{RequestConnectionIDTruncation: s.config.RequestConnectionIDTruncation, CacheHandshake: s.config.CacheHandshake},
 				negotiatedVersions,
+				s.config.KeyLogWriter,
 			)
 		}
 	}
@@ -323,8 +394,10 @@ func (s *session) run() error {
 
 	var timerPth *path
 
+	// The send rates are measured periodically, and written to the debug log
+	logSendings := s.metrics != nil || utils.Debug()
 	logStop := make(chan struct{})
-	if LogPayload {
+	if logSendings {
 		logTicker := time.NewTicker(1000 * time.Millisecond)
 		go s.scheduler.LogSendings(s, logTicker, logStop)
 	}
@@ -364,6 +437,15 @@ runLoop:
 		case <-s.sendingScheduled:
 			// We do all the interesting stuff after the switch statement, so
 			// nothing to see here.
+		case req := <-s.openPathChan:
+			pathID, err := s.pathManager.openPath(req.local, req.remote)
+			req.result <- openPathResult{pathID: pathID, err: err}
+		case req := <-s.closePathChan:
+			req.result <- s.closePath(req.pathID, true)
+		case req := <-s.pathPriorityChan:
+			req.result <- s.setPathPriority(req.pathID, req.priority)
+		case c := <-s.pathStatsChan:
+			c <- s.pathStats()
 		case tmpPth := <-s.pathTimers:
 			timerPth = tmpPth
 			// We do all the interesting stuff after the switch statement, so
@@ -384,6 +466,8 @@ runLoop:
 		case l, ok := <-aeadChanged:
 			if !ok { // the aeadChanged chan was closed. This means that the handshake is completed.
 				s.handshakeComplete = true
+				// The handshake validated the address of the peer on the initial path
+				s.validateInitialPath()
 				aeadChanged = nil // prevent this case from ever being selected again
 				close(s.handshakeChan)
 				close(s.handshakeCompleteChan)
@@ -393,12 +477,13 @@ runLoop:
 			}
 		}
 
-		now := time.Now()
+		now := s.clock.Now()
 		if timerPth != nil {
 			if timeout := timerPth.sentPacketHandler.GetAlarmTimeout(); !timeout.IsZero() && timeout.Before(now) {
 				// This could cause packets to be retransmitted, so check it before trying
 				// to send packets.
 				timerPth.sentPacketHandler.OnAlarm()
+				timerPth.traceCongestionState()
 			}
 			timerPth = nil
 		}
@@ -411,7 +496,7 @@ runLoop:
 			}
 		}
 
-		if s.config.KeepAlive && s.handshakeComplete && time.Since(s.lastNetworkActivityTime) >= s.idleTimeout()/2 {
+		if s.config.KeepAlive && s.handshakeComplete && now.Sub(s.lastNetworkActivityTime) >= s.idleTimeout()/2 {
 			// send the PING frame since there is no activity in the session
 			s.pathsLock.RLock()
 			// XXX (QDC): send PING over all paths, but is it really needed/useful?
@@ -444,7 +529,9 @@ runLoop:
 	}
 
 	// Stop logging
-	logStop <- struct{}{}
+	if logSendings {
+		logStop <- struct{}{}
+	}
 
 	// only send the error the handshakeChan when the handshake is not completed yet
 	// otherwise this chan will already be closed
@@ -453,6 +540,12 @@ runLoop:
 		s.handshakeChan <- handshakeEvent{err: closeErr.err}
 	}
 	s.handleCloseError(closeErr)
+	if s.tracer != nil {
+		s.tracer.Close()
+	}
+	if s.metrics != nil {
+		s.metrics.Close()
+	}
 	defer s.ctxCancel()
 	return closeErr.err
 }
@@ -476,6 +569,17 @@ func (s *session) maybeResetTimer() {
 	if !s.receivedTooManyUndecrytablePacketsTime.IsZero() {
 		deadline = utils.MinTime(deadline, s.receivedTooManyUndecrytablePacketsTime.Add(protocol.PublicResetTimeout))
 	}
+	// Wake up when the pacer of a path allows sending the next packet
+	s.pathsLock.RLock()
+	for _, pth := range s.paths {
+		if pth.sentPacketHandler == nil {
+			continue
+		}
+		if pacingDeadline := pth.sentPacketHandler.TimeUntilSend(); !pacingDeadline.IsZero() {
+			deadline = utils.MinTime(deadline, pacingDeadline)
+		}
+	}
+	s.pathsLock.RUnlock()
 
 	s.timer.Reset(deadline)
 }
@@ -494,7 +598,7 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 
 	if p.rcvTime.IsZero() {
 		// To simplify testing
-		p.rcvTime = time.Now()
+		p.rcvTime = s.now()
 	}
 
 	s.lastNetworkActivityTime = p.rcvTime
@@ -512,6 +616,11 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 		if err != nil {
 			return err
 		}
+		if err = pth.handlePacketImpl(p); err != nil {
+			return err
+		}
+		// The peer has to prove that it is reachable on the path before it carries data
+		return s.sendPathChallenge(pth)
 	}
 	return pth.handlePacketImpl(p)
 }
@@ -522,20 +631,18 @@ func (s *session) handleFrames(fs []wire.Frame, p *path, rcvTime time.Time) erro
 		wire.LogFrame(ff, false)
 		switch frame := ff.(type) {
 		case *wire.StreamFrame:
-			err = s.handleStreamFrame(frame)
-
-			// Log receive timestamps on client side for frame latencies
-			if s.perspective == protocol.PerspectiveClient {
-				if s.logLatFile == nil {
-					filename := "Lat_recv_F.log"
-					s.logLatFile, _ = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-				}
-
-				logLine := strconv.FormatUint(uint64(frame.StreamID), 10) + ";" +
-					strconv.FormatUint(uint64(frame.Offset), 10) + ";" +
-					strconv.FormatInt(rcvTime.UnixNano(), 10) + "\n"
-				s.logLatFile.WriteString(logLine)
+			// Measure the receive timestamps for frame latencies
+			if s.metrics != nil {
+				s.metrics.ReceivedStreamFrame(StreamFrameMetric{
+					PathID:       p.pathID,
+					PacketNumber: p.lastRcvdPacketNumber,
+					StreamID:     frame.StreamID,
+					Offset:       frame.Offset,
+					Data:         frame.Data,
+					Time:         rcvTime,
+				})
 			}
+			err = s.handleStreamFrame(frame)
 		case *wire.AckFrame:
 			err = s.handleAckFrame(frame)
 		case *wire.ConnectionCloseFrame:
@@ -558,8 +665,18 @@ func (s *session) handleFrames(fs []wire.Frame, p *path, rcvTime time.Time) erro
 				err = s.pathManager.handleAddAddressFrame(frame)
 				s.schedulePathsFrame()
 			}
+		case *wire.RemoveAddressFrame:
+			if s.pathManager != nil {
+				err = s.pathManager.handleRemoveAddressFrame(frame)
+			}
 		case *wire.ClosePathFrame:
 			s.handleClosePathFrame(frame)
+		case *wire.PathStatusFrame:
+			s.handlePathStatusFrame(frame)
+		case *wire.PathChallengeFrame:
+			_, err = s.sendPathFrame(&wire.PathResponseFrame{Data: frame.Data}, p)
+		case *wire.PathResponseFrame:
+			s.handlePathResponseFrame(frame, p)
 		case *wire.PathsFrame:
 			// So far, do nothing
 			s.pathsLock.RLock()
@@ -567,7 +684,7 @@ func (s *session) handleFrames(fs []wire.Frame, p *path, rcvTime time.Time) erro
 				s.remoteRTTs[frame.PathIDs[i]] = frame.RemoteRTTs[i]
 				if frame.RemoteRTTs[i] >= 30*time.Minute {
 					// Path is potentially failed
-					s.paths[frame.PathIDs[i]].potentiallyFailed.Set(true)
+					s.paths[frame.PathIDs[i]].setPotentiallyFailed("reported as failed by the peer")
 				}
 			}
 			s.pathsLock.RUnlock()
@@ -668,6 +785,7 @@ func (s *session) handleAckFrame(frame *wire.AckFrame) error {
 		// Update the session RTT, which comes to take the max RTT on all paths
 		s.rttStats.UpdateSessionRTT(pth.rttStats.SmoothedRTT())
 	}
+	pth.traceCongestionState()
 	return err
 }
 
@@ -681,6 +799,7 @@ func (s *session) handleClosePathFrame(frame *wire.ClosePathFrame) error {
 	return pth.sentPacketHandler.ReceivedClosePath(frame, pth.lastRcvdPacketNumber, pth.lastNetworkActivityTime)
 }
 
+// closePath closes the path, it must only be called from the run loop
 func (s *session) closePath(pthID protocol.PathID, sendClosePathFrame bool) error {
 	s.pathsLock.RLock()
 	defer s.pathsLock.RUnlock()
@@ -703,21 +822,203 @@ func (s *session) closePath(pthID protocol.PathID, sendClosePathFrame bool) erro
 	s.closedPaths[pthID] = true
 
 	if !sendClosePathFrame {
+		s.notifyPathEvent(pth, PathClosed, "closed by the peer")
 		return nil
 	}
+	s.notifyPathEvent(pth, PathClosed, "closed locally")
 
 	pth.sentPacketHandler.SetInflightAsLost()
-	closePathFrame := pth.GetClosePathFrame()
-	s.streamFramer.AddClosePathFrameForTransmission(closePathFrame)
+	// Without any packet received on the path, there is nothing to acknowledge in a CLOSE_PATH frame
+	if closePathFrame := pth.GetClosePathFrame(); closePathFrame != nil {
+		s.streamFramer.AddClosePathFrameForTransmission(closePathFrame)
+	}
 
 	return nil
 }
 
+// SetPathPriority sets the priority of a path and announces it to the peer
+func (s *session) SetPathPriority(pathID PathID, priority PathPriority) error {
+	if s.version < protocol.VersionMP {
+		return errMultipathNotSupported
+	}
+	if priority != PathPriorityActive && priority != PathPriorityBackup {
+		return errInvalidPathPriority
+	}
+	return s.requestPathChange(s.pathPriorityChan, pathRequest{pathID: pathID, priority: priority})
+}
+
+// setPathPriority sets the priority of the path, it must only be called from the run loop
+func (s *session) setPathPriority(pathID protocol.PathID, priority PathPriority) error {
+	s.pathsLock.Lock()
+	pth, ok := s.paths[pathID]
+	if !ok {
+		s.pathsLock.Unlock()
+		return errUnknownPath
+	}
+	backup := priority == PathPriorityBackup
+	pth.localBackup.Set(backup)
+	pth.statusSequence++
+	frame := &wire.PathStatusFrame{PathID: pathID, StatusSequence: pth.statusSequence, Backup: backup}
+	s.pathsLock.Unlock()
+
+	s.streamFramer.AddPathStatusFrameForTransmission(frame)
+	return nil
+}
+
+func (s *session) handlePathStatusFrame(frame *wire.PathStatusFrame) {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	pth, ok := s.paths[frame.PathID]
+	if !ok {
+		// XXX (QDC) Status for an unknown path, it may not be created yet
+		return
+	}
+	// Ignore reordered or retransmitted frames carrying an old status
+	if frame.StatusSequence <= pth.remoteStatusSequence {
+		return
+	}
+	pth.remoteStatusSequence = frame.StatusSequence
+	pth.remoteBackup.Set(frame.Backup)
+}
+
+func (s *session) validateInitialPath() {
+	s.pathsLock.RLock()
+	pth, ok := s.paths[protocol.InitialPathID]
+	s.pathsLock.RUnlock()
+	if ok && !pth.validated.Get() {
+		pth.validated.Set(true)
+		s.notifyPathEvent(pth, PathValidated, "handshake completed")
+	}
+}
+
+// sendPathChallenge challenges the peer to prove that it is reachable on the path.
+// After protocol.MaxPathChallenges unanswered challenges, the path is closed.
+func (s *session) sendPathChallenge(pth *path) error {
+	if pth.challengesSent >= protocol.MaxPathChallenges {
+		if pth.revertMigration() {
+			return nil
+		}
+		utils.Debugf("Closing path %x, %d PATH_CHALLENGEs were not answered", pth.pathID, pth.challengesSent)
+		return s.closePath(pth.pathID, true)
+	}
+	frame := &wire.PathChallengeFrame{}
+	if _, err := rand.Read(frame.Data[:]); err != nil {
+		return err
+	}
+	sent, err := s.sendPathFrame(frame, pth)
+	if err != nil {
+		return err
+	}
+	pth.challengeWithheld = !sent
+	if sent {
+		pth.challenge = frame.Data
+		pth.challengesSent++
+	}
+	return nil
+}
+
+func (s *session) handlePathResponseFrame(frame *wire.PathResponseFrame, pth *path) {
+	if pth.validated.Get() || pth.challengesSent == 0 || frame.Data != pth.challenge {
+		// Not an answer to the last challenge, ignore it
+		return
+	}
+	pth.validated.Set(true)
+	pth.validatedRemoteAddr = nil
+	s.notifyPathEvent(pth, PathValidated, "answered a PATH_CHALLENGE")
+	// The scheduler can now use the path
+	s.scheduleSending()
+}
+
+// PathEvents returns the channel on which path events are reported
+func (s *session) PathEvents() <-chan PathEvent {
+	return s.pathEvents
+}
+
+// notifyPathEvent reports a path event to the application, without blocking
+func (s *session) notifyPathEvent(pth *path, eventType PathEventType, reason string) {
+	if s.pathEvents == nil && s.tracer == nil {
+		return
+	}
+	event := PathEvent{
+		Type:       eventType,
+		PathID:     pth.pathID,
+		LocalAddr:  pth.conn.LocalAddr(),
+		RemoteAddr: pth.conn.RemoteAddr(),
+		Reason:     reason,
+	}
+	if s.tracer != nil {
+		s.tracer.UpdatedPath(event)
+	}
+	if s.pathEvents == nil {
+		return
+	}
+	select {
+	case s.pathEvents <- event:
+	default:
+		utils.Debugf("Dropping event for path %x: %s (%s)", pth.pathID, eventType, reason)
+	}
+}
+
+// OpenPath opens a new path between the local and the remote address
+func (s *session) OpenPath(local, remote net.Addr) (PathID, error) {
+	if s.version < protocol.VersionMP || s.pathManager == nil {
+		return 0, errMultipathNotSupported
+	}
+	// Sending the PATH_CHALLENGE frame on the new path must happen on the run loop
+	req := openPathRequest{local: local, remote: remote, result: make(chan openPathResult, 1)}
+	select {
+	case s.openPathChan <- req:
+	case <-s.ctx.Done():
+		return 0, errSessionClosed
+	}
+	res := <-req.result
+	return res.pathID, res.err
+}
+
+// ClosePath closes a path and informs the peer with a CLOSE_PATH frame
+func (s *session) ClosePath(pathID PathID) error {
+	if pathID == protocol.InitialPathID {
+		return errCloseInitialPath
+	}
+	return s.requestPathChange(s.closePathChan, pathRequest{pathID: pathID})
+}
+
+// requestPathChange hands the request to the run loop and waits until it was handled
+func (s *session) requestPathChange(c chan<- pathRequest, req pathRequest) error {
+	req.result = make(chan error, 1)
+	select {
+	case c <- req:
+	case <-s.ctx.Done():
+		return errSessionClosed
+	}
+	return <-req.result
+}
+
+// getClock returns the clock of the session. Sessions set up by tests may not have one, they use the system clock.
+func (s *session) getClock() utils.Clock {
+	if s.clock == nil {
+		return utils.SystemClock{}
+	}
+	return s.clock
+}
+
+func (s *session) now() time.Time {
+	return s.getClock().Now()
+}
+
 func (s *session) schedulePathsFrame() {
-	s.lastPathsFrameSent = time.Now()
+	s.lastPathsFrameSent = s.now()
 	s.streamFramer.AddPathsFrameForTransmission(s)
 }
 
+// congestionControl returns the name of the congestion control algorithm configured for the path
+func (s *session) congestionControl(pathID protocol.PathID) string {
+	if cc, ok := s.config.PathCongestionControl[pathID]; ok {
+		return cc
+	}
+	return s.config.CongestionControl
+}
+
 func (s *session) closePaths() {
 	// XXX (QDC): still for tests
 	if s.pathManager != nil {
@@ -820,7 +1121,13 @@ func (s *session) sendPackedPacket(packet *packedPacket, pth *path) error {
 	}
 	pth.sentPacket <- struct{}{}
 
+	if pth.antiAmplification {
+		pth.bytesSent += protocol.ByteCount(len(packet.raw))
+	}
+
 	s.logPacket(packet, pth.pathID)
+	s.traceSentPacket(packet, pth.pathID)
+	s.captureSentPacket(packet, pth)
 	return pth.conn.Write(packet.raw)
 }
 
@@ -834,12 +1141,18 @@ func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
 		return err
 	}
 	s.logPacket(packet, protocol.InitialPathID)
+	s.traceSentPacket(packet, protocol.InitialPathID)
+	s.captureSentPacket(packet, s.paths[protocol.InitialPathID])
 	// XXX (QDC): seems reasonable to send on pathID 0, but this can change
 	return s.paths[protocol.InitialPathID].conn.Write(packet.raw)
 }
 
 func (s *session) sendPing(pth *path) error {
-	packet, err := s.packer.PackPing(&wire.PingFrame{}, pth)
+	ping := &wire.PingFrame{}
+	if limited, err := s.amplificationLimited([]wire.Frame{ping}, pth); err != nil || limited {
+		return err
+	}
+	packet, err := s.packer.PackPing(ping, pth)
 	if err != nil {
 		return err
 	}
@@ -849,29 +1162,58 @@ func (s *session) sendPing(pth *path) error {
 	return s.sendPackedPacket(packet, pth)
 }
 
-func (s *session) logPacket(packet *packedPacket, pathID protocol.PathID) {
+// sendPathFrame sends a packet only containing the PathChallengeFrame or PathResponseFrame, on the path it belongs to.
+// It returns false if the anti-amplification budget of the path doesn't allow sending the packet.
+func (s *session) sendPathFrame(frame wire.Frame, pth *path) (bool, error) {
+	if limited, err := s.amplificationLimited([]wire.Frame{frame}, pth); err != nil || limited {
+		return false, err
+	}
+	packet, err := s.packer.PackPathFrame(frame, pth)
+	if err != nil {
+		return false, err
+	}
+	if packet == nil {
+		return false, errors.New("Session BUG: expected path probing packet not to be nil")
+	}
+	return true, s.sendPackedPacket(packet, pth)
+}
 
-	// Log send timestamps on server side for frame latencies
-	sendTime := time.Now().UnixNano()
-	if s.perspective == protocol.PerspectiveServer {
-		if s.logLatFile == nil {
-			filename := "Lat_send_F.log"
-			s.logLatFile, _ = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		}
+// amplificationLimited returns true if a packet only containing the frames would exceed the anti-amplification budget of the path.
+// The budget is checked before packing, such that withheld packets are neither numbered nor tracked as in flight.
+func (s *session) amplificationLimited(frames []wire.Frame, pth *path) (bool, error) {
+	if !pth.antiAmplification || pth.validated.Get() {
+		return false, nil
+	}
+	length, err := s.packer.PacketLength(frames, pth)
+	if err != nil {
+		return false, err
+	}
+	if pth.amplificationLimited(length) {
+		utils.Debugf("-> Not sending on path %x, the anti-amplification budget is exhausted", pth.pathID)
+		return true, nil
 	}
+	return false, nil
+}
+
+func (s *session) logPacket(packet *packedPacket, pathID protocol.PathID) {
+
+	sendTime := s.now()
 	s.allSntPackets++
 
 	utils.Debugf("-> Sending packet 0x%x (%d bytes) for connection %x on path %x, %s", packet.number, len(packet.raw), s.connectionID, pathID, packet.encryptionLevel)
 	for _, frame := range packet.frames {
 		wire.LogFrame(frame, true)
 
-		if s.perspective == protocol.PerspectiveServer {
-			if _, isType := frame.(*wire.StreamFrame); isType {
-				logLine := strconv.FormatUint(uint64(frame.(*wire.StreamFrame).StreamID), 10) + ";" +
-					strconv.FormatUint(uint64(frame.(*wire.StreamFrame).Offset), 10) + ";" +
-					strconv.FormatInt(sendTime, 10) + "\n"
-				s.logLatFile.WriteString(logLine)
-			}
+		// Measure the send timestamps for frame latencies
+		if streamFrame, ok := frame.(*wire.StreamFrame); ok && s.metrics != nil {
+			s.metrics.SentStreamFrame(StreamFrameMetric{
+				PathID:       pathID,
+				PacketNumber: packet.number,
+				StreamID:     streamFrame.StreamID,
+				Offset:       streamFrame.Offset,
+				Data:         streamFrame.Data,
+				Time:         sendTime,
+			})
 		}
 	}
 }
@@ -921,7 +1263,20 @@ func (s *session) newStream(id protocol.StreamID) *stream {
 	} else {
 		s.flowControlManager.NewStream(id, true)
 	}
-	return newStream(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager, s.perspective)
+	return newStream(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager, s.readStreamFrame)
+}
+
+// readStreamFrame is called by the streams when the application starts reading a frame
+func (s *session) readStreamFrame(frame *wire.StreamFrame) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.ReadStreamFrame(StreamFrameMetric{
+		StreamID: frame.StreamID,
+		Offset:   frame.Offset,
+		Data:     frame.Data,
+		Time:     s.now(),
+	})
 }
 
 // garbageCollectStreams goes through all streams and removes EOF'ed streams
@@ -942,6 +1297,7 @@ func (s *session) garbageCollectStreams() {
 
 func (s *session) sendPublicReset(rejectedPacketNumber protocol.PacketNumber) error {
 	utils.Infof("Sending public reset for connection %x, packet number %d", s.connectionID, rejectedPacketNumber)
+	s.sentPublicReset.Set(true)
 	// XXX: seems reasonable to send on the pathID 0, but this can change
 	return s.paths[protocol.InitialPathID].conn.Write(wire.WritePublicReset(s.connectionID, rejectedPacketNumber, 0))
 }
@@ -962,7 +1318,7 @@ func (s *session) tryQueueingUndecryptablePacket(p *receivedPacket) {
 	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
 		// if this is the first time the undecryptablePackets runs full, start the timer to send a Public Reset
 		if s.receivedTooManyUndecrytablePacketsTime.IsZero() {
-			s.receivedTooManyUndecrytablePacketsTime = time.Now()
+			s.receivedTooManyUndecrytablePacketsTime = s.now()
 			s.maybeResetTimer()
 		}
 		utils.Infof("Dropping undecrytable packet 0x%x (undecryptable packet queue full)", p.publicHeader.PacketNumber)
@@ -999,6 +1355,34 @@ func (s *session) RemoteAddr() net.Addr {
 	return s.paths[0].conn.RemoteAddr()
 }
 
+// Paths returns a snapshot of the statistics of every path, ordered by path ID
+func (s *session) Paths() []PathStats {
+	// The loss recovery and congestion control of the paths are only accessed on the run loop
+	result := make(chan []PathStats, 1)
+	select {
+	case s.pathStatsChan <- result:
+	case <-s.ctx.Done():
+		return nil
+	}
+	return <-result
+}
+
+// pathStats must only be called from the run loop
+func (s *session) pathStats() []PathStats {
+	s.pathsLock.RLock()
+	stats := make([]PathStats, 0, len(s.paths))
+	for _, pth := range s.paths {
+		stats = append(stats, pth.stats())
+	}
+	s.pathsLock.RUnlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].PathID < stats[j].PathID })
+	return stats
+}
+
+func (s *session) publicResetSent() bool {
+	return s.sentPublicReset.Get()
+}
+
 func (s *session) GetVersion() protocol.VersionNumber {
 	return s.version
 }
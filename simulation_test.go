@@ -0,0 +1,416 @@
+package quic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/simnet"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	simPayloadSize protocol.ByteCount = 1200
+	simAckSize                        = 50
+)
+
+// A simPath is a path of a simulated transfer.
+// Its packets are tracked by the real loss recovery and congestion control, and carried by simnet links.
+type simPath struct {
+	pathID   protocol.PathID
+	rttStats *congestion.RTTStats
+	sender   ackhandler.SentPacketHandler
+	receiver ackhandler.ReceivedPacketHandler
+	forward  *simnet.Link
+	backward *simnet.Link
+
+	packetNumber    protocol.PacketNumber
+	ackPacketNumber protocol.PacketNumber
+	quota           uint
+	alarm           *simnet.Timer
+	ackAlarm        *simnet.Timer
+}
+
+var _ SchedulerPath = &simPath{}
+
+func (p *simPath) PathID() protocol.PathID        { return p.pathID }
+func (p *simPath) SendingAllowed() bool           { return p.sender.SendingAllowed() }
+func (p *simPath) CongestionFree() bool           { return p.sender.CongestionFree() }
+func (p *simPath) OvershootFree(pathNum int) bool { return p.sender.OvershootFree(pathNum) }
+func (p *simPath) PotentiallyFailed() bool        { return false }
+func (p *simPath) SmoothedRTT() time.Duration     { return p.rttStats.SmoothedRTT() }
+func (p *simPath) MinRTT() time.Duration          { return p.rttStats.MinRTT() }
+func (p *simPath) CongestionWindow() uint64       { return p.sender.GetCongestionWindow() }
+func (p *simPath) BytesInFlight() uint64          { return p.sender.GetBytesInFlight() }
+func (p *simPath) Quota() uint                    { return p.quota }
+
+// A simTransfer sends a number of bytes over simulated paths, using a Scheduler to distribute the packets.
+// Everything runs on the virtual clock of the network, so a transfer is repeatable.
+// It drives the loss recovery, congestion control and scheduler of the paths in a single goroutine, as the
+// run loop of a session does. Sessions themselves run in several goroutines, see runSimulatedSessions.
+type simTransfer struct {
+	clock     *simnet.Clock
+	scheduler Scheduler
+	paths     []*simPath
+
+	size            protocol.ByteCount
+	sentOffset      protocol.ByteCount
+	retransmissions []*wire.StreamFrame
+	received        map[protocol.ByteCount]bool
+	receivedBytes   protocol.ByteCount
+	start           time.Time
+	completed       time.Time
+	wakeup          *simnet.Timer
+	err             error
+}
+
+func newSimTransfer(scheduler string, size protocol.ByteCount, seed int64, links ...simnet.LinkConfig) *simTransfer {
+	s, err := newScheduler(scheduler)
+	Expect(err).ToNot(HaveOccurred())
+	clock := simnet.NewClock(time.Unix(0, 0))
+	t := &simTransfer{
+		clock:     clock,
+		scheduler: s,
+		size:      size,
+		received:  make(map[protocol.ByteCount]bool),
+		start:     clock.Now(),
+	}
+	for i, link := range links {
+		rttStats := &congestion.RTTStats{}
+		cong := congestion.NewCubicSender(clock, rttStats, false, protocol.InitialCongestionWindow, protocol.DefaultMaxCongestionWindow)
+		pathID := protocol.PathID(i + 1)
+		t.paths = append(t.paths, &simPath{
+			pathID:   pathID,
+			rttStats: rttStats,
+			sender:   ackhandler.NewSentPacketHandler(rttStats, cong, nil, pathID, func(protocol.PathID, protocol.PacketNumber) {}, nil, clock),
+			receiver: ackhandler.NewReceivedPacketHandler(protocol.VersionWhatever, clock),
+			forward:  simnet.NewLink(clock, link, seed+2*int64(i)),
+			backward: simnet.NewLink(clock, link, seed+2*int64(i)+1),
+		})
+	}
+	return t
+}
+
+// run runs the transfer until all data arrived or the timeout expired, and returns the completion time
+func (t *simTransfer) run(timeout time.Duration) time.Duration {
+	t.send()
+	end := t.start.Add(timeout)
+	for t.completed.IsZero() && t.err == nil {
+		deadline, ok := t.clock.NextDeadline()
+		if !ok || deadline.After(end) {
+			break
+		}
+		t.clock.RunUntil(deadline)
+	}
+	Expect(t.err).ToNot(HaveOccurred())
+	Expect(t.completed.IsZero()).To(BeFalse(), "transfer did not complete")
+	return t.completed.Sub(t.start)
+}
+
+func (t *simTransfer) done() bool {
+	return t.sentOffset >= t.size && len(t.retransmissions) == 0
+}
+
+func (t *simTransfer) send() {
+	if !t.completed.IsZero() {
+		return
+	}
+	for _, p := range t.paths {
+		for packet := p.sender.DequeuePacketForRetransmission(); packet != nil; packet = p.sender.DequeuePacketForRetransmission() {
+			for _, f := range packet.Frames {
+				if sf, ok := f.(*wire.StreamFrame); ok {
+					t.retransmissions = append(t.retransmissions, sf)
+				}
+			}
+		}
+	}
+	for !t.done() {
+		ctx := &ScheduleContext{
+			HasStreamRetransmission: len(t.retransmissions) > 0,
+			QueuedBytes:             uint64(t.queuedBytes()),
+			ConnectionSendWindow:    uint64(protocol.MaxByteCount),
+		}
+		for _, p := range t.paths {
+			ctx.Paths = append(ctx.Paths, p)
+		}
+		selected, redundant := t.scheduler.SelectPath(ctx)
+		if selected == nil {
+			break
+		}
+		p := selected.(*simPath)
+		frame := t.nextFrame()
+		t.sendFrame(p, frame)
+		for _, r := range redundant {
+			if r != selected {
+				t.sendFrame(r.(*simPath), frame)
+			}
+		}
+		if t.scheduler.ShouldDuplicate(ctx, p) {
+			t.retransmissions = append(t.retransmissions, frame)
+		}
+	}
+	t.scheduleWakeup()
+}
+
+func (t *simTransfer) queuedBytes() protocol.ByteCount {
+	queued := t.size - t.sentOffset
+	for _, f := range t.retransmissions {
+		queued += f.DataLen()
+	}
+	return queued
+}
+
+func (t *simTransfer) nextFrame() *wire.StreamFrame {
+	if len(t.retransmissions) > 0 {
+		frame := t.retransmissions[0]
+		t.retransmissions = t.retransmissions[1:]
+		return frame
+	}
+	length := utils.MinByteCount(simPayloadSize, t.size-t.sentOffset)
+	frame := &wire.StreamFrame{StreamID: 5, Offset: t.sentOffset, Data: make([]byte, length)}
+	t.sentOffset += length
+	return frame
+}
+
+func (t *simTransfer) sendFrame(p *simPath, frame *wire.StreamFrame) {
+	p.packetNumber++
+	pn := p.packetNumber
+	if err := p.sender.SentPacket(&ackhandler.Packet{
+		PacketNumber: pn,
+		Frames:       []wire.Frame{frame},
+		Length:       protocol.MaxPacketSize,
+	}); err != nil {
+		t.err = err
+		return
+	}
+	p.quota++
+	t.setAlarm(p)
+	p.forward.Send(int(protocol.MaxPacketSize), func() { t.receive(p, pn, frame) })
+}
+
+func (t *simTransfer) receive(p *simPath, pn protocol.PacketNumber, frame *wire.StreamFrame) {
+	if err := p.receiver.ReceivedPacket(pn, true, uint64(protocol.MaxPacketSize)); err != nil {
+		t.err = err
+		return
+	}
+	if !t.received[frame.Offset] {
+		t.received[frame.Offset] = true
+		t.receivedBytes += frame.DataLen()
+		if t.receivedBytes == t.size {
+			t.completed = t.clock.Now()
+		}
+	}
+	t.sendAck(p)
+}
+
+func (t *simTransfer) sendAck(p *simPath) {
+	if p.ackAlarm != nil {
+		p.ackAlarm.Stop()
+		p.ackAlarm = nil
+	}
+	ack := p.receiver.GetAckFrame()
+	if ack == nil {
+		if deadline := p.receiver.GetAlarmTimeout(); !deadline.IsZero() {
+			p.ackAlarm = t.clock.AfterFunc(deadline.Sub(t.clock.Now()), func() { t.sendAck(p) })
+		}
+		return
+	}
+	p.ackPacketNumber++
+	pn := p.ackPacketNumber
+	p.backward.Send(simAckSize, func() {
+		if err := p.sender.ReceivedAck(ack, pn, t.clock.Now()); err != nil {
+			t.err = err
+			return
+		}
+		// the receiver would learn this from a STOP_WAITING frame
+		if least := p.sender.GetLeastUnacked(); least > 1 {
+			p.receiver.SetLowerLimit(least - 1)
+		}
+		t.setAlarm(p)
+		t.send()
+	})
+}
+
+// setAlarm arms the loss detection and RTO alarm of a path
+func (t *simTransfer) setAlarm(p *simPath) {
+	if p.alarm != nil {
+		p.alarm.Stop()
+		p.alarm = nil
+	}
+	deadline := p.sender.GetAlarmTimeout()
+	if deadline.IsZero() {
+		return
+	}
+	// Like the timers of the system clock, the alarm fires after its deadline, not exactly at it
+	p.alarm = t.clock.AfterFunc(deadline.Sub(t.clock.Now())+time.Nanosecond, func() {
+		p.alarm = nil
+		p.sender.OnAlarm()
+		t.setAlarm(p)
+		t.send()
+	})
+}
+
+// scheduleWakeup wakes the sender when the pacer of a path allows sending again
+func (t *simTransfer) scheduleWakeup() {
+	if t.wakeup != nil {
+		t.wakeup.Stop()
+		t.wakeup = nil
+	}
+	if t.done() {
+		return
+	}
+	var next time.Time
+	for _, p := range t.paths {
+		if at := p.sender.TimeUntilSend(); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	t.wakeup = t.clock.AfterFunc(next.Sub(t.clock.Now()), func() {
+		t.wakeup = nil
+		t.send()
+	})
+}
+
+var _ = Describe("Simulated transfers", func() {
+	fastLink := simnet.LinkConfig{Bandwidth: 1 << 20, Delay: 10 * time.Millisecond, QueueSize: 50000}
+	slowLink := simnet.LinkConfig{Bandwidth: 256 << 10, Delay: 50 * time.Millisecond, QueueSize: 50000}
+	lossyLink := simnet.LinkConfig{Bandwidth: 1 << 20, Delay: 20 * time.Millisecond, LossRate: 0.02, QueueSize: 50000}
+
+	It("completes a transfer on a single path", func() {
+		t := newSimTransfer("lowRTT", 100*simPayloadSize, 1, fastLink)
+		Expect(t.run(time.Minute)).To(BeNumerically(">", 20*time.Millisecond))
+		Expect(t.paths[0].quota).To(BeNumerically(">=", 100))
+	})
+
+	It("repeats transfers over lossy links exactly", func() {
+		run := func() (time.Duration, uint, uint) {
+			t := newSimTransfer("lowRTT", 500*simPayloadSize, 42, lossyLink, slowLink)
+			return t.run(time.Minute), t.paths[0].quota, t.paths[1].quota
+		}
+		d1, q11, q12 := run()
+		d2, q21, q22 := run()
+		Expect(d1).To(Equal(d2))
+		Expect(q11).To(Equal(q21))
+		Expect(q12).To(Equal(q22))
+	})
+
+	It("recovers from losses", func() {
+		t := newSimTransfer("lowRTT", 500*simPayloadSize, 7, lossyLink)
+		t.run(time.Minute)
+		Expect(t.paths[0].forward.Stats().LostPackets).ToNot(BeZero())
+		Expect(t.receivedBytes).To(Equal(t.size))
+	})
+
+	for _, name := range []string{"lowRTT", "RR", "oppRedundant", "blest", "ecf"} {
+		name := name
+
+		It("completes a transfer over heterogeneous paths using "+name, func() {
+			t := newSimTransfer(name, 300*simPayloadSize, 3, fastLink, slowLink)
+			Expect(t.run(time.Minute)).To(BeNumerically("<", 10*time.Second))
+		})
+	}
+
+	It("uses the slow path less with ECF than with lowRTT", func() {
+		lowRTT := newSimTransfer("lowRTT", 300*simPayloadSize, 3, fastLink, slowLink)
+		lowRTT.run(time.Minute)
+		ecf := newSimTransfer("ecf", 300*simPayloadSize, 3, fastLink, slowLink)
+		ecf.run(time.Minute)
+		Expect(ecf.paths[1].quota).To(BeNumerically("<", lowRTT.paths[1].quota))
+	})
+
+	It("sends every packet on all paths with the redundant scheduler", func() {
+		t := newSimTransfer("oppRedundant", 100*simPayloadSize, 3, fastLink, fastLink)
+		t.run(time.Minute)
+		Expect(t.paths[0].quota + t.paths[1].quota).To(BeNumerically(">", 100))
+	})
+})
+
+// simClock runs sessions on the virtual clock of a simulation
+type simClock struct {
+	*simnet.Clock
+}
+
+var _ utils.Clock = simClock{}
+
+func (c simClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return c.Clock.AfterFunc(d, f).Stop
+}
+
+// runSimulatedSessions connects a client and a server session over a simulated path, and sends data from the
+// client to the server. The sessions use the virtual clock of the network, which is advanced along with the
+// wall clock, since they run in several goroutines. It returns the data received by the server and the
+// statistics of the paths of the client.
+func runSimulatedSessions(link simnet.LinkConfig, data []byte) ([]byte, []PathStats) {
+	clock := simnet.NewClock(time.Now())
+	done := make(chan struct{})
+	defer close(done)
+	go clock.RunRealtime(done)
+
+	network := simnet.NewNetwork(clock, 1)
+	clientAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}
+	serverAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443}
+	network.AddPath(clientAddr, serverAddr, link)
+	serverConn, err := network.ListenPacket(serverAddr)
+	Expect(err).ToNot(HaveOccurred())
+	clientConn, err := network.ListenPacket(clientAddr)
+	Expect(err).ToNot(HaveOccurred())
+
+	serverPconnMgr := &pconnManager{perspective: protocol.PerspectiveServer, clock: simClock{clock}}
+	Expect(serverPconnMgr.setup(serverConn, nil)).To(Succeed())
+	ln, err := ListenImpl(serverConn, testdata.GetTLSConfig(), &Config{}, serverPconnMgr)
+	Expect(err).ToNot(HaveOccurred())
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		defer GinkgoRecover()
+		sess, err := ln.Accept()
+		Expect(err).ToNot(HaveOccurred())
+		str, err := sess.AcceptStream()
+		Expect(err).ToNot(HaveOccurred())
+		b, err := ioutil.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		received <- b
+	}()
+
+	clientPconnMgr := &pconnManager{perspective: protocol.PerspectiveClient, clock: simClock{clock}}
+	Expect(clientPconnMgr.setup(clientConn, nil)).To(Succeed())
+	sess, err := Dial(clientConn, serverAddr, "localhost:443", &tls.Config{InsecureSkipVerify: true}, &Config{}, clientPconnMgr)
+	Expect(err).ToNot(HaveOccurred())
+	defer sess.Close(nil)
+	str, err := sess.OpenStreamSync()
+	Expect(err).ToNot(HaveOccurred())
+	_, err = str.Write(data)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(str.Close()).To(Succeed())
+
+	var b []byte
+	Eventually(received, 10*time.Second).Should(Receive(&b))
+	return b, sess.Paths()
+}
+
+var _ = Describe("Simulated sessions", func() {
+	It("transfers data between a client and a server session", func() {
+		data := bytes.Repeat([]byte("foobar"), 20000)
+		link := simnet.LinkConfig{Bandwidth: 4 << 20, Delay: 25 * time.Millisecond, QueueSize: 100000}
+		received, stats := runSimulatedSessions(link, data)
+		Expect(received).To(Equal(data))
+		Expect(stats).ToNot(BeEmpty())
+		// The RTT is measured on the virtual clock, which only delays the packets on the links
+		Expect(stats[0].SmoothedRTT).To(BeNumerically(">=", 2*link.Delay))
+		Expect(stats[0].SentStreamBytes).To(BeNumerically(">=", len(data)))
+	})
+})
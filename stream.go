@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -58,11 +56,15 @@ type stream struct {
 	writeChan      chan struct{}
 	writeDeadline  time.Time
 
+	// pathPreference are the paths the data of the stream is sent on, if any
+	pathPreference []protocol.PathID
+	// pathPinned is set if the data must not be sent on other paths
+	pathPinned bool
+
 	flowControlManager flowcontrol.FlowControlManager
 
-	// For logging the buffer durations
-	perspective   protocol.Perspective
-	logBufferFile *os.File
+	// onRead is called when the application starts reading a frame, for measuring the buffer durations
+	onRead func(*wire.StreamFrame)
 }
 
 var _ Stream = &stream{}
@@ -78,7 +80,7 @@ var errDeadline net.Error = &deadlineError{}
 // newStream creates a new Stream
 func newStream(StreamID protocol.StreamID, onData func(), onReset func(protocol.StreamID, protocol.ByteCount),
 	flowControlManager flowcontrol.FlowControlManager,
-	perspective protocol.Perspective) *stream {
+	onRead func(*wire.StreamFrame)) *stream {
 	s := &stream{
 		onData:             onData,
 		onReset:            onReset,
@@ -87,7 +89,7 @@ func newStream(StreamID protocol.StreamID, onData func(), onReset func(protocol.
 		frameQueue:         newStreamFrameSorter(),
 		readChan:           make(chan struct{}, 1),
 		writeChan:          make(chan struct{}, 1),
-		perspective:        perspective,
+		onRead:             onRead,
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
@@ -105,14 +107,6 @@ func (s *stream) Read(p []byte) (int, error) {
 		return 0, io.EOF
 	}
 
-	// Log read timestamps on client side for frame buffering
-	if s.perspective == protocol.PerspectiveClient {
-		if s.logBufferFile == nil {
-			filename := "Lat_read_F.log"
-			s.logBufferFile, _ = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		}
-	}
-
 	bytesRead := 0
 	for bytesRead < len(p) {
 		s.mutex.Lock()
@@ -156,13 +150,8 @@ func (s *stream) Read(p []byte) (int, error) {
 		}
 		s.mutex.Unlock()
 
-		// Log that frame was read
-		if frame != nil {
-			readTime := time.Now().UnixNano()
-			logLine := strconv.FormatUint(uint64(frame.StreamID), 10) + ";" +
-				strconv.FormatUint(uint64(frame.Offset), 10) + ";" +
-				strconv.FormatInt(readTime, 10) + "\n"
-			s.logBufferFile.WriteString(logLine)
+		if frame != nil && s.onRead != nil {
+			s.onRead(frame)
 		}
 
 		if err != nil {
@@ -374,6 +363,62 @@ func (s *stream) SetDeadline(t time.Time) error {
 	return nil
 }
 
+func (s *stream) SetPathPreference(paths []PathID, pinned bool) {
+	s.mutex.Lock()
+	s.pathPreference = nil
+	if len(paths) > 0 {
+		s.pathPreference = append([]protocol.PathID{}, paths...)
+	}
+	s.pathPinned = pinned
+	s.mutex.Unlock()
+	// The data might now be sent on other paths
+	s.onData()
+}
+
+// mayUsePath returns if data of the stream may be sent on a path, given the paths that currently can carry data
+func (s *stream) mayUsePath(pathID protocol.PathID, usable func(protocol.PathID) bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.mayUsePathImpl(pathID, usable)
+}
+
+// mayUsePaths returns the paths of pathIDs on which data of the stream may be sent
+func (s *stream) mayUsePaths(pathIDs []protocol.PathID, usable func(protocol.PathID) bool) []protocol.PathID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.pathPreference) == 0 {
+		return pathIDs
+	}
+	var allowed []protocol.PathID
+	for _, pathID := range pathIDs {
+		if s.mayUsePathImpl(pathID, usable) {
+			allowed = append(allowed, pathID)
+		}
+	}
+	return allowed
+}
+
+func (s *stream) mayUsePathImpl(pathID protocol.PathID, usable func(protocol.PathID) bool) bool {
+	if len(s.pathPreference) == 0 {
+		return true
+	}
+	for _, preferred := range s.pathPreference {
+		if preferred == pathID {
+			return true
+		}
+	}
+	if s.pathPinned {
+		return false
+	}
+	// Fall back to the other paths only if no preferred path can be used
+	for _, preferred := range s.pathPreference {
+		if usable(preferred) {
+			return false
+		}
+	}
+	return true
+}
+
 // CloseRemote makes the stream receive a "virtual" FIN stream frame at a given offset
 func (s *stream) CloseRemote(offset protocol.ByteCount) {
 	s.AddStreamFrame(&wire.StreamFrame{FinBit: true, Offset: offset})
@@ -15,11 +15,16 @@ type streamFramer struct {
 
 	flowControlManager flowcontrol.FlowControlManager
 
-	retransmissionQueue  []*wire.StreamFrame
-	blockedFrameQueue    []*wire.BlockedFrame
-	addAddressFrameQueue []*wire.AddAddressFrame
-	closePathFrameQueue  []*wire.ClosePathFrame
-	pathsFrame           *wire.PathsFrame
+	retransmissionQueue     []*wire.StreamFrame
+	blockedFrameQueue       []*wire.BlockedFrame
+	addAddressFrameQueue    []*wire.AddAddressFrame
+	removeAddressFrameQueue []*wire.RemoveAddressFrame
+	closePathFrameQueue     []*wire.ClosePathFrame
+	pathStatusFrameQueue    []*wire.PathStatusFrame
+	pathsFrame              *wire.PathsFrame
+
+	// usablePaths are the paths that can currently carry stream data, nil if unknown
+	usablePaths []protocol.PathID
 }
 
 func newStreamFramer(streamsMap *streamsMap, flowControlManager flowcontrol.FlowControlManager) *streamFramer {
@@ -33,9 +38,57 @@ func (f *streamFramer) AddFrameForRetransmission(frame *wire.StreamFrame) {
 	f.retransmissionQueue = append(f.retransmissionQueue, frame)
 }
 
-func (f *streamFramer) PopStreamFrames(maxLen protocol.ByteCount) []*wire.StreamFrame {
+// PopStreamFrames pops the stream frames of a packet sent on a path.
+// Retransmissions are sent on any path, new data only on the paths allowed by the streams.
+func (f *streamFramer) PopStreamFrames(maxLen protocol.ByteCount, pathID protocol.PathID) []*wire.StreamFrame {
 	fs, currentLen := f.maybePopFramesForRetransmission(maxLen)
-	return append(fs, f.maybePopNormalFrames(maxLen-currentLen)...)
+	return append(fs, f.maybePopNormalFrames(maxLen-currentLen, pathID)...)
+}
+
+// SetUsablePaths sets the paths that can currently carry stream data.
+// Streams preferring other paths fall back to these paths if none of their preferred paths is usable.
+func (f *streamFramer) SetUsablePaths(pathIDs []protocol.PathID) {
+	f.usablePaths = pathIDs
+}
+
+func (f *streamFramer) pathUsable(pathID protocol.PathID) bool {
+	if f.usablePaths == nil {
+		return true
+	}
+	for _, usable := range f.usablePaths {
+		if usable == pathID {
+			return true
+		}
+	}
+	return false
+}
+
+// PathsWithData returns the paths of pathIDs on which stream data, including retransmissions, may be sent.
+// It walks all streams, so it should only be called once per scheduling round.
+func (f *streamFramer) PathsWithData(pathIDs []protocol.PathID) []protocol.PathID {
+	if len(f.retransmissionQueue) > 0 {
+		return pathIDs
+	}
+	withData := make(map[protocol.PathID]bool, len(pathIDs))
+	f.streamsMap.Iterate(func(s *stream) (bool, error) {
+		if s == nil || s.streamID == 1 {
+			return true, nil
+		}
+		if s.lenOfDataForWriting() == 0 && !s.shouldSendFin() {
+			return true, nil
+		}
+		for _, pathID := range s.mayUsePaths(pathIDs, f.pathUsable) {
+			withData[pathID] = true
+		}
+		return len(withData) < len(pathIDs), nil
+	})
+	var paths []protocol.PathID
+	for _, pathID := range pathIDs {
+		if withData[pathID] {
+			paths = append(paths, pathID)
+		}
+	}
+	return paths
 }
 
 func (f *streamFramer) PopBlockedFrame() *wire.BlockedFrame {
@@ -60,6 +113,19 @@ func (f *streamFramer) PopAddAddressFrame() *wire.AddAddressFrame {
 	return frame
 }
 
+func (f *streamFramer) AddRemoveAddressForTransmission(ipVersion uint8, addr net.UDPAddr) {
+	f.removeAddressFrameQueue = append(f.removeAddressFrameQueue, &wire.RemoveAddressFrame{IPVersion: ipVersion, Addr: addr})
+}
+
+func (f *streamFramer) PopRemoveAddressFrame() *wire.RemoveAddressFrame {
+	if len(f.removeAddressFrameQueue) == 0 {
+		return nil
+	}
+	frame := f.removeAddressFrameQueue[0]
+	f.removeAddressFrameQueue = f.removeAddressFrameQueue[1:]
+	return frame
+}
+
 func (f *streamFramer) AddPathsFrameForTransmission(s *session) {
 	s.pathsLock.RLock()
 	defer s.pathsLock.RUnlock()
@@ -100,10 +166,39 @@ func (f *streamFramer) PopClosePathFrame() *wire.ClosePathFrame {
 	return frame
 }
 
+func (f *streamFramer) AddPathStatusFrameForTransmission(pathStatusFrame *wire.PathStatusFrame) {
+	f.pathStatusFrameQueue = append(f.pathStatusFrameQueue, pathStatusFrame)
+}
+
+func (f *streamFramer) PopPathStatusFrame() *wire.PathStatusFrame {
+	if len(f.pathStatusFrameQueue) == 0 {
+		return nil
+	}
+	frame := f.pathStatusFrameQueue[0]
+	f.pathStatusFrameQueue = f.pathStatusFrameQueue[1:]
+	return frame
+}
+
 func (f *streamFramer) HasFramesForRetransmission() bool {
 	return len(f.retransmissionQueue) > 0
 }
 
+// QueuedBytes returns the number of bytes of stream data waiting to be sent, including retransmissions.
+// Data of the crypto stream is not counted.
+func (f *streamFramer) QueuedBytes() protocol.ByteCount {
+	var queued protocol.ByteCount
+	for _, frame := range f.retransmissionQueue {
+		queued += frame.DataLen()
+	}
+	f.streamsMap.Iterate(func(s *stream) (bool, error) {
+		if s != nil && s.streamID != 1 {
+			queued += s.lenOfDataForWriting()
+		}
+		return true, nil
+	})
+	return queued
+}
+
 func (f *streamFramer) HasCryptoStreamFrame() bool {
 	// TODO(#657): Flow control
 	cs, _ := f.streamsMap.GetOrOpenStream(1)
@@ -162,7 +257,7 @@ func (f *streamFramer) maybePopFramesForRetransmission(maxLen protocol.ByteCount
 	return
 }
 
-func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount) (res []*wire.StreamFrame) {
+func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount, pathID protocol.PathID) (res []*wire.StreamFrame) {
 	frame := &wire.StreamFrame{DataLenPresent: true}
 	var currentLen protocol.ByteCount
 
@@ -170,6 +265,9 @@ func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount) (res []
 		if s == nil || s.streamID == 1 /* crypto stream is handled separately */ {
 			return true, nil
 		}
+		if !s.mayUsePath(pathID, f.pathUsable) {
+			return true, nil
+		}
 
 		frame.StreamID = s.streamID
 		// not perfect, but thread-safe since writeOffset is only written when getting data
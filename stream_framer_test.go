@@ -51,10 +51,17 @@ var _ = Describe("Stream Framer", func() {
 		Expect(framer.HasFramesForRetransmission()).To(BeTrue())
 	})
 
+	It("counts the queued bytes", func() {
+		Expect(framer.QueuedBytes()).To(BeZero())
+		framer.AddFrameForRetransmission(retransmittedFrame1)
+		stream1.dataForWriting = []byte("foobar")
+		Expect(framer.QueuedBytes()).To(Equal(protocol.ByteCount(2 + 6)))
+	})
+
 	It("sets the DataLenPresent for dequeued retransmitted frames", func() {
 		mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame1.StreamID, retransmittedFrame1.DataLen())
 		framer.AddFrameForRetransmission(retransmittedFrame1)
-		fs := framer.PopStreamFrames(protocol.MaxByteCount)
+		fs := framer.PopStreamFrames(protocol.MaxByteCount, protocol.InitialPathID)
 		Expect(fs).To(HaveLen(1))
 		Expect(fs[0].DataLenPresent).To(BeTrue())
 	})
@@ -64,14 +71,63 @@ var _ = Describe("Stream Framer", func() {
 		mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(6))
 		mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 		stream1.dataForWriting = []byte("foobar")
-		fs := framer.PopStreamFrames(protocol.MaxByteCount)
+		fs := framer.PopStreamFrames(protocol.MaxByteCount, protocol.InitialPathID)
 		Expect(fs).To(HaveLen(1))
 		Expect(fs[0].DataLenPresent).To(BeTrue())
 	})
 
+	Context("path preferences", func() {
+		BeforeEach(func() {
+			stream1.onData = func() {}
+			stream1.dataForWriting = []byte("foobar")
+			stream1.SetPathPreference([]PathID{1}, false)
+			stream2.dataForWriting = []byte("foobaz")
+			framer.SetUsablePaths([]protocol.PathID{1, 3})
+		})
+
+		It("only sends the data of a stream on its preferred paths", func() {
+			mockFcm.EXPECT().SendWindowSize(id2).Return(protocol.MaxByteCount, nil)
+			mockFcm.EXPECT().AddBytesSent(id2, protocol.ByteCount(6))
+			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
+			Expect(framer.PathsWithData([]protocol.PathID{1, 3})).To(Equal([]protocol.PathID{1, 3}))
+			fs := framer.PopStreamFrames(1000, 3)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].StreamID).To(Equal(id2))
+			Expect(framer.PathsWithData([]protocol.PathID{1, 3})).To(Equal([]protocol.PathID{1}))
+		})
+
+		It("falls back to the other paths if no preferred path is usable", func() {
+			stream2.dataForWriting = nil
+			Expect(framer.PathsWithData([]protocol.PathID{3})).To(BeEmpty())
+			framer.SetUsablePaths([]protocol.PathID{3})
+			Expect(framer.PathsWithData([]protocol.PathID{3})).To(Equal([]protocol.PathID{3}))
+		})
+
+		It("never sends the data of a pinned stream on other paths", func() {
+			stream2.dataForWriting = nil
+			stream1.SetPathPreference([]PathID{1}, true)
+			framer.SetUsablePaths([]protocol.PathID{3})
+			Expect(framer.PathsWithData([]protocol.PathID{3})).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, 3)).To(BeEmpty())
+		})
+
+		It("removes the preference", func() {
+			stream2.dataForWriting = nil
+			stream1.SetPathPreference(nil, false)
+			Expect(framer.PathsWithData([]protocol.PathID{3})).To(Equal([]protocol.PathID{3}))
+		})
+
+		It("sends retransmissions on any path", func() {
+			stream2.dataForWriting = nil
+			stream1.SetPathPreference([]PathID{1}, true)
+			framer.AddFrameForRetransmission(retransmittedFrame1)
+			Expect(framer.PathsWithData([]protocol.PathID{3})).To(Equal([]protocol.PathID{3}))
+		})
+	})
+
 	Context("Popping", func() {
 		It("returns nil when popping an empty framer", func() {
-			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, protocol.InitialPathID)).To(BeEmpty())
 		})
 
 		It("pops frames for retransmission", func() {
@@ -79,11 +135,11 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame2.StreamID, retransmittedFrame2.DataLen())
 			framer.AddFrameForRetransmission(retransmittedFrame1)
 			framer.AddFrameForRetransmission(retransmittedFrame2)
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(2))
 			Expect(fs[0]).To(Equal(retransmittedFrame1))
 			Expect(fs[1]).To(Equal(retransmittedFrame2))
-			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, protocol.InitialPathID)).To(BeEmpty())
 		})
 
 		It("returns normal frames", func() {
@@ -91,11 +147,11 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(6))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].StreamID).To(Equal(stream1.streamID))
 			Expect(fs[0].Data).To(Equal([]byte("foobar")))
-			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, protocol.InitialPathID)).To(BeEmpty())
 		})
 
 		It("returns multiple normal frames", func() {
@@ -107,7 +163,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
 			stream2.dataForWriting = []byte("foobaz")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(2))
 			// Swap if we dequeued in other order
 			if fs[0].StreamID != stream1.streamID {
@@ -117,7 +173,7 @@ var _ = Describe("Stream Framer", func() {
 			Expect(fs[0].Data).To(Equal([]byte("foobar")))
 			Expect(fs[1].StreamID).To(Equal(stream2.streamID))
 			Expect(fs[1].Data).To(Equal([]byte("foobaz")))
-			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, protocol.InitialPathID)).To(BeEmpty())
 		})
 
 		It("returns retransmission frames before normal frames", func() {
@@ -127,22 +183,22 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame1.StreamID, retransmittedFrame1.DataLen())
 			framer.AddFrameForRetransmission(retransmittedFrame1)
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(2))
 			Expect(fs[0]).To(Equal(retransmittedFrame1))
 			Expect(fs[1].StreamID).To(Equal(stream1.streamID))
-			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
+			Expect(framer.PopStreamFrames(1000, protocol.InitialPathID)).To(BeEmpty())
 		})
 
 		It("does not pop empty frames", func() {
 			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.MaxByteCount, nil)
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(4)
+			fs := framer.PopStreamFrames(4, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(0))
 			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.MaxByteCount, nil)
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(1))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
-			fs = framer.PopStreamFrames(5)
+			fs = framer.PopStreamFrames(5, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].Data).ToNot(BeEmpty())
 			Expect(fs[0].FinBit).To(BeFalse())
@@ -157,11 +213,11 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = bytes.Repeat([]byte("f"), 100)
 			stream2.dataForWriting = bytes.Repeat([]byte("e"), 100)
-			fs := framer.PopStreamFrames(10)
+			fs := framer.PopStreamFrames(10, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			// it doesn't matter here if this data is from stream1 or from stream2...
 			firstStreamID := fs[0].StreamID
-			fs = framer.PopStreamFrames(10)
+			fs = framer.PopStreamFrames(10, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			// ... but the data popped this time has to be from the other stream
 			Expect(fs[0].StreamID).ToNot(Equal(firstStreamID))
@@ -205,7 +261,7 @@ var _ = Describe("Stream Framer", func() {
 				mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame2.StreamID, protocol.ByteCount(2))
 				framer.AddFrameForRetransmission(retransmittedFrame2)
 				origlen := retransmittedFrame2.DataLen()
-				fs := framer.PopStreamFrames(6)
+				fs := framer.PopStreamFrames(6, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				minLength, _ := fs[0].MinLength(0)
 				Expect(minLength + fs[0].DataLen()).To(Equal(protocol.ByteCount(6)))
@@ -253,11 +309,11 @@ var _ = Describe("Stream Framer", func() {
 			It("only removes a frame from the framer after returning all split parts", func() {
 				framer.AddFrameForRetransmission(retransmittedFrame2)
 				mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame2.StreamID, protocol.ByteCount(2))
-				fs := framer.PopStreamFrames(6)
+				fs := framer.PopStreamFrames(6, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(framer.retransmissionQueue).ToNot(BeEmpty())
 				mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame2.StreamID, protocol.ByteCount(2))
-				fs = framer.PopStreamFrames(1000)
+				fs = framer.PopStreamFrames(1000, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(framer.retransmissionQueue).To(BeEmpty())
 			})
@@ -271,13 +327,13 @@ var _ = Describe("Stream Framer", func() {
 				mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 				origdata := []byte("foobar")
 				stream1.dataForWriting = origdata
-				fs := framer.PopStreamFrames(7)
+				fs := framer.PopStreamFrames(7, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(fs[0].Data).To(Equal([]byte("foo")))
 				var b bytes.Buffer
 				fs[0].Write(&b, 0)
 				Expect(b.Len()).To(Equal(7))
-				fs = framer.PopStreamFrames(1000)
+				fs = framer.PopStreamFrames(1000, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(fs[0].Data).To(Equal([]byte("bar")))
 			})
@@ -289,7 +345,7 @@ var _ = Describe("Stream Framer", func() {
 				mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 				stream1.writeOffset = 42
 				stream1.finishedWriting.Set(true)
-				fs := framer.PopStreamFrames(1000)
+				fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(fs[0].StreamID).To(Equal(stream1.streamID))
 				Expect(fs[0].Offset).To(Equal(stream1.writeOffset))
@@ -302,7 +358,7 @@ var _ = Describe("Stream Framer", func() {
 				mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 				stream1.writeOffset = 42
 				stream1.finishedWriting.Set(true)
-				fs := framer.PopStreamFrames(1000)
+				fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(fs[0].StreamID).To(Equal(stream1.streamID))
 				Expect(fs[0].Offset).To(Equal(stream1.writeOffset))
@@ -316,7 +372,7 @@ var _ = Describe("Stream Framer", func() {
 				mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 				stream1.dataForWriting = []byte("foobar")
 				stream1.finishedWriting.Set(true)
-				fs := framer.PopStreamFrames(1000)
+				fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 				Expect(fs).To(HaveLen(1))
 				Expect(fs[0].StreamID).To(Equal(stream1.streamID))
 				Expect(fs[0].Data).To(Equal([]byte("foobar")))
@@ -331,13 +387,13 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(6))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
-			framer.PopStreamFrames(1000)
+			framer.PopStreamFrames(1000, protocol.InitialPathID)
 		})
 
 		It("does not count retransmitted frames as sent bytes", func() {
 			framer.AddFrameForRetransmission(retransmittedFrame1)
 			mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame1.StreamID, retransmittedFrame1.DataLen())
-			framer.PopStreamFrames(1000)
+			framer.PopStreamFrames(1000, protocol.InitialPathID)
 		})
 
 		It("returns the whole frame if it fits", func() {
@@ -346,7 +402,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.writeOffset = 10
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].DataLen()).To(Equal(protocol.ByteCount(6)))
 		})
@@ -356,7 +412,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(3))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].Data).To(Equal([]byte("foo")))
 		})
@@ -367,7 +423,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.writeOffset = 1
 			stream1.dataForWriting = []byte("foobar")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].Data).To(Equal([]byte("foo")))
 		})
@@ -379,7 +435,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
 			stream2.dataForWriting = []byte("foobaz")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(HaveLen(1))
 			Expect(fs[0].StreamID).To(Equal(stream2.StreamID()))
 			Expect(fs[0].Data).To(Equal([]byte("foobaz")))
@@ -390,7 +446,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().SendWindowSize(id2).Return(protocol.ByteCount(0), nil)
 			stream1.dataForWriting = []byte("foobar")
 			stream2.dataForWriting = []byte("foobaz")
-			fs := framer.PopStreamFrames(1000)
+			fs := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(fs).To(BeEmpty())
 		})
 	})
@@ -405,7 +461,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(3))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foo")
-			frames := framer.PopStreamFrames(1000)
+			frames := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(frames).To(HaveLen(1))
 			blockedFrame := framer.PopBlockedFrame()
 			Expect(blockedFrame).ToNot(BeNil())
@@ -420,11 +476,11 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(0))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foo")
-			frames := framer.PopStreamFrames(1000)
+			frames := framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].FinBit).To(BeFalse())
 			stream1.finishedWriting.Set(true)
-			frames = framer.PopStreamFrames(1000)
+			frames = framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(frames).To(HaveLen(1))
 			Expect(frames[0].FinBit).To(BeTrue())
 			Expect(frames[0].DataLen()).To(BeZero())
@@ -438,7 +494,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(3))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.ByteCount(0))
 			stream1.dataForWriting = []byte("foo")
-			framer.PopStreamFrames(1000)
+			framer.PopStreamFrames(1000, protocol.InitialPathID)
 			blockedFrame := framer.PopBlockedFrame()
 			Expect(blockedFrame).ToNot(BeNil())
 			Expect(blockedFrame.StreamID).To(BeZero())
@@ -450,7 +506,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(3))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foo")
-			framer.PopStreamFrames(1000)
+			framer.PopStreamFrames(1000, protocol.InitialPathID)
 			Expect(framer.PopBlockedFrame()).To(BeNil())
 		})
 
@@ -459,7 +515,7 @@ var _ = Describe("Stream Framer", func() {
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(3))
 			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
 			stream1.dataForWriting = []byte("foobar")
-			framer.PopStreamFrames(1000)
+			framer.PopStreamFrames(1000, protocol.InitialPathID)
 			blockedFrame := framer.PopBlockedFrame()
 			Expect(blockedFrame).ToNot(BeNil())
 			Expect(blockedFrame.StreamID).To(Equal(stream1.StreamID()))
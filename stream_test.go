@@ -59,7 +59,7 @@ var _ = Describe("Stream", func() {
 		onDataCalled = false
 		resetCalled = false
 		mockFcm = mocks_fc.NewMockFlowControlManager(mockCtrl)
-		str = newStream(streamID, onData, onReset, mockFcm, str.perspective)
+		str = newStream(streamID, onData, onReset, mockFcm, nil)
 
 		timeout := scaleDuration(250 * time.Millisecond)
 		strWithTimeout = struct {
@@ -266,6 +266,22 @@ var _ = Describe("Stream", func() {
 			Expect(onDataCalled).To(BeTrue())
 		})
 
+		It("calls onRead with the frames read", func() {
+			var read []*wire.StreamFrame
+			str.onRead = func(f *wire.StreamFrame) { read = append(read, f) }
+			mockFcm.EXPECT().UpdateHighestReceived(streamID, protocol.ByteCount(4))
+			mockFcm.EXPECT().AddBytesRead(streamID, protocol.ByteCount(4))
+			frame := wire.StreamFrame{
+				Offset: 0,
+				Data:   []byte{0xDE, 0xAD, 0xBE, 0xEF},
+			}
+			str.AddStreamFrame(&frame)
+			b := make([]byte, 4)
+			_, err := strWithTimeout.Read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(read).To(Equal([]*wire.StreamFrame{&frame}))
+		})
+
 		Context("deadlines", func() {
 			It("the deadline error has the right net.Error properties", func() {
 				Expect(errDeadline.Temporary()).To(BeTrue())
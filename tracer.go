@@ -0,0 +1,117 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// traceFrames describes the frames of a packet for a Tracer
+func traceFrames(frames []wire.Frame) []TracedFrame {
+	traced := make([]TracedFrame, 0, len(frames))
+	for _, frame := range frames {
+		traced = append(traced, traceFrame(frame))
+	}
+	return traced
+}
+
+func traceFrame(frame wire.Frame) TracedFrame {
+	switch f := frame.(type) {
+	case *wire.StreamFrame:
+		return TracedFrame{Type: "stream", StreamID: f.StreamID, Offset: f.Offset, Length: f.DataLen(), Fin: f.FinBit}
+	case *wire.AckFrame:
+		return TracedFrame{Type: "ack", PathID: f.PathID, AckRanges: traceAckRanges(f.LargestAcked, f.LowestAcked, f.AckRanges)}
+	case *wire.ClosePathFrame:
+		return TracedFrame{Type: "close_path", PathID: f.PathID, AckRanges: traceAckRanges(f.LargestAcked, f.LowestAcked, f.AckRanges)}
+	case *wire.StopWaitingFrame:
+		return TracedFrame{Type: "stop_waiting"}
+	case *wire.RstStreamFrame:
+		return TracedFrame{Type: "reset_stream", StreamID: f.StreamID, Offset: f.ByteOffset, ErrorCode: f.ErrorCode}
+	case *wire.WindowUpdateFrame:
+		if f.StreamID == 0 {
+			return TracedFrame{Type: "max_data", Offset: f.ByteOffset}
+		}
+		return TracedFrame{Type: "max_stream_data", StreamID: f.StreamID, Offset: f.ByteOffset}
+	case *wire.BlockedFrame:
+		if f.StreamID == 0 {
+			return TracedFrame{Type: "data_blocked"}
+		}
+		return TracedFrame{Type: "stream_data_blocked", StreamID: f.StreamID}
+	case *wire.ConnectionCloseFrame:
+		return TracedFrame{Type: "connection_close", ErrorCode: uint32(f.ErrorCode), Reason: f.ReasonPhrase}
+	case *wire.GoawayFrame:
+		return TracedFrame{Type: "goaway", ErrorCode: uint32(f.ErrorCode), Reason: f.ReasonPhrase}
+	case *wire.PingFrame:
+		return TracedFrame{Type: "ping"}
+	case *wire.AddAddressFrame:
+		return TracedFrame{Type: "add_address"}
+	case *wire.RemoveAddressFrame:
+		return TracedFrame{Type: "remove_address"}
+	case *wire.PathStatusFrame:
+		return TracedFrame{Type: "path_status", PathID: f.PathID}
+	case *wire.PathsFrame:
+		return TracedFrame{Type: "paths"}
+	case *wire.PathChallengeFrame:
+		return TracedFrame{Type: "path_challenge"}
+	case *wire.PathResponseFrame:
+		return TracedFrame{Type: "path_response"}
+	default:
+		return TracedFrame{Type: "unknown"}
+	}
+}
+
+func traceAckRanges(largest, lowest protocol.PacketNumber, ackRanges []wire.AckRange) [][2]PacketNumber {
+	if len(ackRanges) == 0 {
+		return [][2]PacketNumber{{lowest, largest}}
+	}
+	ranges := make([][2]PacketNumber, 0, len(ackRanges))
+	for _, r := range ackRanges {
+		ranges = append(ranges, [2]PacketNumber{r.First, r.Last})
+	}
+	return ranges
+}
+
+func (s *session) traceSentPacket(packet *packedPacket, pathID protocol.PathID) {
+	if s.tracer == nil {
+		return
+	}
+	s.tracer.SentPacket(pathID, packet.number, protocol.ByteCount(len(packet.raw)), traceFrames(packet.frames))
+}
+
+// traceLostPacket is the loss callback of the sent packet handlers
+func (s *session) traceLostPacket(pathID protocol.PathID, packetNumber protocol.PacketNumber) {
+	if s.tracer == nil {
+		return
+	}
+	s.tracer.LostPacket(pathID, packetNumber)
+}
+
+// traceSelectedPath reports the path chosen by the scheduler, and the redundant paths
+func (sch *scheduler) traceSelectedPath(s *session, pth *path) {
+	if s.tracer == nil {
+		return
+	}
+	var redundantPaths []PathID
+	for _, redPth := range sch.redundantPaths {
+		redundantPaths = append(redundantPaths, redPth.pathID)
+	}
+	s.tracer.SelectedPath(pth.pathID, redundantPaths)
+}
+
+// traceCongestionState reports the congestion state of the path, if it changed since it was last reported
+func (p *path) traceCongestionState() {
+	if p.sess.tracer == nil {
+		return
+	}
+	state := CongestionState{
+		CongestionWindow: p.sentPacketHandler.GetCongestionWindow(),
+		BytesInFlight:    p.sentPacketHandler.GetBytesInFlight(),
+		SmoothedRTT:      p.rttStats.SmoothedRTT(),
+		MinRTT:           p.rttStats.MinRTT(),
+		LatestRTT:        p.rttStats.LatestRTT(),
+	}
+	if state == p.tracedState {
+		return
+	}
+	p.tracedState = state
+	p.sess.tracer.UpdatedCongestionState(p.pathID, state)
+}
@@ -0,0 +1,170 @@
+package quic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockTracer struct {
+	mutex sync.Mutex
+
+	sentPackets      []PacketNumber
+	receivedPackets  []PacketNumber
+	lostPackets      []PacketNumber
+	congestionStates []CongestionState
+	selectedPaths    []PathID
+	pathEvents       []PathEvent
+	closed           bool
+}
+
+var _ Tracer = &mockTracer{}
+
+func (t *mockTracer) SentPacket(_ PathID, pn PacketNumber, _ ByteCount, _ []TracedFrame) {
+	t.mutex.Lock()
+	t.sentPackets = append(t.sentPackets, pn)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) ReceivedPacket(_ PathID, pn PacketNumber, _ ByteCount, _ []TracedFrame) {
+	t.mutex.Lock()
+	t.receivedPackets = append(t.receivedPackets, pn)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) LostPacket(_ PathID, pn PacketNumber) {
+	t.mutex.Lock()
+	t.lostPackets = append(t.lostPackets, pn)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) UpdatedCongestionState(_ PathID, state CongestionState) {
+	t.mutex.Lock()
+	t.congestionStates = append(t.congestionStates, state)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) SelectedPath(pathID PathID, _ []PathID) {
+	t.mutex.Lock()
+	t.selectedPaths = append(t.selectedPaths, pathID)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) UpdatedPath(event PathEvent) {
+	t.mutex.Lock()
+	t.pathEvents = append(t.pathEvents, event)
+	t.mutex.Unlock()
+}
+
+func (t *mockTracer) Close() {
+	t.mutex.Lock()
+	t.closed = true
+	t.mutex.Unlock()
+}
+
+var _ = Describe("Tracing", func() {
+	Context("describing frames", func() {
+		It("describes STREAM frames", func() {
+			Expect(traceFrame(&wire.StreamFrame{StreamID: 5, Offset: 100, Data: []byte("foobar"), FinBit: true})).To(Equal(TracedFrame{
+				Type:     "stream",
+				StreamID: 5,
+				Offset:   100,
+				Length:   6,
+				Fin:      true,
+			}))
+		})
+
+		It("describes ACK frames without ranges", func() {
+			f := traceFrame(&wire.AckFrame{PathID: 3, LowestAcked: 2, LargestAcked: 10})
+			Expect(f.Type).To(Equal("ack"))
+			Expect(f.PathID).To(Equal(PathID(3)))
+			Expect(f.AckRanges).To(Equal([][2]PacketNumber{{2, 10}}))
+		})
+
+		It("describes ACK frames with ranges", func() {
+			f := traceFrame(&wire.AckFrame{
+				LowestAcked:  1,
+				LargestAcked: 10,
+				AckRanges:    []wire.AckRange{{First: 8, Last: 10}, {First: 1, Last: 5}},
+			})
+			Expect(f.AckRanges).To(Equal([][2]PacketNumber{{8, 10}, {1, 5}}))
+		})
+
+		It("distinguishes connection and stream level WINDOW_UPDATE frames", func() {
+			Expect(traceFrame(&wire.WindowUpdateFrame{ByteOffset: 0x1000})).To(Equal(TracedFrame{Type: "max_data", Offset: 0x1000}))
+			Expect(traceFrame(&wire.WindowUpdateFrame{StreamID: 7, ByteOffset: 0x1000})).To(Equal(TracedFrame{Type: "max_stream_data", StreamID: 7, Offset: 0x1000}))
+		})
+
+		It("describes all frames of a packet", func() {
+			frames := traceFrames([]wire.Frame{&wire.PingFrame{}, &wire.PathChallengeFrame{}, &wire.StopWaitingFrame{}})
+			Expect(frames).To(HaveLen(3))
+			Expect(frames[0].Type).To(Equal("ping"))
+			Expect(frames[1].Type).To(Equal("path_challenge"))
+			Expect(frames[2].Type).To(Equal("stop_waiting"))
+		})
+	})
+
+	Context("sessions", func() {
+		var (
+			sess   *session
+			pth    *path
+			tracer *mockTracer
+		)
+
+		BeforeEach(func() {
+			tracer = &mockTracer{}
+			sess = &session{
+				paths:     make(map[protocol.PathID]*path),
+				config:    &Config{},
+				scheduler: &scheduler{},
+				tracer:    tracer,
+			}
+			sess.scheduler.setup()
+			pth = &path{pathID: 1, sess: sess}
+			pth.setup(nil)
+			sess.paths[1] = pth
+		})
+
+		AfterEach(func() {
+			pth.closeChan <- nil
+		})
+
+		It("traces sent packets", func() {
+			sess.traceSentPacket(&packedPacket{number: 42, raw: make([]byte, 100)}, 1)
+			Expect(tracer.sentPackets).To(Equal([]PacketNumber{42}))
+		})
+
+		It("traces lost packets", func() {
+			for pn := protocol.PacketNumber(1); pn <= 3; pn++ {
+				Expect(pth.sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: pn, Frames: []wire.Frame{&wire.PingFrame{}}, Length: 1})).To(Succeed())
+			}
+			pth.sentPacketHandler.SetInflightAsLost()
+			Expect(tracer.lostPackets).To(BeEmpty()) // nothing was acknowledged yet
+			Expect(pth.sentPacketHandler.ReceivedAck(&wire.AckFrame{PathID: 1, LowestAcked: 3, LargestAcked: 3}, 1, time.Now())).To(Succeed())
+			pth.sentPacketHandler.SetInflightAsLost()
+			Expect(tracer.lostPackets).To(Equal([]PacketNumber{1, 2}))
+		})
+
+		It("traces the congestion state once it changed", func() {
+			pth.traceCongestionState()
+			pth.traceCongestionState()
+			Expect(tracer.congestionStates).To(HaveLen(1))
+			Expect(tracer.congestionStates[0].CongestionWindow).To(Equal(pth.sentPacketHandler.GetCongestionWindow()))
+			pth.rttStats.UpdateRTT(10*time.Millisecond, 0, time.Now())
+			pth.traceCongestionState()
+			Expect(tracer.congestionStates).To(HaveLen(2))
+			Expect(tracer.congestionStates[1].SmoothedRTT).To(Equal(10 * time.Millisecond))
+		})
+
+		It("traces the decisions of the scheduler", func() {
+			sess.scheduler.traceSelectedPath(sess, pth)
+			Expect(tracer.selectedPaths).To(Equal([]PathID{1}))
+		})
+	})
+})
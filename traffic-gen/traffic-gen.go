@@ -165,7 +165,7 @@ func (manager *ClientManager) send(client *Client) {
 	}
 }
 
-func startServerMode(address string, protocol string, multipath bool, log_file string) {
+func startServerMode(address string, protocol string, multipath bool, log_file string, scheduler string, cc string) {
 	fmt.Println("Starting server...")
 	var listener net.Listener
 	var err error
@@ -199,13 +199,13 @@ func startServerMode(address string, protocol string, multipath bool, log_file s
 		}
 	case "quic":
 
-		startQUICServer(address)
+		startQUICServer(address, scheduler, cc)
 
 	}
 
 }
 
-func startClientMode(address string, protocol string, run_time uint, csize_distro string, csize_value float64, arrival_distro string, arrival_value float64, multipath bool, scheduler string) {
+func startClientMode(address string, protocol string, run_time uint, csize_distro string, csize_value float64, arrival_distro string, arrival_value float64, multipath bool, scheduler string, cc string) {
 	//	fmt.Println("Starting client...")
 
 	var stream quic.Stream
@@ -215,7 +215,7 @@ func startClientMode(address string, protocol string, run_time uint, csize_distr
 
 	if protocol == "quic" {
 		addresses := []string{address}
-		quic_session, stream, err = startQUICClient(addresses, scheduler)
+		quic_session, stream, err = startQUICClient(addresses, scheduler, cc)
 		defer stream.Close()
 		defer quic_session.Close(nil)
 
@@ -334,9 +334,11 @@ func startClientMode(address string, protocol string, run_time uint, csize_distr
 	// }()
 }
 
-func startQUICServer(addr string) error {
+func startQUICServer(addr string, scheduler string, cc string) error {
 	listener, err := quic.ListenAddr(addr, generateTLSConfig(), &quic.Config{
-		CreatePaths: false,
+		CreatePaths:       false,
+		Scheduler:         scheduler,
+		CongestionControl: cc,
 	})
 	if err != nil {
 		return err
@@ -403,16 +405,26 @@ func startQUICServer(addr string) error {
 	return err
 }
 
-func startQUICClient(urls []string, scheduler string) (sess quic.Session, stream quic.Stream, err error) {
+func startQUICClient(urls []string, scheduler string, cc string) (sess quic.Session, stream quic.Stream, err error) {
 
 	session, err := quic.DialAddr(urls[0], &tls.Config{InsecureSkipVerify: true}, &quic.Config{
-		CreatePaths: true,
+		CreatePaths:       true,
+		Scheduler:         scheduler,
+		CongestionControl: cc,
+		// write sender-frame.log and receiver-frame.log to the working directory, they are moved to LOG_PREFIX when done
+		MetricsSink: func(bool, quic.ConnectionID) quic.MetricsSink {
+			sink, err := quic.NewFileMetricsSink(".")
+			if err != nil {
+				log.Println(err)
+				return nil
+			}
+			return sink
+		},
 	})
 
 	if err != nil {
 		return nil, nil, err
 	}
-	quic.SetSchedulerAlgorithm(scheduler)
 	stream, err2 := session.OpenStreamSync()
 	if err2 != nil {
 		return nil, nil, err2
@@ -590,6 +602,10 @@ func schedNameConvert(protocol string, sched_name string) string {
 			converted_name = "oppRedundant"
 		case "utlr":
 			converted_name = "utilRepair"
+		case "blest":
+			converted_name = "blest"
+		case "ecf":
+			converted_name = "ecf"
 		default:
 			panic("no scheduler found")
 		}
@@ -619,13 +635,11 @@ func main() {
 	}
 
 	LOG_PREFIX = *flagLog
-	quic.SetCongestionControl(*flagCong)
 	sched := schedNameConvert(*flagProtocol, *flagSched)
 	if strings.ToLower(*flagMode) == "server" {
-		quic.SetSchedulerAlgorithm(sched)
-		startServerMode(*flagAddress, *flagProtocol, *flagMultipath, *flagLog)
+		startServerMode(*flagAddress, *flagProtocol, *flagMultipath, *flagLog, sched, *flagCong)
 	} else {
-		startClientMode(*flagAddress, *flagProtocol, *flagTime, *flagCsizeDistro, float64(*flagCsizeValue), *flagArrDistro, float64(*flagArrValue), *flagMultipath, sched)
+		startClientMode(*flagAddress, *flagProtocol, *flagTime, *flagCsizeDistro, float64(*flagCsizeValue), *flagArrDistro, float64(*flagArrValue), *flagMultipath, sched, *flagCong)
 	}
 
 }